@@ -19,6 +19,8 @@ package codec
 type PACK string
 
 const (
-	MCPACK  PACK = "mcpack"  //mcpack 算法: like Json decode/encode
-	MSGPACK PACK = "msgpack" //msgpack https://msgpack.uptrace.dev/
+	MCPACK   PACK = "mcpack"  //mcpack 算法: like Json decode/encode
+	MSGPACK  PACK = "msgpack" //msgpack https://msgpack.uptrace.dev/
+	JSONPACK PACK = "json"    //json encoding/json
+	TOMLPACK PACK = "toml"    //toml https://toml.io/
 )
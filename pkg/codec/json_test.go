@@ -0,0 +1,49 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package codec_test
+
+import (
+	"testing"
+
+	"github.com/kubeservice-stack/common/pkg/codec"
+	"github.com/stretchr/testify/assert"
+)
+
+type limits struct {
+	QPS     int               `json:"qps" toml:"qps"`
+	Enable  bool              `json:"enable" toml:"enable"`
+	Name    string            `json:"name" toml:"name"`
+	Targets []string          `json:"targets" toml:"targets"`
+	Tags    map[string]string `json:"tags" toml:"tags"`
+}
+
+func TestJSON(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(codec.HasRegister(codec.JSONPACK))
+
+	a := &limits{QPS: 100, Enable: true, Name: "dongjiang", Targets: []string{"a", "b"}, Tags: map[string]string{"k": "v"}}
+	c := codec.PluginInstance(codec.JSONPACK)
+	data, err := c.Marshal(a)
+	assert.Nil(err)
+	assert.Equal(`{"qps":100,"enable":true,"name":"dongjiang","targets":["a","b"],"tags":{"k":"v"}}`, string(data))
+
+	b := &limits{}
+	assert.Nil(c.Unmarshal(data, b))
+	assert.Equal(a, b)
+
+	assert.NotNil(c.Unmarshal([]byte("{qps"), b))
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package codec
+
+import (
+	"bytes"
+
+	"github.com/BurntSushi/toml"
+)
+
+type TOML struct{}
+
+func NewTOML() Codec {
+	return &TOML{}
+}
+
+func (tl *TOML) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (tl *TOML) Unmarshal(data []byte, v interface{}) error {
+	_, err := toml.Decode(string(data), v)
+	return err
+}
+
+func init() {
+	Register(TOMLPACK, NewTOML)
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package codec_test
+
+import (
+	"testing"
+
+	"github.com/kubeservice-stack/common/pkg/codec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOML(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(codec.HasRegister(codec.TOMLPACK))
+
+	a := &limits{QPS: 100, Enable: true, Name: "dongjiang", Targets: []string{"a", "b"}, Tags: map[string]string{"k": "v"}}
+	c := codec.PluginInstance(codec.TOMLPACK)
+	data, err := c.Marshal(a)
+	assert.Nil(err)
+
+	b := &limits{}
+	assert.Nil(c.Unmarshal(data, b))
+	assert.Equal(a, b)
+
+	b = &limits{}
+	assert.Nil(c.Unmarshal([]byte("qps = 10\nname = \"toml\""), b))
+	assert.Equal(10, b.QPS)
+	assert.Equal("toml", b.Name)
+
+	assert.NotNil(c.Unmarshal([]byte("qps = "), b))
+}
@@ -19,16 +19,34 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/kubeservice-stack/common/pkg/utils"
 )
 
+var (
+	ErrDiscoveryEndpointsEmpty   = fmt.Errorf("discovery: endpoints is empty")
+	ErrDiscoveryNegativeDuration = fmt.Errorf("discovery: duration must not be negative")
+	ErrDiscoveryTLSKeyPair       = fmt.Errorf("discovery: tls_cert_file and tls_key_file must be set together")
+	ErrDiscoveryAuthUsername     = fmt.Errorf("discovery: password is set but username is empty")
+)
+
 type Discovery struct {
-	Namespace   string         `toml:"namespace" json:"namespace" env:"DISCOVERY_NAMESPACE"`         // 命名空间
-	Endpoints   []string       `toml:"endpoints" json:"endpoints" env:"DISCOVERY_ENDPOINTS"`         // 连接端点
-	DialTimeout utils.Duration `toml:"dial_timeout" json:"dial_timeout" env:"DISCOVERY_DIALTIMEOUT"` // 连接超时时间
+	Namespace             string         `toml:"namespace" json:"namespace" env:"DISCOVERY_NAMESPACE"`                                              // 命名空间
+	Endpoints             []string       `toml:"endpoints" json:"endpoints" env:"DISCOVERY_ENDPOINTS"`                                              // 连接端点
+	DialTimeout           utils.Duration `toml:"dial_timeout" json:"dial_timeout" env:"DISCOVERY_DIALTIMEOUT"`                                      // 连接超时时间
+	Username              string         `toml:"username" json:"username" env:"DISCOVERY_USERNAME"`                                                 // 认证用户名
+	Password              string         `toml:"password" json:"password" env:"DISCOVERY_PASSWORD"`                                                 // 认证密码
+	TLSCertFile           string         `toml:"tls_cert_file" json:"tls_cert_file" env:"DISCOVERY_TLS_CERT_FILE"`                                  // 客户端证书
+	TLSKeyFile            string         `toml:"tls_key_file" json:"tls_key_file" env:"DISCOVERY_TLS_KEY_FILE"`                                     // 客户端私钥
+	TLSCAFile             string         `toml:"tls_ca_file" json:"tls_ca_file" env:"DISCOVERY_TLS_CA_FILE"`                                        // CA证书
+	TLSServerName         string         `toml:"tls_server_name" json:"tls_server_name" env:"DISCOVERY_TLS_SERVER_NAME"`                            // 校验服务端证书的域名
+	TLSInsecureSkipVerify bool           `toml:"tls_insecure_skip_verify" json:"tls_insecure_skip_verify" env:"DISCOVERY_TLS_INSECURE_SKIP_VERIFY"` // 跳过服务端证书校验
+	KeepAliveTime         utils.Duration `toml:"keepalive_time" json:"keepalive_time" env:"DISCOVERY_KEEPALIVE_TIME"`                               // keepalive探测周期
+	KeepAliveTimeout      utils.Duration `toml:"keepalive_timeout" json:"keepalive_timeout" env:"DISCOVERY_KEEPALIVE_TIMEOUT"`                      // keepalive探测超时时间
 }
 
+// TOML 生成配置文件内容, password 不会写入, 需要通过 DISCOVERY_PASSWORD 环境变量或者手动配置
 func (ds Discovery) TOML() string {
 	if len(ds.Endpoints) == 0 {
 		ds.Endpoints = []string{}
@@ -37,21 +55,84 @@ func (ds Discovery) TOML() string {
 	return fmt.Sprintf(`
 [discovery]
   ## etcd namespace
-  namespace = "%s"
+  namespace = %s
   ## etcd 集群配置
   endpoints = %s
   ## ETCD连接 timeout时间
-  dial_timeout = "%s"`,
-		ds.Namespace,
+  dial_timeout = %s
+  ## ETCD认证用户名, 为空时不开启认证
+  username = %s
+  ## ETCD认证密码, 不写入生成的配置, 通过 DISCOVERY_PASSWORD 环境变量设置
+  # password = ""
+  ## mTLS 客户端证书文件
+  tls_cert_file = %s
+  ## mTLS 客户端私钥文件
+  tls_key_file = %s
+  ## 校验服务端证书的CA文件
+  tls_ca_file = %s
+  ## 校验服务端证书的域名, 为空时使用endpoint的host
+  tls_server_name = %s
+  ## 是否跳过服务端证书校验
+  tls_insecure_skip_verify = %v
+  ## keepalive探测周期, 0s表示不开启
+  keepalive_time = %s
+  ## keepalive探测超时时间
+  keepalive_timeout = %s`,
+		tomlString(ds.Namespace),
 		endpoints,
-		ds.DialTimeout.String(),
+		tomlString(ds.DialTimeout.String()),
+		tomlString(ds.Username),
+		tomlString(ds.TLSCertFile),
+		tomlString(ds.TLSKeyFile),
+		tomlString(ds.TLSCAFile),
+		tomlString(ds.TLSServerName),
+		ds.TLSInsecureSkipVerify,
+		tomlString(ds.KeepAliveTime.String()),
+		tomlString(ds.KeepAliveTimeout.String()),
 	)
 }
 
+// tomlString 转义为 TOML 字符串, JSON 字符串的转义规则是 TOML basic string 的子集
+func tomlString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// EnableTLS 是否需要使用TLS连接etcd
+func (ds Discovery) EnableTLS() bool {
+	return ds.TLSCertFile != "" || ds.TLSCAFile != "" || ds.TLSServerName != "" || ds.TLSInsecureSkipVerify
+}
+
+// Validate 校验配置是否合法
+func (ds Discovery) Validate() error {
+	if len(ds.Endpoints) == 0 {
+		return ErrDiscoveryEndpointsEmpty
+	}
+	if ds.DialTimeout < 0 {
+		return fmt.Errorf("%w: dial_timeout = %s", ErrDiscoveryNegativeDuration, ds.DialTimeout)
+	}
+	if ds.KeepAliveTime < 0 {
+		return fmt.Errorf("%w: keepalive_time = %s", ErrDiscoveryNegativeDuration, ds.KeepAliveTime)
+	}
+	if ds.KeepAliveTimeout < 0 {
+		return fmt.Errorf("%w: keepalive_timeout = %s", ErrDiscoveryNegativeDuration, ds.KeepAliveTimeout)
+	}
+	if (ds.TLSCertFile == "") != (ds.TLSKeyFile == "") {
+		return ErrDiscoveryTLSKeyPair
+	}
+	if ds.Username == "" && ds.Password != "" {
+		return ErrDiscoveryAuthUsername
+	}
+	return nil
+}
+
 func (ds Discovery) DefaultConfig() Discovery {
 	ds = Discovery{
-		Namespace: "application",
-		Endpoints: []string{"http://127.0.0.1:2379"},
+		Namespace:        "application",
+		Endpoints:        []string{"http://127.0.0.1:2379"},
+		DialTimeout:      utils.Duration(5 * time.Second),
+		KeepAliveTime:    utils.Duration(30 * time.Second),
+		KeepAliveTimeout: utils.Duration(10 * time.Second),
 	}
 	return ds
 }
@@ -17,8 +17,12 @@ limitations under the License.
 package config
 
 import (
+	"os"
 	"testing"
+	"time"
 
+	"github.com/BurntSushi/toml"
+	"github.com/caarlos0/env/v6"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -33,5 +37,113 @@ func Test_Discovery(t *testing.T) {
   ## etcd 集群配置
   endpoints = ["http://127.0.0.1:2379"]
   ## ETCD连接 timeout时间
-  dial_timeout = "0s"`)
+  dial_timeout = "5s"
+  ## ETCD认证用户名, 为空时不开启认证
+  username = ""
+  ## ETCD认证密码, 不写入生成的配置, 通过 DISCOVERY_PASSWORD 环境变量设置
+  # password = ""
+  ## mTLS 客户端证书文件
+  tls_cert_file = ""
+  ## mTLS 客户端私钥文件
+  tls_key_file = ""
+  ## 校验服务端证书的CA文件
+  tls_ca_file = ""
+  ## 校验服务端证书的域名, 为空时使用endpoint的host
+  tls_server_name = ""
+  ## 是否跳过服务端证书校验
+  tls_insecure_skip_verify = false
+  ## keepalive探测周期, 0s表示不开启
+  keepalive_time = "30s"
+  ## keepalive探测超时时间
+  keepalive_timeout = "10s"`)
+}
+
+func Test_DiscoveryTOMLEscape(t *testing.T) {
+	assert := assert.New(t)
+
+	ds := GlobalCfg.Discovery.DefaultConfig()
+	ds.Namespace = `app"\ns`
+	ds.Username = "root"
+	ds.Password = "secret"
+	ds.TLSCAFile = `C:\etcd\ca.crt`
+	out := ds.TOML()
+	assert.NotContains(out, "secret")
+
+	var cfg struct {
+		Discovery Discovery `toml:"discovery"`
+	}
+	_, err := toml.Decode(out, &cfg)
+	assert.Nil(err)
+	assert.Equal(ds.Namespace, cfg.Discovery.Namespace)
+	assert.Equal(ds.TLSCAFile, cfg.Discovery.TLSCAFile)
+	assert.Equal("root", cfg.Discovery.Username)
+	assert.Equal("", cfg.Discovery.Password)
+}
+
+func Test_DiscoveryValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	ds := GlobalCfg.Discovery.DefaultConfig()
+	assert.Nil(ds.Validate())
+	assert.False(ds.EnableTLS())
+
+	empty := ds
+	empty.Endpoints = nil
+	assert.ErrorIs(empty.Validate(), ErrDiscoveryEndpointsEmpty)
+
+	negative := ds
+	negative.DialTimeout = -1
+	assert.ErrorIs(negative.Validate(), ErrDiscoveryNegativeDuration)
+	negative = ds
+	negative.KeepAliveTime = -1
+	assert.ErrorIs(negative.Validate(), ErrDiscoveryNegativeDuration)
+	negative = ds
+	negative.KeepAliveTimeout = -1
+	assert.ErrorIs(negative.Validate(), ErrDiscoveryNegativeDuration)
+
+	keyPair := ds
+	keyPair.TLSCertFile = "client.crt"
+	assert.True(keyPair.EnableTLS())
+	assert.Equal(ErrDiscoveryTLSKeyPair, keyPair.Validate())
+	keyPair.TLSKeyFile = "client.key"
+	assert.Nil(keyPair.Validate())
+
+	serverName := ds
+	serverName.TLSServerName = "etcd.local"
+	assert.True(serverName.EnableTLS())
+
+	auth := ds
+	auth.Password = "secret"
+	assert.Equal(ErrDiscoveryAuthUsername, auth.Validate())
+	auth.Username = "root"
+	assert.Nil(auth.Validate())
+}
+
+func Test_DiscoveryEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	envs := map[string]string{
+		"DISCOVERY_USERNAME":                 "root",
+		"DISCOVERY_PASSWORD":                 "secret",
+		"DISCOVERY_TLS_CA_FILE":              "/etc/etcd/ca.crt",
+		"DISCOVERY_TLS_INSECURE_SKIP_VERIFY": "true",
+		"DISCOVERY_KEEPALIVE_TIME":           "15s",
+	}
+	for k, v := range envs {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range envs {
+			os.Unsetenv(k)
+		}
+	}()
+
+	ds := GlobalCfg.Discovery.DefaultConfig()
+	assert.Nil(env.Parse(&ds))
+	assert.Equal("root", ds.Username)
+	assert.Equal("secret", ds.Password)
+	assert.Equal("/etc/etcd/ca.crt", ds.TLSCAFile)
+	assert.True(ds.TLSInsecureSkipVerify)
+	assert.Equal(15*time.Second, ds.KeepAliveTime.Duration())
+	assert.True(ds.EnableTLS())
 }
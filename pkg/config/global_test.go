@@ -81,6 +81,24 @@ func Test_global(t *testing.T) {
   endpoints = []
   ## ETCD连接 timeout时间
   dial_timeout = "0s"
+  ## ETCD认证用户名, 为空时不开启认证
+  username = ""
+  ## ETCD认证密码, 不写入生成的配置, 通过 DISCOVERY_PASSWORD 环境变量设置
+  # password = ""
+  ## mTLS 客户端证书文件
+  tls_cert_file = ""
+  ## mTLS 客户端私钥文件
+  tls_key_file = ""
+  ## 校验服务端证书的CA文件
+  tls_ca_file = ""
+  ## 校验服务端证书的域名, 为空时使用endpoint的host
+  tls_server_name = ""
+  ## 是否跳过服务端证书校验
+  tls_insecure_skip_verify = false
+  ## keepalive探测周期, 0s表示不开启
+  keepalive_time = "0s"
+  ## keepalive探测超时时间
+  keepalive_timeout = "0s"
 [gin]
   ## APP name
   app = "server-override"
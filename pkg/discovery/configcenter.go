@@ -0,0 +1,269 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kubeservice-stack/common/pkg/codec"
+	"github.com/kubeservice-stack/common/pkg/logger"
+)
+
+var (
+	ErrConfigNotFound = fmt.Errorf("config center: config not found in discovery and fallback file")
+	ErrConfigStarted  = fmt.Errorf("config center: already started")
+)
+
+// ConfigValidator 配置对象实现该接口后, 每次更新前都会先校验, 校验失败不会替换当前配置
+type ConfigValidator interface {
+	Validate() error
+}
+
+// ConfigChangeFunc 配置变更回调, old 在首次加载时为nil
+type ConfigChangeFunc[T any] func(old, new *T)
+
+// ConfigOption 配置中心可选参数
+type ConfigOption func(*configOptions)
+
+type configOptions struct {
+	codec        codec.Codec
+	prefix       bool
+	fallbackFile string
+}
+
+// WithConfigCodec 设置配置的编解码方式, 默认TOML
+func WithConfigCodec(c codec.Codec) ConfigOption {
+	return func(o *configOptions) {
+		o.codec = c
+	}
+}
+
+// WithConfigPrefix 绑定前缀: 前缀下所有key按字典序依次解码到同一个配置对象, 后面的覆盖前面的
+func WithConfigPrefix() ConfigOption {
+	return func(o *configOptions) {
+		o.prefix = true
+	}
+}
+
+// WithConfigFallbackFile 首次加载时discovery不可用或者没有数据, 从本地文件加载
+func WithConfigFallbackFile(fileName string) ConfigOption {
+	return func(o *configOptions) {
+		o.fallbackFile = fileName
+	}
+}
+
+// ConfigCenter 将discovery中的key或者前缀绑定到类型T, 数据变更时自动解码、校验并原子替换
+type ConfigCenter[T any] struct {
+	ds   Discovery
+	key  string
+	opts configOptions
+
+	value   atomic.Value // *T
+	started int32
+
+	mu          sync.RWMutex
+	raw         map[string][]byte // 当前生效的原始数据
+	subscribers []ConfigChangeFunc[T]
+
+	logger *logger.Logger
+}
+
+// NewConfigCenter 创建配置中心
+func NewConfigCenter[T any](ds Discovery, key string, opts ...ConfigOption) *ConfigCenter[T] {
+	o := configOptions{codec: codec.NewTOML()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &ConfigCenter[T]{
+		ds:     ds,
+		key:    key,
+		opts:   o,
+		raw:    make(map[string][]byte),
+		logger: logger.GetLogger("pkg/common/discovery", "ConfigCenter"),
+	}
+}
+
+// Start 首次加载配置并在后台watch变更, ctx结束时停止watch
+func (c *ConfigCenter[T]) Start(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&c.started, 0, 1) {
+		return ErrConfigStarted
+	}
+	if err := c.load(ctx); err != nil {
+		atomic.StoreInt32(&c.started, 0)
+		return err
+	}
+
+	var ch WatchEventChan
+	if c.opts.prefix {
+		ch = c.ds.WatchPrefix(ctx, c.key, true)
+	} else {
+		ch = c.ds.Watch(ctx, c.key, true)
+	}
+	go c.watch(ch)
+	return nil
+}
+
+// Get 返回当前生效的配置, 未加载时返回nil. 返回值只读, 不要修改
+func (c *ConfigCenter[T]) Get() *T {
+	v, _ := c.value.Load().(*T)
+	return v
+}
+
+// Subscribe 订阅配置变更
+func (c *ConfigCenter[T]) Subscribe(fn ConfigChangeFunc[T]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribers = append(c.subscribers, fn)
+}
+
+// load 首次加载, discovery失败或者没有数据时使用本地文件
+func (c *ConfigCenter[T]) load(ctx context.Context) error {
+	raw, err := c.fetch(ctx)
+	if err == nil && len(raw) > 0 {
+		return c.apply(raw)
+	}
+	if c.opts.fallbackFile == "" {
+		if err != nil {
+			return err
+		}
+		return ErrConfigNotFound
+	}
+	c.logger.Warn("load config from discovery failure, use fallback file",
+		logger.String("key", c.key),
+		logger.String("file", c.opts.fallbackFile),
+		logger.Any("error", err))
+	data, ferr := os.ReadFile(c.opts.fallbackFile)
+	if ferr != nil {
+		return fmt.Errorf("%w: read fallback file[%s] error:%s", ErrConfigNotFound, c.opts.fallbackFile, ferr)
+	}
+	return c.apply(map[string][]byte{c.opts.fallbackFile: data})
+}
+
+func (c *ConfigCenter[T]) fetch(ctx context.Context) (map[string][]byte, error) {
+	raw := make(map[string][]byte)
+	if c.opts.prefix {
+		result, err := c.ds.ListPrefix(ctx, c.key)
+		if err != nil {
+			return nil, err
+		}
+		for _, kv := range result.KVs {
+			if len(kv.Value) > 0 {
+				raw[kv.Key] = kv.Value
+			}
+		}
+		return raw, nil
+	}
+	val, err := c.ds.Get(ctx, c.key)
+	if err != nil {
+		return nil, err
+	}
+	raw[c.key] = val
+	return raw, nil
+}
+
+func (c *ConfigCenter[T]) watch(ch WatchEventChan) {
+	for event := range ch {
+		if event.Err != nil {
+			continue
+		}
+		c.mu.RLock()
+		raw := make(map[string][]byte, len(c.raw))
+		if event.Type != EventTypeAll {
+			for k, v := range c.raw {
+				// discovery 有数据后不再使用本地文件
+				if c.opts.fallbackFile != "" && k == c.opts.fallbackFile {
+					continue
+				}
+				raw[k] = v
+			}
+		}
+		c.mu.RUnlock()
+
+		for _, kv := range event.KeyValues {
+			if event.Type == EventTypeDelete || len(kv.Value) == 0 {
+				delete(raw, kv.Key)
+				continue
+			}
+			raw[kv.Key] = kv.Value
+		}
+		if len(raw) == 0 {
+			// 配置被删除, 保留最后一次生效的配置
+			c.logger.Warn("config is deleted, keep the last one", logger.String("key", c.key))
+			continue
+		}
+		if err := c.apply(raw); err != nil {
+			c.logger.Error("apply config failure, keep the last one", logger.Error(err), logger.String("key", c.key))
+		}
+	}
+}
+
+// apply 解码、校验后原子替换, 并通知订阅者
+func (c *ConfigCenter[T]) apply(raw map[string][]byte) error {
+	c.mu.Lock()
+	if equalRaw(c.raw, raw) && c.Get() != nil {
+		c.mu.Unlock()
+		return nil
+	}
+
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	val := new(T)
+	for _, k := range keys {
+		if err := c.opts.codec.Unmarshal(raw[k], val); err != nil {
+			c.mu.Unlock()
+			return fmt.Errorf("decode config key[%s] error:%w", k, err)
+		}
+	}
+	if v, ok := interface{}(val).(ConfigValidator); ok {
+		if err := v.Validate(); err != nil {
+			c.mu.Unlock()
+			return fmt.Errorf("validate config key[%s] error:%w", c.key, err)
+		}
+	}
+
+	old := c.Get()
+	c.value.Store(val)
+	c.raw = raw
+	subscribers := append([]ConfigChangeFunc[T]{}, c.subscribers...)
+	c.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(old, val)
+	}
+	return nil
+}
+
+func equalRaw(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || string(v) != string(w) {
+			return false
+		}
+	}
+	return true
+}
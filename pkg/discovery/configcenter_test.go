@@ -0,0 +1,178 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeservice-stack/common/pkg/codec"
+	"github.com/kubeservice-stack/common/pkg/config"
+)
+
+type testLimits struct {
+	QPS    int  `toml:"qps" json:"qps"`
+	Burst  int  `toml:"burst" json:"burst"`
+	Enable bool `toml:"enable" json:"enable"`
+}
+
+func (l *testLimits) Validate() error {
+	if l.QPS < 0 {
+		return fmt.Errorf("qps must not be negative")
+	}
+	return nil
+}
+
+type changes struct {
+	sync.Mutex
+	olds []*testLimits
+	news []*testLimits
+}
+
+func (c *changes) on(old, new *testLimits) {
+	c.Lock()
+	defer c.Unlock()
+	c.olds = append(c.olds, old)
+	c.news = append(c.news, new)
+}
+
+func (c *changes) len() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.news)
+}
+
+func TestConfigCenter(t *testing.T) {
+	assert := assert.New(t)
+	cluster := StartEtcdMockCluster(t)
+	defer cluster.Terminate(t)
+
+	ds, err := NewDiscoveryFactory("nobody").CreateDiscovery(config.Discovery{
+		Namespace: "/test/configcenter",
+		Endpoints: cluster.Endpoints,
+	})
+	assert.Nil(err)
+	defer ds.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	assert.Nil(ds.Put(ctx, "/limits", []byte("qps = 100\nburst = 10")))
+
+	cc := NewConfigCenter[testLimits](ds, "/limits")
+	var ch changes
+	cc.Subscribe(ch.on)
+	assert.Nil(cc.Get())
+	assert.Nil(cc.Start(ctx))
+	assert.Equal(ErrConfigStarted, cc.Start(ctx))
+	assert.Equal(&testLimits{QPS: 100, Burst: 10}, cc.Get())
+	assert.Equal(1, ch.len())
+	assert.Nil(ch.olds[0])
+
+	// 更新
+	assert.Nil(ds.Put(ctx, "/limits", []byte("qps = 200\nburst = 20\nenable = true")))
+	assert.Eventually(func() bool { return cc.Get().QPS == 200 }, 3*time.Second, 10*time.Millisecond)
+	assert.Equal(&testLimits{QPS: 200, Burst: 20, Enable: true}, cc.Get())
+	assert.Equal(2, ch.len())
+	assert.Equal(100, ch.olds[1].QPS)
+	assert.Equal(200, ch.news[1].QPS)
+
+	// 校验失败, 保留旧配置
+	assert.Nil(ds.Put(ctx, "/limits", []byte("qps = -1")))
+	// 解码失败, 保留旧配置
+	assert.Nil(ds.Put(ctx, "/limits", []byte("qps = ")))
+	// 删除, 保留旧配置
+	assert.Nil(ds.Delete(ctx, "/limits"))
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(200, cc.Get().QPS)
+	assert.Equal(2, ch.len())
+
+	assert.Nil(ds.Put(ctx, "/limits", []byte("qps = 300")))
+	assert.Eventually(func() bool { return cc.Get().QPS == 300 }, 3*time.Second, 10*time.Millisecond)
+}
+
+func TestConfigCenterPrefix(t *testing.T) {
+	assert := assert.New(t)
+	cluster := StartEtcdMockCluster(t)
+	defer cluster.Terminate(t)
+
+	ds, err := NewDiscoveryFactory("nobody").CreateDiscovery(config.Discovery{
+		Namespace: "/test/configcenter",
+		Endpoints: cluster.Endpoints,
+	})
+	assert.Nil(err)
+	defer ds.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	assert.Nil(ds.Put(ctx, "/app/00-default", []byte(`{"qps":100,"burst":10}`)))
+	assert.Nil(ds.Put(ctx, "/app/10-override", []byte(`{"qps":150}`)))
+
+	cc := NewConfigCenter[testLimits](ds, "/app", WithConfigPrefix(), WithConfigCodec(codec.NewJSON()))
+	assert.Nil(cc.Start(ctx))
+	assert.Equal(&testLimits{QPS: 150, Burst: 10}, cc.Get())
+
+	assert.Nil(ds.Delete(ctx, "/app/10-override"))
+	assert.Eventually(func() bool { return cc.Get().QPS == 100 }, 3*time.Second, 10*time.Millisecond)
+
+	assert.Nil(ds.Put(ctx, "/app/20-enable", []byte(`{"enable":true}`)))
+	assert.Eventually(func() bool { return cc.Get().Enable }, 3*time.Second, 10*time.Millisecond)
+	assert.Equal(&testLimits{QPS: 100, Burst: 10, Enable: true}, cc.Get())
+}
+
+func TestConfigCenterFallback(t *testing.T) {
+	assert := assert.New(t)
+	cluster := StartEtcdMockCluster(t)
+	defer cluster.Terminate(t)
+
+	ds, err := NewDiscoveryFactory("nobody").CreateDiscovery(config.Discovery{
+		Namespace: "/test/configcenter",
+		Endpoints: cluster.Endpoints,
+	})
+	assert.Nil(err)
+	defer ds.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 没有fallback
+	cc := NewConfigCenter[testLimits](ds, "/fallback")
+	assert.NotNil(cc.Start(ctx))
+
+	// fallback 文件不存在
+	dir := t.TempDir()
+	cc = NewConfigCenter[testLimits](ds, "/fallback", WithConfigFallbackFile(filepath.Join(dir, "not-exist.toml")))
+	assert.ErrorIs(cc.Start(ctx), ErrConfigNotFound)
+
+	file := filepath.Join(dir, "limits.toml")
+	assert.Nil(os.WriteFile(file, []byte("qps = 50"), 0600))
+	cc = NewConfigCenter[testLimits](ds, "/fallback", WithConfigFallbackFile(file))
+	assert.Nil(cc.Start(ctx))
+	assert.Equal(50, cc.Get().QPS)
+
+	// discovery 写入后覆盖本地文件配置
+	assert.Nil(ds.Put(ctx, "/fallback", []byte("qps = 60")))
+	assert.Eventually(func() bool { return cc.Get().QPS == 60 }, 3*time.Second, 10*time.Millisecond)
+}
@@ -18,9 +18,12 @@ package discovery
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
-	"path/filepath"
-	"strings"
+	"os"
+
+	"github.com/pkg/errors"
 
 	"github.com/kubeservice-stack/common/pkg/config"
 	"github.com/kubeservice-stack/common/pkg/logger"
@@ -29,31 +32,86 @@ import (
 )
 
 type etcdDiscovery struct {
-	namespace string
-	client    *etcdcliv3.Client
-	logger    *logger.Logger
+	owner  string
+	codec  keyCodec
+	client *etcdcliv3.Client
+	logger *logger.Logger
+	isView bool // 通过 WithNamespace 创建的视图, 不持有 client
 }
 
 func newEtedDiscovery(cfg config.Discovery, owner string) (Discovery, error) {
-	cf := etcdcliv3.Config{
-		Endpoints: cfg.Endpoints,
-		//TODO: maybe bug dongjiang
-		//DialTimeout: config.DialTimeout * time.Second,
+	cf, err := newEtcdConfig(cfg)
+	if err != nil {
+		return nil, err
 	}
 	cli, err := etcdcliv3.New(cf)
 	if err != nil {
 		return nil, fmt.Errorf("create etc client error:%s", err)
 	}
 	ed := etcdDiscovery{
-		namespace: cfg.Namespace,
-		client:    cli,
-		logger:    logger.GetLogger(owner, "ETCD")}
+		owner:  owner,
+		codec:  newKeyCodec(cfg.Namespace),
+		client: cli,
+		logger: logger.GetLogger(owner, "ETCD")}
 
 	ed.logger.Info("new etcd client successfully",
-		logger.Any("endpoints", cfg.Endpoints))
+		logger.Any("endpoints", cfg.Endpoints),
+		logger.Any("tls", cf.TLS != nil),
+		logger.Any("auth", cf.Username != ""))
 	return &ed, nil
 }
 
+// newEtcdConfig 将 config.Discovery 转化为 etcd client 配置
+func newEtcdConfig(cfg config.Discovery) (etcdcliv3.Config, error) {
+	if err := cfg.Validate(); err != nil {
+		return etcdcliv3.Config{}, fmt.Errorf("invalid discovery config: %w", err)
+	}
+	cf := etcdcliv3.Config{
+		Endpoints:            cfg.Endpoints,
+		DialTimeout:          cfg.DialTimeout.Duration(),
+		DialKeepAliveTime:    cfg.KeepAliveTime.Duration(),
+		DialKeepAliveTimeout: cfg.KeepAliveTimeout.Duration(),
+		Username:             cfg.Username,
+		Password:             cfg.Password,
+	}
+	if cfg.EnableTLS() {
+		tlsCfg, err := newTLSConfig(cfg)
+		if err != nil {
+			return etcdcliv3.Config{}, err
+		}
+		cf.TLS = tlsCfg
+	}
+	return cf, nil
+}
+
+// newTLSConfig 加载证书文件, 构造 tls 配置
+func newTLSConfig(cfg config.Discovery) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		ServerName:         cfg.TLSServerName,
+		InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if cfg.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load tls key pair cert[%s] key[%s] error:%w", cfg.TLSCertFile, cfg.TLSKeyFile, err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.TLSCAFile != "" {
+		ca, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read tls ca file[%s] error:%w", cfg.TLSCAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("tls ca file[%s] contains no valid certificate", cfg.TLSCAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	return tlsCfg, nil
+}
+
 func (ed *etcdDiscovery) Get(ctx context.Context, key string) ([]byte, error) {
 	resp, err := ed.get(ctx, key)
 	if err != nil {
@@ -62,6 +120,18 @@ func (ed *etcdDiscovery) Get(ctx context.Context, key string) ([]byte, error) {
 	return ed.getValue(key, resp)
 }
 
+func (ed *etcdDiscovery) GetWithRevision(ctx context.Context, key string) ([]byte, int64, error) {
+	resp, err := ed.get(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	val, err := ed.getValue(key, resp)
+	if err != nil {
+		return nil, 0, err
+	}
+	return val, resp.Kvs[0].ModRevision, nil
+}
+
 func (ed *etcdDiscovery) get(ctx context.Context, key string) (*etcdcliv3.GetResponse, error) {
 	resp, err := ed.client.Get(ctx, ed.keyPath(key))
 	if err != nil {
@@ -72,10 +142,7 @@ func (ed *etcdDiscovery) get(ctx context.Context, key string) (*etcdcliv3.GetRes
 
 // keyPath return new key path with namespace prefix
 func (ed *etcdDiscovery) keyPath(key string) string {
-	if len(ed.namespace) > 0 {
-		return filepath.Join(ed.namespace, key)
-	}
-	return key
+	return ed.codec.Encode(key)
 }
 
 func (ed *etcdDiscovery) getValue(key string, resp *etcdcliv3.GetResponse) ([]byte, error) {
@@ -109,10 +176,69 @@ func (ed *etcdDiscovery) List(ctx context.Context, prefix string) ([]KeyValue, e
 
 // parseKey parses the key, removes the namespace
 func (ed *etcdDiscovery) parseKey(key string) string {
-	if len(ed.namespace) == 0 {
-		return key
+	k, _ := ed.codec.Decode(key)
+	return k
+}
+
+// WithNamespace 返回共享同一个 client 的 namespace 视图, namespace 嵌套在当前 namespace 之下.
+// 视图的 Close 不会关闭底层 client
+func (ed *etcdDiscovery) WithNamespace(namespace string) Discovery {
+	return &etcdDiscovery{
+		owner:  ed.owner,
+		codec:  ed.codec.Sub(namespace),
+		client: ed.client,
+		logger: ed.logger,
+		isView: true,
 	}
-	return strings.Replace(key, ed.namespace, "", 1)
+}
+
+func (ed *etcdDiscovery) ListPrefix(ctx context.Context, prefix string, opts ...ListOption) (*ListResult, error) {
+	o := listOptions{sortTarget: SortByKey, sortOrder: SortAscend}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	start := ed.keyPath(prefix)
+	end := etcdcliv3.GetPrefixRangeEnd(start)
+	byKey := o.sortTarget == SortByKey
+	if o.cursor != "" {
+		if !byKey {
+			return nil, ErrListCursorUnsupported
+		}
+		if o.sortOrder == SortDescend {
+			end = ed.keyPath(o.cursor)
+		} else {
+			start = ed.keyPath(o.cursor)
+		}
+	}
+
+	ops := []etcdcliv3.OpOption{
+		etcdcliv3.WithRange(end),
+		etcdcliv3.WithSort(etcdcliv3.SortTarget(o.sortTarget), etcdcliv3.SortOrder(o.sortOrder)),
+	}
+	if o.limit > 0 {
+		ops = append(ops, etcdcliv3.WithLimit(o.limit))
+	}
+	if o.keysOnly {
+		ops = append(ops, etcdcliv3.WithKeysOnly())
+	}
+	resp, err := ed.client.Get(ctx, start, ops...)
+	if err != nil {
+		return nil, fmt.Errorf("list prefix[%s] failure, error:%s", prefix, err)
+	}
+
+	result := &ListResult{More: resp.More, Count: resp.Count, Revision: resp.Header.Revision}
+	for _, kv := range resp.Kvs {
+		result.KVs = append(result.KVs, KeyValue{Key: ed.parseKey(string(kv.Key)), Value: kv.Value})
+	}
+	if resp.More && byKey && len(resp.Kvs) > 0 {
+		last := ed.parseKey(string(resp.Kvs[len(resp.Kvs)-1].Key))
+		if o.sortOrder == SortDescend {
+			result.Cursor = last
+		} else {
+			result.Cursor = last + "\x00"
+		}
+	}
+	return result, nil
 }
 
 func (ed *etcdDiscovery) Put(ctx context.Context, key string, val []byte) error {
@@ -126,6 +252,9 @@ func (ed *etcdDiscovery) Delete(ctx context.Context, key string) error {
 }
 
 func (ed *etcdDiscovery) Close() error {
+	if ed.isView {
+		return nil
+	}
 	return ed.client.Close()
 }
 
@@ -168,13 +297,13 @@ func (ed *etcdDiscovery) Elect(ctx context.Context, key string, value []byte, tt
 	return success, nil, nil
 }
 
-func (ed *etcdDiscovery) Watch(ctx context.Context, key string, fetchVal bool) WatchEventChan {
-	watcher := newWatcher(ctx, ed, ed.keyPath(key), fetchVal)
+func (ed *etcdDiscovery) Watch(ctx context.Context, key string, fetchVal bool, opts ...WatchOption) WatchEventChan {
+	watcher := newWatcher(ctx, ed, ed.keyPath(key), fetchVal, opts)
 	return watcher.EventC
 }
 
-func (ed *etcdDiscovery) WatchPrefix(ctx context.Context, prefixKey string, fetchVal bool) WatchEventChan {
-	watcher := newWatcher(ctx, ed, ed.keyPath(prefixKey), fetchVal, etcdcliv3.WithPrefix())
+func (ed *etcdDiscovery) WatchPrefix(ctx context.Context, prefixKey string, fetchVal bool, opts ...WatchOption) WatchEventChan {
+	watcher := newWatcher(ctx, ed, ed.keyPath(prefixKey), fetchVal, opts, etcdcliv3.WithPrefix())
 	return watcher.EventC
 }
 
@@ -203,14 +332,47 @@ func (ed *etcdDiscovery) Commit(ctx context.Context, txn Transaction) error {
 	if !ok {
 		return ErrTxnConvert
 	}
-	resp, err := ed.client.Txn(ctx).If(t.cmps...).Then(t.ops...).Commit()
+	resp, err := ed.client.Txn(ctx).If(t.cmps...).Then(t.thenOps...).Else(t.elseOps...).Commit()
 	return TxnErr(resp, err)
 }
 
+func (ed *etcdDiscovery) CommitWithResult(ctx context.Context, txn Transaction) (*TxnResult, error) {
+	t, ok := txn.(*transaction)
+	if !ok {
+		return nil, ErrTxnConvert
+	}
+	resp, err := ed.client.Txn(ctx).If(t.cmps...).Then(t.thenOps...).Else(t.elseOps...).Commit()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	result := &TxnResult{Succeeded: resp.Succeeded, Revision: resp.Header.Revision}
+	ops := t.thenOps
+	if !resp.Succeeded {
+		ops = t.elseOps
+	}
+	for i, op := range ops {
+		if !op.IsGet() || i >= len(resp.Responses) {
+			continue
+		}
+		get := TxnGetResult{Key: ed.parseKey(string(op.KeyBytes()))}
+		if rangeResp := resp.Responses[i].GetResponseRange(); rangeResp != nil && len(rangeResp.Kvs) > 0 {
+			kv := rangeResp.Kvs[0]
+			get.Exists = true
+			get.Value = kv.Value
+			get.ModRevision = kv.ModRevision
+			get.CreateRevision = kv.CreateRevision
+			get.Version = kv.Version
+		}
+		result.Gets = append(result.Gets, get)
+	}
+	return result, nil
+}
+
 type transaction struct {
-	ops  []etcdcliv3.Op
-	cmps []etcdcliv3.Cmp
-	ed   *etcdDiscovery
+	thenOps []etcdcliv3.Op
+	elseOps []etcdcliv3.Op
+	cmps    []etcdcliv3.Cmp
+	ed      *etcdDiscovery
 }
 
 func newTransaction(ed *etcdDiscovery) Transaction {
@@ -221,10 +383,38 @@ func (t *transaction) ModRevisionCmp(key, op string, v interface{}) {
 	t.cmps = append(t.cmps, etcdcliv3.Compare(etcdcliv3.ModRevision(t.ed.keyPath(key)), op, v))
 }
 
+func (t *transaction) CreateRevisionCmp(key, op string, v interface{}) {
+	t.cmps = append(t.cmps, etcdcliv3.Compare(etcdcliv3.CreateRevision(t.ed.keyPath(key)), op, v))
+}
+
+func (t *transaction) VersionCmp(key, op string, v interface{}) {
+	t.cmps = append(t.cmps, etcdcliv3.Compare(etcdcliv3.Version(t.ed.keyPath(key)), op, v))
+}
+
+func (t *transaction) ValueCmp(key, op string, value []byte) {
+	t.cmps = append(t.cmps, etcdcliv3.Compare(etcdcliv3.Value(t.ed.keyPath(key)), op, string(value)))
+}
+
 func (t *transaction) Put(key string, value []byte) {
-	t.ops = append(t.ops, etcdcliv3.OpPut(t.ed.keyPath(key), string(value)))
+	t.thenOps = append(t.thenOps, etcdcliv3.OpPut(t.ed.keyPath(key), string(value)))
 }
 
 func (t *transaction) Delete(key string) {
-	t.ops = append(t.ops, etcdcliv3.OpDelete(t.ed.keyPath(key)))
+	t.thenOps = append(t.thenOps, etcdcliv3.OpDelete(t.ed.keyPath(key)))
+}
+
+func (t *transaction) Get(key string) {
+	t.thenOps = append(t.thenOps, etcdcliv3.OpGet(t.ed.keyPath(key)))
+}
+
+func (t *transaction) ElsePut(key string, value []byte) {
+	t.elseOps = append(t.elseOps, etcdcliv3.OpPut(t.ed.keyPath(key), string(value)))
+}
+
+func (t *transaction) ElseDelete(key string) {
+	t.elseOps = append(t.elseOps, etcdcliv3.OpDelete(t.ed.keyPath(key)))
+}
+
+func (t *transaction) ElseGet(key string) {
+	t.elseOps = append(t.elseOps, etcdcliv3.OpGet(t.ed.keyPath(key)))
 }
@@ -18,15 +18,26 @@ package discovery
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"go.etcd.io/etcd/integration"
 
 	"github.com/kubeservice-stack/common/pkg/config"
+	"github.com/kubeservice-stack/common/pkg/utils"
 )
 
 type ETCDMockCluster struct {
@@ -73,6 +84,14 @@ func (s *EtcdClusterTestSuite) TestWriteRead() {
 	s.Nil(err1)
 	s.Equal(string(d1), "dongjiang")
 
+	d2, rev, err1 := ed.GetWithRevision(context.TODO(), "/test/key1")
+	s.Nil(err1)
+	s.Equal(string(d2), "dongjiang")
+	s.True(rev > 0)
+
+	_, _, err1 = ed.GetWithRevision(context.TODO(), "/test/not-exist")
+	s.Equal(ErrNotExist, err1)
+
 	err2 := ed.Delete(context.TODO(), "/test/key1")
 	s.Nil(err2)
 
@@ -108,6 +127,131 @@ func (s *EtcdClusterTestSuite) TestList() {
 	s.Nil(err3)
 }
 
+func (s *EtcdClusterTestSuite) TestListNamespaceText() {
+	ed, err := newEtedDiscovery(config.Discovery{
+		Namespace: "/app",
+		Endpoints: s.Cluster.Endpoints,
+	}, "nobody")
+	s.Nil(err)
+
+	s.Nil(ed.Put(context.TODO(), "/config/app/1", []byte("v1")))
+	list, err := ed.List(context.TODO(), "/config")
+	s.Nil(err)
+	s.Equal([]KeyValue{{Key: "/config/app/1", Value: []byte("v1")}}, list)
+
+	s.Nil(ed.Close())
+}
+
+func (s *EtcdClusterTestSuite) TestWithNamespace() {
+	ed, err := newEtedDiscovery(config.Discovery{
+		Namespace: "/app",
+		Endpoints: s.Cluster.Endpoints,
+	}, "nobody")
+	s.Nil(err)
+
+	ctx := context.TODO()
+	t1 := ed.WithNamespace("tenant1")
+	t2 := ed.WithNamespace("/tenant2")
+	s.Nil(t1.Put(ctx, "/key", []byte("t1")))
+	s.Nil(t2.Put(ctx, "/key", []byte("t2")))
+
+	v, err := t1.Get(ctx, "/key")
+	s.Nil(err)
+	s.Equal("t1", string(v))
+	v, err = t2.Get(ctx, "/key")
+	s.Nil(err)
+	s.Equal("t2", string(v))
+	v, err = ed.Get(ctx, "/tenant1/key")
+	s.Nil(err)
+	s.Equal("t1", string(v))
+
+	// 嵌套 namespace
+	v, err = ed.WithNamespace("tenant1").WithNamespace("").Get(ctx, "key")
+	s.Nil(err)
+	s.Equal("t1", string(v))
+
+	ctx2, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ch := t1.Watch(ctx2, "/key", true)
+	event := <-ch
+	s.Equal(EventTypeAll, event.Type)
+	s.Equal("/key", event.KeyValues[0].Key)
+
+	// 关闭视图不影响底层client
+	s.Nil(t1.Close())
+	v, err = t2.Get(ctx, "/key")
+	s.Nil(err)
+	s.Equal("t2", string(v))
+
+	s.Nil(ed.Close())
+}
+
+func (s *EtcdClusterTestSuite) TestListPrefix() {
+	ed, err := newEtedDiscovery(config.Discovery{
+		Namespace: "/test/page",
+		Endpoints: s.Cluster.Endpoints,
+	}, "nobody")
+	s.Nil(err)
+
+	ctx := context.TODO()
+	for i := 0; i < 5; i++ {
+		s.Nil(ed.Put(ctx, fmt.Sprintf("/data/%d", i), []byte(fmt.Sprintf("v%d", i))))
+	}
+	s.Nil(ed.Put(ctx, "/data2/0", []byte("other")))
+
+	// 按key升序分页
+	var keys []string
+	var opts []ListOption
+	for {
+		result, err := ed.ListPrefix(ctx, "/data/", append(opts, WithListLimit(2))...)
+		s.Nil(err)
+		for _, kv := range result.KVs {
+			keys = append(keys, kv.Key)
+		}
+		if !result.More {
+			s.Equal("", result.Cursor)
+			break
+		}
+		s.True(result.Revision > 0)
+		opts = []ListOption{WithListCursor(result.Cursor)}
+	}
+	s.Equal([]string{"/data/0", "/data/1", "/data/2", "/data/3", "/data/4"}, keys)
+
+	// 按key降序分页
+	keys = nil
+	opts = []ListOption{WithListSort(SortByKey, SortDescend)}
+	for {
+		result, err := ed.ListPrefix(ctx, "/data/", append(opts, WithListLimit(3))...)
+		s.Nil(err)
+		for _, kv := range result.KVs {
+			keys = append(keys, kv.Key)
+		}
+		if !result.More {
+			break
+		}
+		opts = []ListOption{WithListSort(SortByKey, SortDescend), WithListCursor(result.Cursor)}
+	}
+	s.Equal([]string{"/data/4", "/data/3", "/data/2", "/data/1", "/data/0"}, keys)
+
+	// keys only
+	result, err := ed.ListPrefix(ctx, "/data", WithListKeysOnly(), WithListLimit(1))
+	s.Nil(err)
+	s.Equal(int64(6), result.Count)
+	s.True(result.More)
+	s.Equal("/data/0", result.KVs[0].Key)
+	s.Nil(result.KVs[0].Value)
+
+	// 按 mod revision 降序
+	result, err = ed.ListPrefix(ctx, "/data/", WithListSort(SortByModRevision, SortDescend), WithListLimit(1))
+	s.Nil(err)
+	s.Equal("/data/4", result.KVs[0].Key)
+	s.Equal("", result.Cursor)
+	_, err = ed.ListPrefix(ctx, "/data/", WithListSort(SortByModRevision, SortDescend), WithListCursor("/data/1"))
+	s.Equal(ErrListCursorUnsupported, err)
+
+	s.Nil(ed.Close())
+}
+
 func (s *EtcdClusterTestSuite) TestNewDiscovery() {
 	_, err := newEtedDiscovery(config.Discovery{}, "nobody")
 	s.NotNil(err)
@@ -293,6 +437,91 @@ func (s *EtcdClusterTestSuite) TestGetWatchPrefix() {
 
 }
 
+func (s *EtcdClusterTestSuite) TestWatchFromRevision() {
+	ed, err := newEtedDiscovery(config.Discovery{
+		Namespace: "/test/revision",
+		Endpoints: s.Cluster.Endpoints,
+	}, "nobody")
+	s.Nil(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Nil(ed.Put(ctx, "/data/1", []byte("v1")))
+	resp, err := ed.(*etcdDiscovery).client.Get(ctx, "/test/revision/data/1")
+	s.Nil(err)
+	rev := resp.Kvs[0].ModRevision
+	s.Nil(ed.Put(ctx, "/data/2", []byte("v2")))
+
+	// 从 rev+1 开始 watch, 只收到之后的变更, 没有全量快照
+	ch := ed.WatchPrefix(ctx, "/data", true, WithRevision(rev+1))
+	select {
+	case event := <-ch:
+		s.Nil(event.Err)
+		s.Equal(EventTypeModify, event.Type)
+		s.Equal("/data/2", event.KeyValues[0].Key)
+		s.Equal("v2", string(event.KeyValues[0].Value))
+	case <-time.After(3 * time.Second):
+		s.Fail("watch from revision timeout")
+	}
+
+	s.Nil(ed.Close())
+}
+
+func (s *EtcdClusterTestSuite) TestWatchCompacted() {
+	ed, err := newEtedDiscovery(config.Discovery{
+		Namespace: "/test/compact",
+		Endpoints: s.Cluster.Endpoints,
+	}, "nobody")
+	s.Nil(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Nil(ed.Put(ctx, "/data/1", []byte("v1")))
+	resp, err := ed.(*etcdDiscovery).client.Get(ctx, "/test/compact/data/1")
+	s.Nil(err)
+	rev := resp.Kvs[0].ModRevision
+	s.Nil(ed.Put(ctx, "/data/1", []byte("v2")))
+	s.Nil(ed.Put(ctx, "/data/2", []byte("v3")))
+
+	_, err = ed.(*etcdDiscovery).client.Compact(ctx, rev+2)
+	s.Nil(err)
+
+	// rev 已经被 compact, 先收到错误, 然后自动重新推送全量快照
+	ch := ed.WatchPrefix(ctx, "/data", true, WithRevision(rev), WithWatchName("compact"),
+		WithRetryBackoff(10*time.Millisecond, 100*time.Millisecond, 0))
+	var gotErr bool
+	for {
+		select {
+		case event := <-ch:
+			if event.Err != nil {
+				gotErr = true
+				continue
+			}
+			s.True(gotErr)
+			s.Equal(EventTypeAll, event.Type)
+			kvs := map[string]string{}
+			for _, kv := range event.KeyValues {
+				kvs[kv.Key] = string(kv.Value)
+			}
+			s.Equal(map[string]string{"/data/1": "v2", "/data/2": "v3"}, kvs)
+
+			s.Nil(ed.Put(ctx, "/data/3", []byte("v4")))
+			event = <-ch
+			s.Nil(event.Err)
+			s.Equal(EventTypeModify, event.Type)
+			s.Equal("/data/3", event.KeyValues[0].Key)
+
+			s.Nil(ed.Close())
+			return
+		case <-time.After(3 * time.Second):
+			s.Fail("watch compacted resync timeout")
+			return
+		}
+	}
+}
+
 func (s *EtcdClusterTestSuite) TestTransaction() {
 	ed, err := newEtedDiscovery(config.Discovery{
 		Namespace: "/test/batch",
@@ -343,6 +572,70 @@ func (s *EtcdClusterTestSuite) TestTransaction() {
 	s.Nil(err3)
 }
 
+func (s *EtcdClusterTestSuite) TestTransactionResult() {
+	ed, err := newEtedDiscovery(config.Discovery{
+		Namespace: "/test/txn",
+		Endpoints: s.Cluster.Endpoints,
+	}, "nobody")
+	s.Nil(err)
+
+	ctx := context.TODO()
+	s.Nil(ed.Put(ctx, "/cfg", []byte("v1")))
+
+	// CAS: value 匹配时更新, 并读取新值
+	txn := ed.NewTransaction()
+	txn.ValueCmp("/cfg", "=", []byte("v1"))
+	txn.VersionCmp("/cfg", "=", 1)
+	txn.CreateRevisionCmp("/cfg", ">", 0)
+	txn.Put("/cfg", []byte("v2"))
+	txn.Get("/cfg")
+	txn.Get("/not-exist")
+	txn.ElseGet("/cfg")
+	result, err := ed.CommitWithResult(ctx, txn)
+	s.Nil(err)
+	s.True(result.Succeeded)
+	s.True(result.Revision > 0)
+	s.Equal(2, len(result.Gets))
+	get, ok := result.Get("/cfg")
+	s.True(ok)
+	s.True(get.Exists)
+	s.Equal("v2", string(get.Value))
+	s.Equal(int64(2), get.Version)
+	s.Equal(result.Revision, get.ModRevision)
+	s.True(get.CreateRevision < get.ModRevision)
+	get, ok = result.Get("/not-exist")
+	s.True(ok)
+	s.False(get.Exists)
+	_, ok = result.Get("/other")
+	s.False(ok)
+
+	// CAS 失败时执行 Else 分支, 返回当前值
+	txn = ed.NewTransaction()
+	txn.ValueCmp("/cfg", "=", []byte("v1"))
+	txn.Put("/cfg", []byte("v3"))
+	txn.ElseGet("/cfg")
+	txn.ElsePut("/conflict", []byte("1"))
+	result, err = ed.CommitWithResult(ctx, txn)
+	s.Nil(err)
+	s.False(result.Succeeded)
+	get, ok = result.Get("/cfg")
+	s.True(ok)
+	s.Equal("v2", string(get.Value))
+	v, err := ed.Get(ctx, "/conflict")
+	s.Nil(err)
+	s.Equal("1", string(v))
+
+	// Commit 条件不满足时返回 ErrTxnFailed
+	txn = ed.NewTransaction()
+	txn.VersionCmp("/cfg", "=", 0)
+	txn.ElseDelete("/conflict")
+	s.Equal(ErrTxnFailed, ed.Commit(ctx, txn))
+	_, err = ed.Get(ctx, "/conflict")
+	s.Equal(ErrNotExist, err)
+
+	s.Nil(ed.Close())
+}
+
 func (s *EtcdClusterTestSuite) TestBatch() {
 	ed, err := newEtedDiscovery(config.Discovery{
 		Namespace: "/test/batch",
@@ -425,6 +718,101 @@ func (s *EtcdClusterTestSuite) TestElect() {
 	s.Nil(err3)
 }
 
+func writeTestCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "etcd"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewEtcdConfig(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir)
+
+	cf, err := newEtcdConfig(config.Discovery{
+		Endpoints:        []string{"127.0.0.1:2379"},
+		DialTimeout:      utils.Duration(3 * time.Second),
+		KeepAliveTime:    utils.Duration(30 * time.Second),
+		KeepAliveTimeout: utils.Duration(10 * time.Second),
+		Username:         "root",
+		Password:         "secret",
+	})
+	assert.Nil(err)
+	assert.Equal(3*time.Second, cf.DialTimeout)
+	assert.Equal(30*time.Second, cf.DialKeepAliveTime)
+	assert.Equal(10*time.Second, cf.DialKeepAliveTimeout)
+	assert.Equal("root", cf.Username)
+	assert.Equal("secret", cf.Password)
+	assert.Nil(cf.TLS)
+
+	cf, err = newEtcdConfig(config.Discovery{
+		Endpoints:     []string{"127.0.0.1:2379"},
+		TLSCertFile:   certFile,
+		TLSKeyFile:    keyFile,
+		TLSCAFile:     certFile,
+		TLSServerName: "etcd",
+	})
+	assert.Nil(err)
+	assert.NotNil(cf.TLS)
+	assert.Equal(1, len(cf.TLS.Certificates))
+	assert.NotNil(cf.TLS.RootCAs)
+	assert.Equal("etcd", cf.TLS.ServerName)
+
+	_, err = newEtcdConfig(config.Discovery{})
+	assert.ErrorIs(err, config.ErrDiscoveryEndpointsEmpty)
+
+	_, err = newEtcdConfig(config.Discovery{
+		Endpoints:   []string{"127.0.0.1:2379"},
+		TLSCertFile: certFile,
+	})
+	assert.ErrorIs(err, config.ErrDiscoveryTLSKeyPair)
+
+	_, err = newEtcdConfig(config.Discovery{
+		Endpoints:   []string{"127.0.0.1:2379"},
+		TLSCertFile: filepath.Join(dir, "not-exist.crt"),
+		TLSKeyFile:  keyFile,
+	})
+	assert.NotNil(err)
+
+	_, err = newEtcdConfig(config.Discovery{
+		Endpoints: []string{"127.0.0.1:2379"},
+		TLSCAFile: keyFile,
+	})
+	assert.NotNil(err)
+
+	_, err = newEtedDiscovery(config.Discovery{
+		Endpoints: []string{"127.0.0.1:2379"},
+		TLSCAFile: filepath.Join(dir, "not-exist.crt"),
+	}, "nobody")
+	assert.NotNil(err)
+}
+
 // go test 入口
 func TestEtcdClusterTestSuite(t *testing.T) {
 	suite.Run(t, new(EtcdClusterTestSuite))
@@ -37,6 +37,7 @@ type heartbeat struct {
 	value  []byte
 
 	keepaliveCh <-chan *etcd.LeaseKeepAliveResponse
+	leaseID     etcd.LeaseID
 	isElect     bool
 
 	ttl    int64
@@ -78,13 +79,37 @@ func (h *heartbeat) grantKeepAliveLease(ctx context.Context) (bool, error) {
 		return false, err
 	}
 	response.Responses[0].GetResponse()
-	if response.Succeeded {
-		h.keepaliveCh, err = h.client.KeepAlive(ctx, resp.ID)
+	if !response.Succeeded {
+		// 选举失败, 回收租约, 避免重试时租约堆积到过期
+		if _, e := h.client.Revoke(ctx, resp.ID); e != nil {
+			h.logger.Warn("revoke lease error", logger.Error(e), logger.String("key", h.key))
+		}
+		return false, nil
+	}
+	h.leaseID = resp.ID
+	h.keepaliveCh, err = h.client.KeepAlive(ctx, resp.ID)
+	return true, err
+}
+
+// revoke 回收当前租约, 租约上的key立即删除, 不用等到租约过期
+func (h *heartbeat) revoke() {
+	if h.leaseID == etcd.NoLease {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(h.ttl)*time.Second)
+	defer cancel()
+	if _, err := h.client.Revoke(ctx, h.leaseID); err != nil {
+		h.logger.Warn("revoke lease error", logger.Error(err), logger.String("key", h.key))
 	}
-	return response.Succeeded, err
 }
 
 func (h *heartbeat) keepAlive(ctx context.Context) {
+	defer func() {
+		// 主动停止心跳时回收租约
+		if ctx.Err() != nil {
+			h.revoke()
+		}
+	}()
 	var (
 		err error
 		gap = 100 * time.Millisecond //时间间隔
@@ -24,7 +24,8 @@ import (
 )
 
 var (
-	ErrNotExist = fmt.Errorf("discovery is not exist")
+	ErrNotExist              = fmt.Errorf("discovery is not exist")
+	ErrListCursorUnsupported = fmt.Errorf("discovery: list cursor only supports sort by key")
 )
 
 type DiscoveryFactory interface {
@@ -33,18 +34,22 @@ type DiscoveryFactory interface {
 
 type Discovery interface {
 	// 从service center中获得数据
-	Get(ctx context.Context, key string) ([]byte, error)                                         // 根据key获得value
-	List(ctx context.Context, prefix string) ([]KeyValue, error)                                 // 根据前缀获得数据
-	Put(ctx context.Context, key string, val []byte) error                                       // key-value写入， 为了控制面使用
-	Delete(ctx context.Context, key string) error                                                // 删除key，为了控制面使用
-	Heartbeat(ctx context.Context, key string, value []byte, ttl int64) (<-chan Closed, error)   // endponit 健康检查
-	Elect(ctx context.Context, key string, value []byte, ttl int64) (bool, <-chan Closed, error) // 选举写入： key 不存在，写入成功，返回成功；key存在，写入失败，返回error
-	Watch(ctx context.Context, key string, fetchVal bool) WatchEventChan                         // watch key
-	WatchPrefix(ctx context.Context, prefixKey string, fetchVal bool) WatchEventChan             // watch 前缀key
-	Batch(ctx context.Context, batch Batch) (bool, error)                                        // 批写入
-	NewTransaction() Transaction                                                                 // 新transaction
-	Commit(ctx context.Context, txn Transaction) error                                           // commit
-	Close() error                                                                                // close discovery
+	Get(ctx context.Context, key string) ([]byte, error)                                                  // 根据key获得value
+	GetWithRevision(ctx context.Context, key string) ([]byte, int64, error)                               // 根据key获得value和mod revision
+	List(ctx context.Context, prefix string) ([]KeyValue, error)                                          // 根据前缀获得数据, 不分页; Deprecated: 使用ListPrefix
+	ListPrefix(ctx context.Context, prefix string, opts ...ListOption) (*ListResult, error)               // 根据前缀分页获得数据
+	Put(ctx context.Context, key string, val []byte) error                                                // key-value写入， 为了控制面使用
+	Delete(ctx context.Context, key string) error                                                         // 删除key，为了控制面使用
+	Heartbeat(ctx context.Context, key string, value []byte, ttl int64) (<-chan Closed, error)            // endponit 健康检查
+	Elect(ctx context.Context, key string, value []byte, ttl int64) (bool, <-chan Closed, error)          // 选举写入： key 不存在，写入成功，返回成功；key存在，写入失败，返回error
+	Watch(ctx context.Context, key string, fetchVal bool, opts ...WatchOption) WatchEventChan             // watch key
+	WatchPrefix(ctx context.Context, prefixKey string, fetchVal bool, opts ...WatchOption) WatchEventChan // watch 前缀key
+	Batch(ctx context.Context, batch Batch) (bool, error)                                                 // 批写入
+	NewTransaction() Transaction                                                                          // 新transaction
+	Commit(ctx context.Context, txn Transaction) error                                                    // commit, 条件不满足时返回 ErrTxnFailed
+	CommitWithResult(ctx context.Context, txn Transaction) (*TxnResult, error)                            // commit, 返回执行分支和Get结果
+	WithNamespace(namespace string) Discovery                                                             // 共享client的namespace视图
+	Close() error                                                                                         // close discovery
 }
 
 type EventType int
@@ -74,6 +79,75 @@ type KeyValue struct {
 	Value []byte
 }
 
+// 排序字段, 取值与etcd保持一致
+type SortTarget int
+
+const (
+	SortByKey SortTarget = iota
+	SortByVersion
+	SortByCreateRevision
+	SortByModRevision
+	SortByValue
+)
+
+// 排序方式, 取值与etcd保持一致
+type SortOrder int
+
+const (
+	SortNone SortOrder = iota
+	SortAscend
+	SortDescend
+)
+
+// ListPrefix 可选参数
+type ListOption func(*listOptions)
+
+type listOptions struct {
+	limit      int64
+	keysOnly   bool
+	sortTarget SortTarget
+	sortOrder  SortOrder
+	cursor     string
+}
+
+// WithListLimit 每页最多返回的数量, <=0 表示不限制
+func WithListLimit(limit int64) ListOption {
+	return func(o *listOptions) {
+		o.limit = limit
+	}
+}
+
+// WithListKeysOnly 只返回key
+func WithListKeysOnly() ListOption {
+	return func(o *listOptions) {
+		o.keysOnly = true
+	}
+}
+
+// WithListSort 设置排序, 默认按key升序
+func WithListSort(target SortTarget, order SortOrder) ListOption {
+	return func(o *listOptions) {
+		o.sortTarget = target
+		o.sortOrder = order
+	}
+}
+
+// WithListCursor 从上一页返回的 ListResult.Cursor 继续获取, 只支持按key排序
+func WithListCursor(cursor string) ListOption {
+	return func(o *listOptions) {
+		o.cursor = cursor
+	}
+}
+
+// 分页获取结果
+type ListResult struct {
+	KVs      []KeyValue
+	More     bool   // 是否还有更多数据
+	Count    int64  // 本次请求范围内的数据总数
+	Cursor   string // 下一页游标, 没有更多数据或者不按key排序时为空
+	Revision int64  // 读取时的revision
+}
+
 // 批写入数据
 type Batch struct {
 	KVs []KeyValue
@@ -111,8 +185,44 @@ func (df *discoveryFactory) CreateDiscovery(cfg config.Discovery) (Discovery, er
 	return newEtedDiscovery(cfg, df.owner)
 }
 
+// 事务: If(所有Cmp) Then(Put/Delete/Get) Else(ElsePut/ElseDelete/ElseGet)
+// op 取值 "=", "!=", "<", ">"
 type Transaction interface {
-	ModRevisionCmp(key, op string, v interface{})
+	ModRevisionCmp(key, op string, v interface{})    // 比较 mod revision, 0 表示 key 不存在
+	CreateRevisionCmp(key, op string, v interface{}) // 比较 create revision, 0 表示 key 不存在
+	VersionCmp(key, op string, v interface{})        // 比较 version(修改次数), 0 表示 key 不存在
+	ValueCmp(key, op string, value []byte)           // 比较 value
 	Put(key string, value []byte)
 	Delete(key string)
+	Get(key string)
+	ElsePut(key string, value []byte)
+	ElseDelete(key string)
+	ElseGet(key string)
+}
+
+// 事务执行结果
+type TxnResult struct {
+	Succeeded bool           // 是否执行了 Then 分支
+	Revision  int64          // 事务执行后的revision
+	Gets      []TxnGetResult // 执行分支中 Get 的结果, 按添加顺序
+}
+
+// 事务中 Get 的结果
+type TxnGetResult struct {
+	Key            string
+	Value          []byte
+	Exists         bool
+	ModRevision    int64
+	CreateRevision int64
+	Version        int64
+}
+
+// Get 返回 key 第一次 Get 的结果
+func (r *TxnResult) Get(key string) (TxnGetResult, bool) {
+	for _, get := range r.Gets {
+		if get.Key == key {
+			return get, true
+		}
+	}
+	return TxnGetResult{}, false
 }
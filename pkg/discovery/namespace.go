@@ -0,0 +1,73 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"strings"
+)
+
+const keySeparator = "/"
+
+// keyCodec 严格的 namespace 前缀编解码, 不对 key 做任何路径规范化(不处理 ".."、不去掉末尾的"/").
+//
+//	namespace "/app", key "/a/b"  => "/app/a/b"
+//	namespace "/app", key "a/b"   => "/app/a/b"
+//	namespace "/app", key "/a/"   => "/app/a/"
+//
+// 解码时只去掉开头的 namespace 前缀, 返回的 key 总是以"/"开头
+type keyCodec struct {
+	prefix string
+}
+
+func newKeyCodec(namespace string) keyCodec {
+	return keyCodec{prefix: strings.TrimRight(namespace, keySeparator)}
+}
+
+// Namespace 返回 namespace 前缀
+func (c keyCodec) Namespace() string {
+	return c.prefix
+}
+
+// Encode 为 key 加上 namespace 前缀
+func (c keyCodec) Encode(key string) string {
+	if c.prefix == "" {
+		return key
+	}
+	if strings.HasPrefix(key, keySeparator) {
+		return c.prefix + key
+	}
+	return c.prefix + keySeparator + key
+}
+
+// Decode 去掉 namespace 前缀, 不属于该 namespace 的 key 返回 false
+func (c keyCodec) Decode(key string) (string, bool) {
+	if c.prefix == "" {
+		return key, true
+	}
+	if !strings.HasPrefix(key, c.prefix+keySeparator) {
+		return key, false
+	}
+	return key[len(c.prefix):], true
+}
+
+// Sub 返回嵌套的 namespace
+func (c keyCodec) Sub(namespace string) keyCodec {
+	if namespace == "" {
+		return c
+	}
+	return newKeyCodec(c.Encode(namespace))
+}
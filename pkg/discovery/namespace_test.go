@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyCodec(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newKeyCodec("")
+	assert.Equal("/a/../b/", c.Encode("/a/../b/"))
+	k, ok := c.Decode("/a/b")
+	assert.True(ok)
+	assert.Equal("/a/b", k)
+
+	c = newKeyCodec("/app/")
+	assert.Equal("/app", c.Namespace())
+	assert.Equal("/app/a/b", c.Encode("/a/b"))
+	assert.Equal("/app/a/b", c.Encode("a/b"))
+	// 不做路径规范化
+	assert.Equal("/app/a/../b", c.Encode("/a/../b"))
+	assert.Equal("/app/a/", c.Encode("/a/"))
+	assert.Equal("/app/", c.Encode(""))
+
+	// key 中包含 namespace 文本时不会被破坏
+	k, ok = c.Decode("/app/config/app/1")
+	assert.True(ok)
+	assert.Equal("/config/app/1", k)
+
+	// 不属于该 namespace
+	k, ok = c.Decode("/application/1")
+	assert.False(ok)
+	assert.Equal("/application/1", k)
+
+	sub := c.Sub("tenant1")
+	assert.Equal("/app/tenant1", sub.Namespace())
+	assert.Equal("/app/tenant1/a", sub.Encode("/a"))
+	assert.Equal(c, c.Sub(""))
+	assert.Equal("/tenant1", newKeyCodec("").Sub("/tenant1/").Namespace())
+}
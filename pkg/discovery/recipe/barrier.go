@@ -0,0 +1,154 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recipe
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kubeservice-stack/common/pkg/discovery"
+)
+
+// DoubleBarrier 分布式双屏障: count个参与者全部 Enter 后才能继续执行, 全部 Leave 后才能退出.
+// 同一个屏障key在所有参与者 Leave 之前不能开始下一轮
+type DoubleBarrier struct {
+	ds      discovery.Discovery
+	key     string
+	id      string
+	count   int
+	ttl     int64
+	waiters string
+	ready   string
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// NewDoubleBarrier 创建双屏障, id 在参与者之间唯一, ttl 为参与者异常退出后自动清理的时间(秒)
+func NewDoubleBarrier(ds discovery.Discovery, key, id string, count int, ttl int64) *DoubleBarrier {
+	return &DoubleBarrier{
+		ds:      ds,
+		key:     key,
+		id:      id,
+		count:   count,
+		ttl:     ttl,
+		waiters: key + "/waiters/",
+		ready:   key + "/ready",
+	}
+}
+
+func (b *DoubleBarrier) self() string {
+	return b.waiters + b.id
+}
+
+// Enter 等待所有参与者到达, 失败时停止心跳并回收租约, 可以重新 Enter
+func (b *DoubleBarrier) Enter(ctx context.Context) (err error) {
+	if b.count <= 0 {
+		return ErrBarrierCountZero
+	}
+	b.mu.Lock()
+	if b.cancel != nil {
+		b.mu.Unlock()
+		return ErrBarrierEntered
+	}
+	hbCtx, cancel := context.WithCancel(context.Background())
+	closed, err := b.ds.Heartbeat(hbCtx, b.self(), []byte(b.id), b.ttl)
+	if err != nil {
+		cancel()
+		b.mu.Unlock()
+		return err
+	}
+	b.cancel = cancel
+	b.mu.Unlock()
+	defer func() {
+		if err != nil {
+			b.abort(closed)
+		}
+	}()
+
+	result, err := b.ds.ListPrefix(ctx, b.waiters, discovery.WithListLimit(1), discovery.WithListKeysOnly())
+	if err != nil {
+		return err
+	}
+	if result.Count >= int64(b.count) {
+		if err := b.ds.Put(ctx, b.ready, []byte(b.id)); err != nil {
+			return err
+		}
+	}
+
+	wctx, wcancel := context.WithCancel(ctx)
+	defer wcancel()
+	// 租约丢失后自己不再计入参与者, 其他参与者可能永远等不齐, 停止等待
+	lost := make(chan struct{})
+	go func() {
+		select {
+		case <-closed:
+			close(lost)
+			wcancel()
+		case <-wctx.Done():
+		}
+	}()
+	err = waitUntil(wctx, b.ds.Watch(wctx, b.ready, true), func(kvs map[string][]byte) bool {
+		return len(kvs) > 0
+	})
+	if err != nil {
+		select {
+		case <-lost:
+			return ErrBarrierLeaseLost
+		default:
+		}
+	}
+	return err
+}
+
+// abort 停止心跳, 等待租约回收后返回
+func (b *DoubleBarrier) abort(closed <-chan discovery.Closed) {
+	b.mu.Lock()
+	if b.cancel != nil {
+		b.cancel()
+		b.cancel = nil
+	}
+	b.mu.Unlock()
+	for range closed {
+	}
+}
+
+// Leave 等待所有参与者离开
+func (b *DoubleBarrier) Leave(ctx context.Context) error {
+	b.mu.Lock()
+	if b.cancel == nil {
+		b.mu.Unlock()
+		return ErrBarrierNotEntered
+	}
+	b.cancel()
+	b.cancel = nil
+	b.mu.Unlock()
+
+	if err := b.ds.Delete(ctx, b.self()); err != nil {
+		return err
+	}
+
+	wctx, wcancel := context.WithCancel(ctx)
+	defer wcancel()
+	if err := waitUntil(wctx, b.ds.WatchPrefix(wctx, b.waiters, true), func(kvs map[string][]byte) bool {
+		return len(kvs) == 0
+	}); err != nil {
+		return err
+	}
+	// 所有参与者已离开, 清理 ready 标记
+	return b.ds.Delete(ctx, b.ready)
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recipe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoubleBarrier(t *testing.T) {
+	assert := assert.New(t)
+	ds, stop := newTestDiscovery(t)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	const count = 3
+	var entered, left int32
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b := NewDoubleBarrier(ds, "/barrier", fmt.Sprintf("worker-%d", i), count, 5)
+			// 最后一个参与者延迟到达, 其他参与者必须等待
+			if i == count-1 {
+				time.Sleep(200 * time.Millisecond)
+				assert.Equal(int32(0), atomic.LoadInt32(&entered))
+			}
+			assert.Nil(b.Enter(ctx))
+			atomic.AddInt32(&entered, 1)
+			assert.Equal(ErrBarrierEntered, b.Enter(ctx))
+
+			if i == 0 {
+				time.Sleep(200 * time.Millisecond)
+				assert.Equal(int32(0), atomic.LoadInt32(&left))
+			}
+			assert.Nil(b.Leave(ctx))
+			atomic.AddInt32(&left, 1)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(int32(count), entered)
+	assert.Equal(int32(count), left)
+
+	kvs, err := ds.List(ctx, "/barrier")
+	assert.Nil(err)
+	assert.Equal(0, len(kvs))
+
+	b := NewDoubleBarrier(ds, "/barrier", "worker", count, 5)
+	assert.Equal(ErrBarrierNotEntered, b.Leave(ctx))
+	assert.Equal(ErrBarrierCountZero, NewDoubleBarrier(ds, "/barrier", "worker", 0, 5).Enter(ctx))
+}
+
+func TestDoubleBarrierLeaseLost(t *testing.T) {
+	assert := assert.New(t)
+	ds, cluster, stop := newTestCluster(t)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	b := NewDoubleBarrier(ds, "/barrier", "worker", 2, 5)
+	done := make(chan error)
+	go func() {
+		done <- b.Enter(ctx)
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	// 租约被回收, 等待中的参与者立即返回
+	cli := cluster.RandClient()
+	leases, err := cli.Leases(ctx)
+	assert.Nil(err)
+	for _, l := range leases.Leases {
+		_, err = cli.Revoke(ctx, l.ID)
+		assert.Nil(err)
+	}
+	select {
+	case err := <-done:
+		assert.Equal(ErrBarrierLeaseLost, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("enter not stopped after lease lost")
+	}
+	assert.Equal(ErrBarrierNotEntered, b.Leave(ctx))
+}
+
+func TestDoubleBarrierEnterFailed(t *testing.T) {
+	assert := assert.New(t)
+	ds, cluster, stop := newTestCluster(t)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 等待超时后心跳停止, 租约被回收, 参与者的key立即删除
+	b := NewDoubleBarrier(ds, "/barrier", "worker", 2, 60)
+	wctx, wcancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer wcancel()
+	assert.Equal(context.DeadlineExceeded, b.Enter(wctx))
+	kvs, err := ds.List(ctx, "/barrier/waiters")
+	assert.Nil(err)
+	assert.Equal(0, len(kvs))
+	leases, err := cluster.RandClient().Leases(ctx)
+	assert.Nil(err)
+	assert.Equal(0, len(leases.Leases))
+	assert.Equal(ErrBarrierNotEntered, b.Leave(ctx))
+
+	// 可以重新 Enter
+	other := NewDoubleBarrier(ds, "/barrier", "other", 2, 60)
+	done := make(chan error)
+	go func() {
+		done <- other.Enter(ctx)
+	}()
+	assert.Nil(b.Enter(ctx))
+	assert.Nil(<-done)
+}
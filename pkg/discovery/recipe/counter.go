@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recipe
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/kubeservice-stack/common/pkg/discovery"
+)
+
+// Counter 分布式原子计数器, 基于 ModRevisionCmp CAS 实现
+type Counter struct {
+	ds  discovery.Discovery
+	key string
+}
+
+func NewCounter(ds discovery.Discovery, key string) *Counter {
+	return &Counter{ds: ds, key: key}
+}
+
+// Get 返回当前值, key 不存在时返回0
+func (c *Counter) Get(ctx context.Context) (int64, error) {
+	val, _, err := c.get(ctx)
+	return val, err
+}
+
+func (c *Counter) get(ctx context.Context) (int64, int64, error) {
+	data, rev, err := c.ds.GetWithRevision(ctx, c.key)
+	if err == discovery.ErrNotExist {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	val, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return val, rev, nil
+}
+
+// Add 原子增加 delta, 返回增加后的值
+func (c *Counter) Add(ctx context.Context, delta int64) (int64, error) {
+	for {
+		val, rev, err := c.get(ctx)
+		if err != nil {
+			return 0, err
+		}
+		txn := c.ds.NewTransaction()
+		// rev为0时表示key不存在
+		txn.ModRevisionCmp(c.key, "=", rev)
+		txn.Put(c.key, []byte(strconv.FormatInt(val+delta, 10)))
+		err = c.ds.Commit(ctx, txn)
+		if err == nil {
+			return val + delta, nil
+		}
+		if err != discovery.ErrTxnFailed {
+			return 0, err
+		}
+		// 被其他实例修改, 重试
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+	}
+}
+
+func (c *Counter) Incr(ctx context.Context) (int64, error) {
+	return c.Add(ctx, 1)
+}
+
+func (c *Counter) Decr(ctx context.Context) (int64, error) {
+	return c.Add(ctx, -1)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recipe
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	assert := assert.New(t)
+	ds, stop := newTestDiscovery(t)
+	defer stop()
+
+	ctx := context.TODO()
+	c := NewCounter(ds, "/counter")
+	v, err := c.Get(ctx)
+	assert.Nil(err)
+	assert.Equal(int64(0), v)
+
+	v, err = c.Incr(ctx)
+	assert.Nil(err)
+	assert.Equal(int64(1), v)
+	v, err = c.Add(ctx, 10)
+	assert.Nil(err)
+	assert.Equal(int64(11), v)
+	v, err = c.Decr(ctx)
+	assert.Nil(err)
+	assert.Equal(int64(10), v)
+
+	// 并发CAS
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			other := NewCounter(ds, "/counter")
+			for j := 0; j < 10; j++ {
+				_, err := other.Incr(ctx)
+				assert.Nil(err)
+			}
+		}()
+	}
+	wg.Wait()
+	v, err = c.Get(ctx)
+	assert.Nil(err)
+	assert.Equal(int64(50), v)
+
+	assert.Nil(ds.Put(ctx, "/counter", []byte("not-a-number")))
+	_, err = c.Incr(ctx)
+	assert.NotNil(err)
+}
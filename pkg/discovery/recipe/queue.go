@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recipe
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/kubeservice-stack/common/pkg/discovery"
+)
+
+// Queue 分布式FIFO队列, 入队顺序由分布式计数器分配的序号决定
+type Queue struct {
+	ds     discovery.Discovery
+	prefix string
+	items  string
+	seq    *Counter
+}
+
+func NewQueue(ds discovery.Discovery, prefix string) *Queue {
+	return &Queue{
+		ds:     ds,
+		prefix: prefix,
+		items:  prefix + "/items/",
+		seq:    NewCounter(ds, prefix+"/seq"),
+	}
+}
+
+// Enqueue 入队, 序号递增和写入数据在同一个事务中完成, 保证数据按序号顺序可见
+func (q *Queue) Enqueue(ctx context.Context, val []byte) error {
+	for {
+		seq, rev, err := q.seq.get(ctx)
+		if err != nil {
+			return err
+		}
+		seq++
+		txn := q.ds.NewTransaction()
+		txn.ModRevisionCmp(q.seq.key, "=", rev)
+		txn.Put(q.seq.key, []byte(strconv.FormatInt(seq, 10)))
+		txn.Put(fmt.Sprintf("%s%020d", q.items, seq), val)
+		err = q.ds.Commit(ctx, txn)
+		if err == nil {
+			return nil
+		}
+		if err != discovery.ErrTxnFailed {
+			return err
+		}
+		// 序号被其他生产者占用, 重试
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// Len 返回队列长度
+func (q *Queue) Len(ctx context.Context) (int, error) {
+	result, err := q.ds.ListPrefix(ctx, q.items, discovery.WithListLimit(1), discovery.WithListKeysOnly())
+	if err != nil {
+		return 0, err
+	}
+	return int(result.Count), nil
+}
+
+// TryDequeue 出队, 队列为空时返回 discovery.ErrNotExist
+func (q *Queue) TryDequeue(ctx context.Context) ([]byte, error) {
+	for {
+		// 按key升序, 第一个即为队头
+		result, err := q.ds.ListPrefix(ctx, q.items, discovery.WithListLimit(1))
+		if err != nil {
+			return nil, err
+		}
+		if len(result.KVs) == 0 {
+			return nil, discovery.ErrNotExist
+		}
+		head := result.KVs[0]
+		txn := q.ds.NewTransaction()
+		txn.ModRevisionCmp(head.Key, ">", 0)
+		txn.Delete(head.Key)
+		err = q.ds.Commit(ctx, txn)
+		if err == nil {
+			return head.Value, nil
+		}
+		if err != discovery.ErrTxnFailed {
+			return nil, err
+		}
+		// 队头被其他消费者取走, 重试
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Dequeue 出队, 队列为空时阻塞直到有数据或者ctx结束
+func (q *Queue) Dequeue(ctx context.Context) ([]byte, error) {
+	for {
+		val, err := q.TryDequeue(ctx)
+		if err != discovery.ErrNotExist {
+			return val, err
+		}
+		if err := q.waitItems(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (q *Queue) waitItems(ctx context.Context) error {
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	return waitUntil(wctx, q.ds.WatchPrefix(wctx, q.items, true), func(kvs map[string][]byte) bool {
+		return len(kvs) > 0
+	})
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recipe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeservice-stack/common/pkg/discovery"
+)
+
+func TestQueue(t *testing.T) {
+	assert := assert.New(t)
+	ds, stop := newTestDiscovery(t)
+	defer stop()
+
+	ctx := context.TODO()
+	q := NewQueue(ds, "/queue")
+	_, err := q.TryDequeue(ctx)
+	assert.Equal(discovery.ErrNotExist, err)
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(q.Enqueue(ctx, []byte(fmt.Sprintf("item-%d", i))))
+	}
+	n, err := q.Len(ctx)
+	assert.Nil(err)
+	assert.Equal(3, n)
+
+	for i := 0; i < 3; i++ {
+		val, err := q.Dequeue(ctx)
+		assert.Nil(err)
+		assert.Equal(fmt.Sprintf("item-%d", i), string(val))
+	}
+
+	// 阻塞出队
+	done := make(chan []byte)
+	go func() {
+		val, err := q.Dequeue(ctx)
+		assert.Nil(err)
+		done <- val
+	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(NewQueue(ds, "/queue").Enqueue(ctx, []byte("late")))
+	select {
+	case val := <-done:
+		assert.Equal("late", string(val))
+	case <-time.After(3 * time.Second):
+		assert.Fail("dequeue timeout")
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = q.Dequeue(tctx)
+	assert.Equal(context.DeadlineExceeded, err)
+}
+
+func TestQueueConcurrentProducers(t *testing.T) {
+	assert := assert.New(t)
+	ds, stop := newTestDiscovery(t)
+	defer stop()
+
+	ctx := context.TODO()
+	const producers, items = 4, 10
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			q := NewQueue(ds, "/queue")
+			for i := 0; i < items; i++ {
+				assert.Nil(q.Enqueue(ctx, []byte(fmt.Sprintf("%d-%d", p, i))))
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	// 同一个生产者的数据按入队顺序出队, 序号连续没有空洞
+	q := NewQueue(ds, "/queue")
+	seq, err := q.seq.Get(ctx)
+	assert.Nil(err)
+	assert.Equal(int64(producers*items), seq)
+	next := make([]int, producers)
+	for n := 0; n < producers*items; n++ {
+		val, err := q.TryDequeue(ctx)
+		assert.Nil(err)
+		var p, i int
+		_, err = fmt.Sscanf(string(val), "%d-%d", &p, &i)
+		assert.Nil(err)
+		assert.Equal(next[p], i)
+		next[p]++
+	}
+	_, err = q.TryDequeue(ctx)
+	assert.Equal(discovery.ErrNotExist, err)
+}
+
+func TestQueueConcurrentConsumers(t *testing.T) {
+	assert := assert.New(t)
+	ds, stop := newTestDiscovery(t)
+	defer stop()
+
+	ctx := context.TODO()
+	q := NewQueue(ds, "/queue")
+	for i := 0; i < 20; i++ {
+		assert.Nil(q.Enqueue(ctx, []byte(fmt.Sprintf("%d", i))))
+	}
+
+	var mu sync.Mutex
+	got := map[string]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			consumer := NewQueue(ds, "/queue")
+			for {
+				val, err := consumer.TryDequeue(ctx)
+				if err == discovery.ErrNotExist {
+					return
+				}
+				assert.Nil(err)
+				mu.Lock()
+				got[string(val)]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(20, len(got))
+	for _, cnt := range got {
+		assert.Equal(1, cnt)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package recipe 基于 discovery.Discovery 事务和 watch 实现的分布式协调原语:
+// 原子计数器、FIFO队列、双屏障和信号量
+package recipe
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kubeservice-stack/common/pkg/discovery"
+)
+
+var (
+	ErrBarrierEntered     = fmt.Errorf("recipe: barrier already entered")
+	ErrBarrierNotEntered  = fmt.Errorf("recipe: barrier not entered")
+	ErrSemaphoreAcquired  = fmt.Errorf("recipe: semaphore already acquired")
+	ErrSemaphoreNotHeld   = fmt.Errorf("recipe: semaphore not held")
+	ErrSemaphoreSizeZero  = fmt.Errorf("recipe: semaphore size <= 0")
+	ErrBarrierCountZero   = fmt.Errorf("recipe: barrier count <= 0")
+	ErrBarrierLeaseLost   = fmt.Errorf("recipe: barrier lease lost")
+	ErrWatchChannelClosed = fmt.Errorf("recipe: watch channel closed")
+)
+
+// waitUntil watch 数据变更, 维护前缀下当前的 key-value 视图, 直到 cond 返回 true
+func waitUntil(ctx context.Context, ch discovery.WatchEventChan, cond func(kvs map[string][]byte) bool) error {
+	kvs := make(map[string][]byte)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-ch:
+			if !ok {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return ErrWatchChannelClosed
+			}
+			if event.Err != nil {
+				continue
+			}
+			if event.Type == discovery.EventTypeAll {
+				kvs = make(map[string][]byte)
+			}
+			for _, kv := range event.KeyValues {
+				if event.Type == discovery.EventTypeDelete {
+					delete(kvs, kv.Key)
+					continue
+				}
+				kvs[kv.Key] = kv.Value
+			}
+			if cond(kvs) {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recipe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/integration"
+
+	"github.com/kubeservice-stack/common/pkg/config"
+	"github.com/kubeservice-stack/common/pkg/discovery"
+)
+
+func newTestDiscovery(t *testing.T) (discovery.Discovery, func()) {
+	ds, _, stop := newTestCluster(t)
+	return ds, stop
+}
+
+// newTestCluster 同时返回 etcd 集群, 用于直接检查和操作租约
+func newTestCluster(t *testing.T) (discovery.Discovery, *integration.ClusterV3, func()) {
+	cluster := integration.NewClusterV3(t, &integration.ClusterConfig{Size: 1})
+	ds, err := discovery.NewDiscoveryFactory("nobody").CreateDiscovery(config.Discovery{
+		Namespace: "/test/recipe",
+		Endpoints: []string{cluster.Members[0].GRPCAddr()},
+	})
+	if err != nil {
+		cluster.Terminate(t)
+		t.Fatal(err)
+	}
+	return ds, cluster, func() {
+		_ = ds.Close()
+		cluster.Terminate(t)
+	}
+}
+
+func TestWaitUntil(t *testing.T) {
+	assert := assert.New(t)
+
+	ch := make(chan *discovery.Event, 4)
+	ch <- &discovery.Event{Type: discovery.EventTypeAll, KeyValues: []discovery.EventKeyValue{{Key: "a", Value: []byte("1")}}}
+	ch <- &discovery.Event{Err: context.Canceled}
+	ch <- &discovery.Event{Type: discovery.EventTypeModify, KeyValues: []discovery.EventKeyValue{{Key: "b", Value: []byte("2")}}}
+	ch <- &discovery.Event{Type: discovery.EventTypeDelete, KeyValues: []discovery.EventKeyValue{{Key: "a"}}}
+	close(ch)
+
+	var sizes []int
+	err := waitUntil(context.TODO(), ch, func(kvs map[string][]byte) bool {
+		sizes = append(sizes, len(kvs))
+		return false
+	})
+	assert.Equal(ErrWatchChannelClosed, err)
+	assert.Equal([]int{1, 2, 1}, sizes)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, waitUntil(ctx, make(chan *discovery.Event), func(map[string][]byte) bool { return true }))
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recipe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/kubeservice-stack/common/pkg/discovery"
+)
+
+// Semaphore 分布式信号量, 同一时刻最多 size 个持有者. 每个持有者占用一个带租约的槽位,
+// 进程异常退出后槽位在 ttl 秒后自动释放
+type Semaphore struct {
+	ds     discovery.Discovery
+	prefix string
+	id     string
+	size   int
+	ttl    int64
+
+	mu     sync.Mutex
+	slot   string
+	rev    int64
+	cancel context.CancelFunc
+}
+
+// NewSemaphore 创建信号量, id 在持有者之间唯一
+func NewSemaphore(ds discovery.Discovery, prefix, id string, size int, ttl int64) *Semaphore {
+	return &Semaphore{
+		ds:     ds,
+		prefix: prefix,
+		id:     id,
+		size:   size,
+		ttl:    ttl,
+	}
+}
+
+func (s *Semaphore) slotKey(i int) string {
+	return fmt.Sprintf("%s/%d", s.prefix, i)
+}
+
+// TryAcquire 尝试获取一个槽位, 没有空闲槽位时返回 false
+func (s *Semaphore) TryAcquire(ctx context.Context) (bool, error) {
+	if s.size <= 0 {
+		return false, ErrSemaphoreSizeZero
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return false, ErrSemaphoreAcquired
+	}
+
+	for i := 0; i < s.size; i++ {
+		key := s.slotKey(i)
+		hbCtx, cancel := context.WithCancel(context.Background())
+		ok, _, err := s.ds.Elect(hbCtx, key, []byte(s.id), s.ttl)
+		if err != nil {
+			cancel()
+			return false, err
+		}
+		if !ok {
+			cancel()
+			continue
+		}
+		_, rev, err := s.ds.GetWithRevision(ctx, key)
+		if err != nil {
+			cancel()
+			return false, err
+		}
+		s.slot, s.rev, s.cancel = key, rev, cancel
+		return true, nil
+	}
+	return false, nil
+}
+
+// Acquire 获取一个槽位, 没有空闲槽位时阻塞直到获取成功或者ctx结束
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	for {
+		ok, err := s.TryAcquire(ctx)
+		if err != nil || ok {
+			return err
+		}
+		wctx, cancel := context.WithCancel(ctx)
+		err = waitUntil(wctx, s.ds.WatchPrefix(wctx, s.prefix+"/", true), func(kvs map[string][]byte) bool {
+			return len(kvs) < s.size
+		})
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Release 释放持有的槽位
+func (s *Semaphore) Release(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel == nil {
+		return ErrSemaphoreNotHeld
+	}
+	s.cancel()
+	s.cancel = nil
+
+	// 只删除自己持有的槽位, 租约过期后槽位可能已经被其他持有者获取
+	txn := s.ds.NewTransaction()
+	txn.ModRevisionCmp(s.slot, "=", s.rev)
+	txn.Delete(s.slot)
+	err := s.ds.Commit(ctx, txn)
+	if err == discovery.ErrTxnFailed {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recipe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemaphore(t *testing.T) {
+	assert := assert.New(t)
+	ds, stop := newTestDiscovery(t)
+	defer stop()
+
+	ctx := context.TODO()
+	s1 := NewSemaphore(ds, "/sema", "s1", 2, 5)
+	s2 := NewSemaphore(ds, "/sema", "s2", 2, 5)
+	s3 := NewSemaphore(ds, "/sema", "s3", 2, 5)
+
+	ok, err := s1.TryAcquire(ctx)
+	assert.Nil(err)
+	assert.True(ok)
+	_, err = s1.TryAcquire(ctx)
+	assert.Equal(ErrSemaphoreAcquired, err)
+
+	ok, err = s2.TryAcquire(ctx)
+	assert.Nil(err)
+	assert.True(ok)
+
+	ok, err = s3.TryAcquire(ctx)
+	assert.Nil(err)
+	assert.False(ok)
+
+	done := make(chan struct{})
+	go func() {
+		assert.Nil(s3.Acquire(ctx))
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(s1.Release(ctx))
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		assert.Fail("acquire timeout")
+	}
+
+	assert.Equal(ErrSemaphoreNotHeld, s1.Release(ctx))
+	assert.Nil(s2.Release(ctx))
+	assert.Nil(s3.Release(ctx))
+
+	_, err = NewSemaphore(ds, "/sema", "s0", 0, 5).TryAcquire(ctx)
+	assert.Equal(ErrSemaphoreSizeZero, err)
+}
+
+func TestSemaphoreNoLeaseLeak(t *testing.T) {
+	assert := assert.New(t)
+	ds, cluster, stop := newTestCluster(t)
+	defer stop()
+
+	ctx := context.TODO()
+	s1 := NewSemaphore(ds, "/sema", "s1", 2, 60)
+	s2 := NewSemaphore(ds, "/sema", "s2", 2, 60)
+	s3 := NewSemaphore(ds, "/sema", "s3", 2, 60)
+	for _, s := range []*Semaphore{s1, s2} {
+		ok, err := s.TryAcquire(ctx)
+		assert.Nil(err)
+		assert.True(ok)
+	}
+	// 获取失败时不会留下租约
+	for i := 0; i < 5; i++ {
+		ok, err := s3.TryAcquire(ctx)
+		assert.Nil(err)
+		assert.False(ok)
+	}
+	leases, err := cluster.RandClient().Leases(ctx)
+	assert.Nil(err)
+	assert.Len(leases.Leases, 2)
+
+	assert.Nil(s1.Release(ctx))
+	assert.Nil(s2.Release(ctx))
+}
+
+func TestSemaphoreConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	ds, stop := newTestDiscovery(t)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	const size = 2
+	var holding, maxHolding int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s := NewSemaphore(ds, "/sema", fmt.Sprintf("s%d", i), size, 5)
+			assert.Nil(s.Acquire(ctx))
+			n := atomic.AddInt32(&holding, 1)
+			for {
+				m := atomic.LoadInt32(&maxHolding)
+				if n <= m || atomic.CompareAndSwapInt32(&maxHolding, m, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			atomic.AddInt32(&holding, -1)
+			assert.Nil(s.Release(ctx))
+		}(i)
+	}
+	wg.Wait()
+	assert.True(maxHolding <= size)
+	assert.True(maxHolding > 0)
+}
@@ -20,14 +20,79 @@ import (
 	"context"
 	"time"
 
+	"github.com/uber-go/tally"
 	etcdcliv3 "go.etcd.io/etcd/clientv3"
 	"go.etcd.io/etcd/mvcc/mvccpb"
+
+	"github.com/kubeservice-stack/common/pkg/logger"
+	"github.com/kubeservice-stack/common/pkg/metrics"
+	"github.com/kubeservice-stack/common/pkg/utils"
 )
 
 const (
-	defaultRetryInterval = 100 * time.Millisecond //默认watch周期100ms
+	defaultRetryInterval    = 100 * time.Millisecond //默认watch重试最小间隔100ms
+	defaultMaxRetryInterval = 10 * time.Second       //默认watch重试最大间隔10s
+	defaultRetryJitter      = 0.2                    //默认watch重试随机抖动比例
+	defaultWatchName        = "default"              //默认watch名称, 用作metrics标签
 )
 
+// WatchOption watch 可选参数
+type WatchOption func(*watchOptions)
+
+type watchOptions struct {
+	name        string
+	revision    int64
+	minRetry    time.Duration
+	maxRetry    time.Duration
+	retryJitter float64
+}
+
+// WithRevision 从指定的 revision 开始 watch (包含该 revision), 不再推送初始的 EventTypeAll 全量快照.
+// 如果 revision 已经被 compact, 会自动重新推送一次 EventTypeAll 全量快照.
+func WithRevision(rev int64) WatchOption {
+	return func(o *watchOptions) {
+		o.revision = rev
+	}
+}
+
+// WithWatchName 设置 watch 名称, 作为 metrics 的 watch 标签, 不设置时为 default.
+// 名称需要是有限的取值, 不要使用 key 本身
+func WithWatchName(name string) WatchOption {
+	return func(o *watchOptions) {
+		o.name = name
+	}
+}
+
+// WithRetryBackoff 设置 watch 断开重连的指数退避参数
+func WithRetryBackoff(min, max time.Duration, jitter float64) WatchOption {
+	return func(o *watchOptions) {
+		o.minRetry = min
+		o.maxRetry = max
+		o.retryJitter = jitter
+	}
+}
+
+// watch 相关 metrics
+type watchMetrics struct {
+	reconnects  tally.Counter
+	compactions tally.Counter
+	events      tally.Counter
+	revisionLag tally.Gauge
+}
+
+func newWatchMetrics(owner, name string) *watchMetrics {
+	scope := metrics.DefaultTallyScope.Scope.SubScope("discovery").Tagged(map[string]string{
+		"owner": owner,
+		"watch": name,
+	})
+	return &watchMetrics{
+		reconnects:  scope.Counter("watch_reconnects"),
+		compactions: scope.Counter("watch_compactions"),
+		events:      scope.Counter("watch_events"),
+		revisionLag: scope.Gauge("watch_revision_lag"),
+	}
+}
+
 type watcher struct {
 	ctx      context.Context
 	cli      *etcdDiscovery
@@ -35,10 +100,24 @@ type watcher struct {
 	fetchVal bool
 	opts     []etcdcliv3.OpOption
 
+	revision int64 // 下一个需要 watch 的 revision, <=0 表示需要全量快照
+	backoff  *utils.Backoff
+	metrics  *watchMetrics
+
 	EventC WatchEventChan
 }
 
-func newWatcher(ctx context.Context, cli *etcdDiscovery, key string, fetchVal bool, opts ...etcdcliv3.OpOption) *watcher {
+func newWatcher(ctx context.Context, cli *etcdDiscovery, key string, fetchVal bool, wopts []WatchOption, opts ...etcdcliv3.OpOption) *watcher {
+	o := &watchOptions{
+		name:        defaultWatchName,
+		minRetry:    defaultRetryInterval,
+		maxRetry:    defaultMaxRetryInterval,
+		retryJitter: defaultRetryJitter,
+	}
+	for _, opt := range wopts {
+		opt(o)
+	}
+
 	eventc := make(chan *Event)
 	w := &watcher{
 		ctx:      ctx,
@@ -46,6 +125,9 @@ func newWatcher(ctx context.Context, cli *etcdDiscovery, key string, fetchVal bo
 		key:      key,
 		opts:     opts,
 		fetchVal: fetchVal,
+		revision: o.revision,
+		backoff:  utils.NewBackoff(o.minRetry, o.maxRetry, 2, o.retryJitter),
+		metrics:  newWatchMetrics(cli.owner, o.name),
 
 		EventC: eventc,
 	}
@@ -56,52 +138,111 @@ func newWatcher(ctx context.Context, cli *etcdDiscovery, key string, fetchVal bo
 func (w *watcher) watch(eventCh chan<- *Event) {
 	defer close(eventCh)
 
-	cli := w.cli.client
-	var evtAll *Event
-	var resp *etcdcliv3.GetResponse
-	// etcdcliv3.Watch 可能会抛出ErrCompacted错误
 	for {
-		for {
-			var err error
-			if resp, err = cli.Get(w.ctx, w.key, w.opts...); err == nil {
-				evtAll = w.packAllEvents(resp.Kvs)
-				break
+		// 需要全量快照: 首次 watch 或者 revision 被 compact
+		if w.revision <= 0 {
+			evtAll, err := w.snapshot()
+			if err != nil {
+				if !w.retry(err) {
+					return
+				}
+				continue
 			}
-			select {
-			case <-w.ctx.Done():
+			if !w.send(eventCh, evtAll) {
 				return
-			case <-time.After(defaultRetryInterval):
 			}
 		}
-		select {
-		case <-w.ctx.Done():
+
+		err := w.watchFrom(eventCh)
+		if w.ctx.Err() != nil {
 			return
-		case eventCh <- evtAll:
 		}
-
-		opts := append(w.opts, etcdcliv3.WithRev(resp.Header.Revision+1))
-		wchc := cli.Watch(w.ctx, w.key, opts...)
-		if wchc == nil {
-			continue
+		if !w.retry(err) {
+			return
 		}
-		for watchResp := range wchc {
-			if err := watchResp.Err(); err != nil {
-				select {
-				case <-w.ctx.Done():
-					return
-				case eventCh <- &Event{Err: err}:
-				}
-				continue
+	}
+}
+
+// snapshot 获取全量数据, 并记录下一个需要 watch 的 revision
+func (w *watcher) snapshot() (*Event, error) {
+	resp, err := w.cli.client.Get(w.ctx, w.key, w.opts...)
+	if err != nil {
+		return nil, err
+	}
+	w.revision = resp.Header.Revision + 1
+	w.backoff.Reset()
+	return w.packAllEvents(resp.Kvs), nil
+}
+
+// watchFrom 从 w.revision 开始 watch, 直到 watch 断开
+func (w *watcher) watchFrom(eventCh chan<- *Event) error {
+	ctx, cancel := context.WithCancel(w.ctx)
+	defer cancel()
+
+	opts := append(append([]etcdcliv3.OpOption{}, w.opts...), etcdcliv3.WithRev(w.revision))
+	wchc := w.cli.client.Watch(ctx, w.key, opts...)
+	if wchc == nil {
+		return ErrWatchFailed
+	}
+	for watchResp := range wchc {
+		if err := watchResp.Err(); err != nil {
+			// 转发错误, 让调用方感知
+			if !w.send(eventCh, &Event{Err: err}) {
+				return nil
 			}
-			for _, event := range watchResp.Events {
-				select {
-				case <-w.ctx.Done():
-					return
-				case eventCh <- w.packWatchEvent(event):
-				}
+			if watchResp.CompactRevision != 0 {
+				// revision 已被 compact, 重新拉取全量快照
+				w.metrics.compactions.Inc(1)
+				w.cli.logger.Warn("watch revision has been compacted, resync",
+					logger.String("key", w.key),
+					logger.Int64("revision", w.revision),
+					logger.Int64("compactRevision", watchResp.CompactRevision))
+				w.revision = 0
+			}
+			return err
+		}
+		w.backoff.Reset()
+		for _, event := range watchResp.Events {
+			if !w.send(eventCh, w.packWatchEvent(event)) {
+				return nil
 			}
+			w.revision = event.Kv.ModRevision + 1
+			w.metrics.events.Inc(1)
+		}
+		if lag := watchResp.Header.Revision - w.revision + 1; lag >= 0 {
+			w.metrics.revisionLag.Update(float64(lag))
 		}
 	}
+	return ErrWatchFailed
+}
+
+// retry 按指数退避等待后重试, ctx 结束时返回 false
+func (w *watcher) retry(err error) bool {
+	w.metrics.reconnects.Inc(1)
+	d := w.backoff.Next()
+	if err != nil {
+		w.cli.logger.Warn("watch disconnected, retry",
+			logger.Error(err),
+			logger.String("key", w.key),
+			logger.Any("backoff", d))
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-w.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (w *watcher) send(eventCh chan<- *Event, evt *Event) bool {
+	select {
+	case <-w.ctx.Done():
+		return false
+	case eventCh <- evt:
+		return true
+	}
 }
 
 func (w *watcher) packWatchEvent(watchEvent *etcdcliv3.Event) *Event {
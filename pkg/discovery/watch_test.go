@@ -0,0 +1,51 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+
+	"github.com/kubeservice-stack/common/pkg/metrics"
+)
+
+func Test_WatchMetricsTags(t *testing.T) {
+	assert := assert.New(t)
+	scope := tally.NewTestScope("", nil)
+	old := metrics.DefaultTallyScope
+	metrics.DefaultTallyScope = &metrics.TallyScope{Scope: scope}
+	defer func() { metrics.DefaultTallyScope = old }()
+
+	o := &watchOptions{name: defaultWatchName}
+	WithWatchName("services")(o)
+	assert.Equal("services", o.name)
+
+	newWatchMetrics("nobody", o.name).events.Inc(1)
+	newWatchMetrics("nobody", defaultWatchName).events.Inc(1)
+
+	counters := scope.Snapshot().Counters()
+	assert.Len(counters, 6)
+	for _, c := range counters {
+		assert.NotContains(c.Tags(), "key")
+	}
+	_, ok := counters["discovery.watch_events+owner=nobody,watch=services"]
+	assert.True(ok)
+	_, ok = counters["discovery.watch_events+owner=nobody,watch=default"]
+	assert.True(ok)
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BlockingQueue 泛型有界阻塞队列, 满时 Put 阻塞, 空时 Take 阻塞.
+// 缓冲区按需扩容到 capacity, 突发写入消费完后缩容
+type BlockingQueue[T any] struct {
+	mu       sync.Mutex
+	content  *ring[T]
+	capacity int
+	closed   bool
+	notEmpty chan struct{} // 有数据时关闭并替换, 唤醒所有等待的消费者
+	notFull  chan struct{} // 有空位时关闭并替换, 唤醒所有等待的生产者
+	takers   int           // 等待中的消费者数
+	putters  int           // 等待中的生产者数
+}
+
+// NewBlockingQueue 创建最多容纳 capacity 个元素的阻塞队列
+func NewBlockingQueue[T any](capacity int) *BlockingQueue[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	minSize := capacity
+	if minSize > defaultBlockingQueueMinSize {
+		minSize = defaultBlockingQueueMinSize
+	}
+	return &BlockingQueue[T]{
+		content:  newRing[T](minSize),
+		capacity: capacity,
+		notEmpty: make(chan struct{}),
+		notFull:  make(chan struct{}),
+	}
+}
+
+// 阻塞队列缓冲区的初始大小和缩容下限
+const defaultBlockingQueueMinSize = 16
+
+// Put 放入元素, 队列满时阻塞直到有空位、ctx 结束或者队列关闭
+func (q *BlockingQueue[T]) Put(ctx context.Context, item T) error {
+	q.mu.Lock()
+	for {
+		if q.closed {
+			q.mu.Unlock()
+			return ErrQueueClosed
+		}
+		if q.content.size < q.capacity {
+			q.content.push(item)
+			q.signalNotEmpty()
+			q.mu.Unlock()
+			return nil
+		}
+		wait := q.notFull
+		q.putters++
+		q.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			q.mu.Lock()
+			q.putters--
+			q.mu.Unlock()
+			return ctx.Err()
+		}
+		q.mu.Lock()
+		q.putters--
+	}
+}
+
+// Take 取出元素, 队列空时阻塞直到有数据、ctx 结束或者队列关闭.
+// 队列关闭后仍可以取出剩余的元素, 取完后返回 ErrQueueClosed
+func (q *BlockingQueue[T]) Take(ctx context.Context) (T, error) {
+	q.mu.Lock()
+	for {
+		if item, ok := q.content.pop(); ok {
+			q.signalNotFull()
+			q.mu.Unlock()
+			return item, nil
+		}
+		if q.closed {
+			q.mu.Unlock()
+			var zero T
+			return zero, ErrQueueClosed
+		}
+		wait := q.notEmpty
+		q.takers++
+		q.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			q.mu.Lock()
+			q.takers--
+			q.mu.Unlock()
+			var zero T
+			return zero, ctx.Err()
+		}
+		q.mu.Lock()
+		q.takers--
+	}
+}
+
+// Offer 放入元素, 队列满时最多等待 timeout, 返回是否放入. timeout 小于等于0时不等待
+func (q *BlockingQueue[T]) Offer(item T, timeout time.Duration) bool {
+	ctx, cancel := timeoutContext(timeout)
+	defer cancel()
+	return q.Put(ctx, item) == nil
+}
+
+// Poll 取出元素, 队列空时最多等待 timeout, 返回是否取到. timeout 小于等于0时不等待
+func (q *BlockingQueue[T]) Poll(timeout time.Duration) (T, bool) {
+	ctx, cancel := timeoutContext(timeout)
+	defer cancel()
+	item, err := q.Take(ctx)
+	return item, err == nil
+}
+
+// Peek 返回队首元素, 不取出
+func (q *BlockingQueue[T]) Peek() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.content.peek()
+}
+
+// Len 当前元素数
+func (q *BlockingQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.content.size
+}
+
+// Cap 最多容纳的元素数
+func (q *BlockingQueue[T]) Cap() int {
+	return q.capacity
+}
+
+// Close 关闭队列, 唤醒所有等待的生产者和消费者. 之后 Put 返回 ErrQueueClosed, Take 取完剩余元素后返回 ErrQueueClosed
+func (q *BlockingQueue[T]) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	close(q.notEmpty)
+	close(q.notFull)
+}
+
+// Closed 队列是否已经关闭
+func (q *BlockingQueue[T]) Closed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+// signalNotEmpty 唤醒等待的消费者, 调用者持有 mu.
+// 关闭后 notEmpty 和 notFull 已经关闭, 等待者被唤醒前计数仍可能不为0, 不能再次关闭
+func (q *BlockingQueue[T]) signalNotEmpty() {
+	if q.takers > 0 && !q.closed {
+		close(q.notEmpty)
+		q.notEmpty = make(chan struct{})
+	}
+}
+
+// signalNotFull 唤醒等待的生产者, 调用者持有 mu
+func (q *BlockingQueue[T]) signalNotFull() {
+	if q.putters > 0 && !q.closed {
+		close(q.notFull)
+		q.notFull = make(chan struct{})
+	}
+}
+
+// timeoutContext timeout 小于等于0时返回已经结束的 context
+func timeoutContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx, cancel
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockingQueuePutTake(t *testing.T) {
+	assert := assert.New(t)
+	q := NewBlockingQueue[int](2)
+	assert.Equal(2, q.Cap())
+	ctx := context.Background()
+
+	assert.Nil(q.Put(ctx, 1))
+	assert.Nil(q.Put(ctx, 2))
+	assert.Equal(2, q.Len())
+	v, ok := q.Peek()
+	assert.True(ok)
+	assert.Equal(1, v)
+
+	// 队列满时 Put 阻塞直到 ctx 结束
+	tctx, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, q.Put(tctx, 3))
+
+	// 有空位时唤醒等待的生产者
+	done := make(chan error)
+	go func() {
+		done <- q.Put(ctx, 3)
+	}()
+	v, err := q.Take(ctx)
+	assert.Nil(err)
+	assert.Equal(1, v)
+	assert.Nil(<-done)
+
+	for _, want := range []int{2, 3} {
+		v, err = q.Take(ctx)
+		assert.Nil(err)
+		assert.Equal(want, v)
+	}
+	tctx, cancel = context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+	_, err = q.Take(tctx)
+	assert.Equal(context.DeadlineExceeded, err)
+}
+
+func TestBlockingQueueOfferPoll(t *testing.T) {
+	assert := assert.New(t)
+	q := NewBlockingQueue[string](1)
+
+	_, ok := q.Poll(0)
+	assert.False(ok)
+	start := time.Now()
+	_, ok = q.Poll(time.Millisecond * 20)
+	assert.False(ok)
+	assert.True(time.Since(start) >= time.Millisecond*20)
+
+	assert.True(q.Offer("a", 0))
+	assert.False(q.Offer("b", 0))
+	assert.False(q.Offer("b", time.Millisecond*10))
+
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		q.Poll(0)
+	}()
+	assert.True(q.Offer("b", time.Second))
+	v, ok := q.Poll(time.Second)
+	assert.True(ok)
+	assert.Equal("b", v)
+}
+
+func TestBlockingQueueClose(t *testing.T) {
+	assert := assert.New(t)
+	q := NewBlockingQueue[int](1)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := q.Take(ctx)
+			errs <- err
+		}()
+	}
+	time.Sleep(time.Millisecond * 10)
+	assert.Nil(q.Put(ctx, 1))
+	assert.Nil(<-errs)
+
+	// Close 唤醒剩余的消费者
+	q.Close()
+	q.Close()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.Equal(ErrQueueClosed, err)
+	}
+	assert.True(q.Closed())
+	assert.Equal(ErrQueueClosed, q.Put(ctx, 2))
+}
+
+func TestBlockingQueueCloseDrain(t *testing.T) {
+	assert := assert.New(t)
+	q := NewBlockingQueue[int](2)
+	ctx := context.Background()
+	assert.Nil(q.Put(ctx, 1))
+	assert.Nil(q.Put(ctx, 2))
+
+	done := make(chan error)
+	go func() {
+		done <- q.Put(ctx, 3)
+	}()
+	time.Sleep(time.Millisecond * 10)
+	q.Close()
+	assert.Equal(ErrQueueClosed, <-done)
+
+	// 关闭后仍可以取出剩余的元素
+	for _, want := range []int{1, 2} {
+		v, err := q.Take(ctx)
+		assert.Nil(err)
+		assert.Equal(want, v)
+	}
+	_, err := q.Take(ctx)
+	assert.Equal(ErrQueueClosed, err)
+}
+
+func TestBlockingQueueCloseWithWaiters(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		q := NewBlockingQueue[int](1)
+		assert.Nil(q.Put(ctx, 1))
+		done := make(chan error)
+		go func() {
+			done <- q.Put(ctx, 2)
+		}()
+		assert.Eventually(func() bool {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			return q.putters == 1
+		}, time.Second, time.Millisecond)
+
+		// 被唤醒的生产者还没有减计数时取出元素, 不能重复关闭 notFull
+		q.Close()
+		v, err := q.Take(ctx)
+		assert.Nil(err)
+		assert.Equal(1, v)
+		assert.Equal(ErrQueueClosed, <-done)
+	}
+}
+
+func TestBlockingQueueShrink(t *testing.T) {
+	assert := assert.New(t)
+	q := NewBlockingQueue[int](10000)
+	for i := 0; i < 10000; i++ {
+		assert.True(q.Offer(i, 0))
+	}
+	assert.False(q.Offer(10000, 0))
+	assert.Equal(16384, len(q.content.buffer))
+	for i := 0; i < 10000; i++ {
+		v, ok := q.Poll(0)
+		assert.True(ok)
+		assert.Equal(i, v)
+	}
+	assert.Equal(defaultBlockingQueueMinSize, len(q.content.buffer))
+}
+
+func TestBlockingQueueConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	q := NewBlockingQueue[int](8)
+	ctx := context.Background()
+	var producers sync.WaitGroup
+	for p := 0; p < 8; p++ {
+		producers.Add(1)
+		go func() {
+			defer producers.Done()
+			for i := 0; i < 1000; i++ {
+				assert.Nil(q.Put(ctx, i))
+			}
+		}()
+	}
+	go func() {
+		producers.Wait()
+		q.Close()
+	}()
+
+	var (
+		consumers sync.WaitGroup
+		mu        sync.Mutex
+		sum       int
+	)
+	for c := 0; c < 4; c++ {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			for {
+				v, err := q.Take(ctx)
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				sum += v
+				mu.Unlock()
+			}
+		}()
+	}
+	consumers.Wait()
+	assert.Equal(8*999*1000/2, sum)
+}
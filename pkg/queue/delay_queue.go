@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// delayItem 到期时间 at 之后才能取出的元素
+type delayItem[T any] struct {
+	value T
+	at    time.Time
+}
+
+// DelayQueue 延迟队列, 元素到期后才能取出, 按到期时间先后出队.
+// 所有元素共用一个定时器, 定时器在最早的元素到期时唤醒等待的 Take
+type DelayQueue[T any] struct {
+	mu     sync.Mutex
+	items  elementHeap[delayItem[T]]
+	timer  *time.Timer
+	wakeAt time.Time     // 定时器的触发时间, 零值表示定时器没有启动
+	notify chan struct{} // 定时器触发或者队列关闭时关闭并替换, 唤醒所有等待的 Take
+	closed bool
+}
+
+// NewDelayQueue 创建延迟队列
+func NewDelayQueue[T any]() *DelayQueue[T] {
+	q := &DelayQueue[T]{
+		items: elementHeap[delayItem[T]]{less: func(a, b delayItem[T]) bool {
+			return a.at.Before(b.at)
+		}},
+		notify: make(chan struct{}),
+	}
+	q.timer = time.AfterFunc(time.Hour, q.fire)
+	q.timer.Stop()
+	return q
+}
+
+// Put 加入在 at 到期的元素, 队列关闭后返回 ErrQueueClosed
+func (q *DelayQueue[T]) Put(value T, at time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	heap.Push(&q.items, &Element[delayItem[T]]{value: delayItem[T]{value: value, at: at}})
+	if q.items.peek().value.at.Equal(at) {
+		q.arm(at)
+	}
+	return nil
+}
+
+// PutAfter 加入 delay 之后到期的元素
+func (q *DelayQueue[T]) PutAfter(value T, delay time.Duration) error {
+	return q.Put(value, time.Now().Add(delay))
+}
+
+// Take 取出到期的元素, 没有时阻塞直到有元素到期、ctx 结束或者队列关闭
+func (q *DelayQueue[T]) Take(ctx context.Context) (T, error) {
+	q.mu.Lock()
+	for {
+		if value, ok := q.poll(); ok {
+			q.mu.Unlock()
+			return value, nil
+		}
+		if q.closed {
+			q.mu.Unlock()
+			var zero T
+			return zero, ErrQueueClosed
+		}
+		if head := q.items.peek(); head != nil {
+			q.arm(head.value.at)
+		}
+		wait := q.notify
+		q.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+		q.mu.Lock()
+	}
+}
+
+// Poll 取出到期的元素, 没有时返回false
+func (q *DelayQueue[T]) Poll() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.poll()
+}
+
+// Len 元素数, 包括还没有到期的
+func (q *DelayQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
+
+// Close 关闭队列, 唤醒所有等待的 Take 并返回还没有取出的元素
+func (q *DelayQueue[T]) Close() []T {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	q.timer.Stop()
+	close(q.notify)
+
+	remaining := make([]T, 0, q.items.Len())
+	for q.items.Len() > 0 {
+		remaining = append(remaining, heap.Pop(&q.items).(*Element[delayItem[T]]).value.value)
+	}
+	return remaining
+}
+
+// poll 取出到期的堆顶元素, 调用者持有 mu
+func (q *DelayQueue[T]) poll() (T, bool) {
+	head := q.items.peek()
+	if head == nil || head.value.at.After(time.Now()) {
+		var zero T
+		return zero, false
+	}
+	heap.Pop(&q.items)
+	return head.value.value, true
+}
+
+// arm 保证定时器在 at 之前触发, 调用者持有 mu
+func (q *DelayQueue[T]) arm(at time.Time) {
+	if !q.wakeAt.IsZero() && !at.Before(q.wakeAt) {
+		return
+	}
+	q.wakeAt = at
+	q.timer.Reset(time.Until(at))
+}
+
+// fire 定时器触发, 唤醒所有等待的 Take
+func (q *DelayQueue[T]) fire() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.wakeAt = time.Time{}
+	if !q.closed {
+		close(q.notify)
+		q.notify = make(chan struct{})
+	}
+}
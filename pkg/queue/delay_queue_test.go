@@ -0,0 +1,145 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelayQueueOrder(t *testing.T) {
+	assert := assert.New(t)
+	q := NewDelayQueue[string]()
+	defer q.Close()
+
+	start := time.Now()
+	assert.Nil(q.PutAfter("c", time.Millisecond*60))
+	assert.Nil(q.PutAfter("a", time.Millisecond*20))
+	assert.Nil(q.PutAfter("b", time.Millisecond*40))
+	assert.Equal(3, q.Len())
+	_, ok := q.Poll()
+	assert.False(ok)
+
+	for i, want := range []string{"a", "b", "c"} {
+		v, err := q.Take(context.Background())
+		assert.Nil(err)
+		assert.Equal(want, v)
+		assert.True(time.Since(start) >= time.Duration(i+1)*time.Millisecond*20)
+	}
+	assert.Equal(0, q.Len())
+}
+
+func TestDelayQueueEarlierItem(t *testing.T) {
+	assert := assert.New(t)
+	q := NewDelayQueue[int]()
+	defer q.Close()
+
+	assert.Nil(q.PutAfter(1, time.Hour))
+	done := make(chan int)
+	go func() {
+		v, _ := q.Take(context.Background())
+		done <- v
+	}()
+	// 更早到期的元素重置定时器, 唤醒等待的 Take
+	time.Sleep(time.Millisecond * 10)
+	assert.Nil(q.Put(2, time.Now().Add(time.Millisecond*10)))
+	select {
+	case v := <-done:
+		assert.Equal(2, v)
+	case <-time.After(time.Second):
+		assert.Fail("take is not woken up")
+	}
+
+	// 已经到期的元素立即取出
+	assert.Nil(q.Put(3, time.Now().Add(-time.Second)))
+	v, ok := q.Poll()
+	assert.True(ok)
+	assert.Equal(3, v)
+}
+
+func TestDelayQueueTakeContext(t *testing.T) {
+	assert := assert.New(t)
+	q := NewDelayQueue[int]()
+	defer q.Close()
+	assert.Nil(q.PutAfter(1, time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	_, err := q.Take(ctx)
+	assert.Equal(context.DeadlineExceeded, err)
+}
+
+func TestDelayQueueClose(t *testing.T) {
+	assert := assert.New(t)
+	q := NewDelayQueue[int]()
+	assert.Nil(q.PutAfter(1, time.Hour))
+	assert.Nil(q.PutAfter(2, time.Minute))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := q.Take(context.Background())
+			assert.Equal(ErrQueueClosed, err)
+		}()
+	}
+	time.Sleep(time.Millisecond * 10)
+	assert.Equal([]int{2, 1}, q.Close())
+	wg.Wait()
+	assert.Nil(q.Close())
+	assert.Equal(ErrQueueClosed, q.PutAfter(3, 0))
+}
+
+func TestDelayQueueConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	q := NewDelayQueue[int]()
+	defer q.Close()
+
+	const n = 200
+	for i := 0; i < n; i++ {
+		assert.Nil(q.PutAfter(i, time.Duration(i%20)*time.Millisecond))
+	}
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[int]bool)
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	for c := 0; c < 4; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n/4; i++ {
+				v, err := q.Take(ctx)
+				if !assert.Nil(err) {
+					return
+				}
+				mu.Lock()
+				seen[v] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(seen, n)
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// DiskConsumer DiskQueue 的消费者, 按 sequence 顺序消费.
+// Ack 确认到某条消息为止的所有消息, Commit 持久化确认位置; 重启后从 Commit 的位置之后重新消费
+type DiskConsumer struct {
+	queue     *DiskQueue
+	name      string
+	path      string
+	mu        sync.Mutex
+	consumed  int64 // Next 返回的最后一条消息的 sequence
+	acked     int64 // 确认的最后一条消息的 sequence
+	committed int64 // 持久化的确认位置, 原子读写, Cleanup 持有队列的锁时读取
+}
+
+// openConsumer 读取持久化的确认位置, 位置在 [minSeq, maxSeq] 之外时返回 ErrOutOfSequenceRange
+func openConsumer(q *DiskQueue, name string, minSeq, maxSeq int64) (*DiskConsumer, error) {
+	c := &DiskConsumer{
+		queue:     q,
+		name:      name,
+		path:      q.consumerPath(name),
+		committed: minSeq,
+	}
+	data, err := os.ReadFile(c.path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	case len(data) != 8:
+		return nil, ErrOutOfSequenceRange
+	default:
+		c.committed = int64(binary.BigEndian.Uint64(data))
+		if c.committed > maxSeq {
+			return nil, ErrOutOfSequenceRange
+		}
+		// 已经被清理的消息不再消费
+		if c.committed < minSeq {
+			c.committed = minSeq
+		}
+	}
+	c.consumed, c.acked = c.committed, c.committed
+	return c, nil
+}
+
+// Name 消费者名称
+func (c *DiskConsumer) Name() string {
+	return c.name
+}
+
+// Next 返回下一条消息和它的 sequence, 没有新消息时返回 ErrOutOfSequenceRange.
+// 损坏的消息会被跳过
+func (c *DiskConsumer) Next() (int64, []byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for seq := c.consumed + 1; ; seq++ {
+		msg, err := c.queue.Get(seq)
+		if err == ErrMsgNotFound {
+			continue
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		c.consumed = seq
+		return seq, msg, nil
+	}
+}
+
+// Ack 确认 seq 及之前的所有消息, seq 必须已经被 Next 返回且大于上次确认的位置, 否则返回 ErrOutOfSequenceRange
+func (c *DiskConsumer) Ack(seq int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if seq <= c.acked || seq > c.consumed {
+		return ErrOutOfSequenceRange
+	}
+	c.acked = seq
+	return nil
+}
+
+// Commit 持久化确认位置, 队列关闭后返回 ErrQueueClosed
+func (c *DiskConsumer) Commit() error {
+	// 和 Next 一样先锁消费者再锁队列
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queue.mu.RLock()
+	defer c.queue.mu.RUnlock()
+	if c.queue.closed {
+		return ErrQueueClosed
+	}
+	if c.acked == c.committed {
+		return nil
+	}
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(c.acked))
+	tmp := c.path + consumerTmpSuffix
+	if err := writeFileSync(tmp, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&c.committed, c.acked)
+	return nil
+}
+
+// Rewind 回到确认位置, 没有确认的消息重新消费
+func (c *DiskConsumer) Rewind() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.consumed = c.acked
+}
+
+// ConsumedSeq Next 返回的最后一条消息的 sequence
+func (c *DiskConsumer) ConsumedSeq() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.consumed
+}
+
+// AckedSeq 确认的最后一条消息的 sequence
+func (c *DiskConsumer) AckedSeq() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.acked
+}
+
+// CommittedSeq 持久化的确认位置
+func (c *DiskConsumer) CommittedSeq() int64 {
+	return atomic.LoadInt64(&c.committed)
+}
+
+// Pending 还没有确认的消息数
+func (c *DiskConsumer) Pending() int64 {
+	return c.queue.TailSeq() - 1 - c.AckedSeq()
+}
+
+// writeFileSync 写入文件并 fsync
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,365 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kubeservice-stack/common/pkg/logger"
+	"github.com/kubeservice-stack/common/pkg/utils"
+)
+
+const (
+	defaultSegmentSize    int64 = 64 * 1024 * 1024
+	defaultMaxMessageSize       = 1024 * 1024
+	// 消费者 offset 文件所在的子目录
+	consumersDir = "consumers"
+	// Commit 时先写入的临时文件后缀
+	consumerTmpSuffix = ".tmp"
+)
+
+type DiskQueueOption func(*DiskQueue)
+
+// Defaults to 64MB, segment 文件写满后创建新的 segment
+func WithSegmentSize(size int64) DiskQueueOption {
+	return func(q *DiskQueue) {
+		q.segmentSize = size
+	}
+}
+
+// Defaults to 1MB, 更大的消息返回 ErrExceedingMessageSizeLimit, 不超过 segment 大小
+func WithMaxMessageSize(size int) DiskQueueOption {
+	return func(q *DiskQueue) {
+		q.maxMessageSize = size
+	}
+}
+
+// Defaults to 0 不限制, 所有 segment 的总大小超过时返回 ErrExceedingTotalSizeLimit
+func WithMaxTotalSize(size int64) DiskQueueOption {
+	return func(q *DiskQueue) {
+		q.maxTotalSize = size
+	}
+}
+
+// Defaults to false, 为true时每次 Put 后 fsync, 否则在切换 segment, Sync 和 Close 时 fsync
+func WithSyncWrite(sync bool) DiskQueueOption {
+	return func(q *DiskQueue) {
+		q.syncWrite = sync
+	}
+}
+
+// DiskQueue 持久化在磁盘上的消息队列. 消息追加写入 segment 文件, 按写入顺序分配递增的 sequence.
+// 每个消费者独立记录消费位置, Commit 后持久化; 所有消费者都提交过的 segment 由 Cleanup 删除.
+// 重启时从 segment 文件恢复, 截断崩溃时没有写完的消息
+type DiskQueue struct {
+	dir            string
+	segmentSize    int64
+	maxMessageSize int
+	maxTotalSize   int64
+	syncWrite      bool
+
+	mu        sync.RWMutex
+	segments  []*segment // 按 base 排序, 最后一个是正在写入的 segment
+	totalSize int64
+	consumers map[string]*DiskConsumer
+	closed    bool
+	logger    *logger.Logger
+}
+
+// OpenDiskQueue 打开目录 dir 下的队列, 目录不存在时创建
+func OpenDiskQueue(dir string, opts ...DiskQueueOption) (*DiskQueue, error) {
+	q := &DiskQueue{
+		dir:            dir,
+		segmentSize:    defaultSegmentSize,
+		maxMessageSize: defaultMaxMessageSize,
+		consumers:      make(map[string]*DiskConsumer),
+		logger:         logger.GetLogger("pkg/common/queue", "DiskQueue"),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	if q.segmentSize <= recordHeaderSize {
+		q.segmentSize = defaultSegmentSize
+	}
+	if q.maxMessageSize <= 0 || int64(q.maxMessageSize)+recordHeaderSize > q.segmentSize {
+		q.maxMessageSize = int(q.segmentSize - recordHeaderSize)
+	}
+	if err := utils.MkDirIfNotExist(filepath.Join(dir, consumersDir)); err != nil {
+		return nil, err
+	}
+	if err := q.recover(); err != nil {
+		q.closeSegments()
+		return nil, err
+	}
+	return q, nil
+}
+
+// recover 打开所有 segment, 没有时创建第一个, 然后加载持久化的消费者
+func (q *DiskQueue) recover() error {
+	names, err := utils.ListDir(q.dir)
+	if err != nil {
+		return err
+	}
+	var bases []int64
+	for _, name := range names {
+		if base, ok := parseSegmentName(name); ok {
+			bases = append(bases, base)
+		}
+	}
+	sort.Slice(bases, func(i, j int) bool { return bases[i] < bases[j] })
+
+	for i, base := range bases {
+		s, err := openSegment(q.dir, base)
+		if err != nil {
+			return err
+		}
+		if i+1 < len(bases) && s.next() != bases[i+1] {
+			// 中间的 segment 损坏, 缺失的消息 Get 时返回 ErrMsgNotFound
+			q.logger.Warn("segment is truncated",
+				logger.String("path", s.path), logger.Int64("lost", bases[i+1]-s.next()))
+		}
+		q.segments = append(q.segments, s)
+		q.totalSize += s.size
+	}
+	if len(q.segments) == 0 {
+		s, err := createSegment(q.dir, 0)
+		if err != nil {
+			return err
+		}
+		q.segments = append(q.segments, s)
+	}
+	return q.recoverConsumers()
+}
+
+// recoverConsumers 加载 consumers 目录下所有持久化的消费者, 重启后还没有重新打开的消费者也参与 Cleanup
+func (q *DiskQueue) recoverConsumers() error {
+	names, err := utils.ListDir(filepath.Join(q.dir, consumersDir))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if validConsumerName(name) != nil {
+			continue
+		}
+		c, err := openConsumer(q, name, q.segments[0].base-1, q.active().next()-1)
+		if err != nil {
+			// 位置超出范围的消费者 Consumer 时同样返回错误, 这里只记录
+			q.logger.Warn("skip broken consumer",
+				logger.String("name", name), logger.Error(err))
+			continue
+		}
+		q.consumers[name] = c
+	}
+	return nil
+}
+
+// Put 追加一条消息, 返回消息的 sequence
+func (q *DiskQueue) Put(msg []byte) (int64, error) {
+	if len(msg) > q.maxMessageSize {
+		return 0, ErrExceedingMessageSizeLimit
+	}
+	size := recordSize(msg)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return 0, ErrQueueClosed
+	}
+	if q.maxTotalSize > 0 && q.totalSize+size > q.maxTotalSize {
+		return 0, ErrExceedingTotalSizeLimit
+	}
+
+	active := q.active()
+	if active.size+size > q.segmentSize && len(active.offsets) > 0 {
+		if err := q.roll(); err != nil {
+			return 0, err
+		}
+		active = q.active()
+	}
+	seq := active.next()
+	if err := active.append(msg); err != nil {
+		return 0, err
+	}
+	q.totalSize += size
+	if q.syncWrite {
+		if err := active.sync(); err != nil {
+			return 0, err
+		}
+	}
+	return seq, nil
+}
+
+// roll 持久化当前 segment 并创建新的 segment, 调用者持有写锁
+func (q *DiskQueue) roll() error {
+	active := q.active()
+	if err := active.sync(); err != nil {
+		return err
+	}
+	s, err := createSegment(q.dir, active.next())
+	if err != nil {
+		return err
+	}
+	q.segments = append(q.segments, s)
+	return nil
+}
+
+// active 正在写入的 segment, 调用者持有锁
+func (q *DiskQueue) active() *segment {
+	return q.segments[len(q.segments)-1]
+}
+
+// Get 读取 sequence 为 seq 的消息.
+// seq 还没有写入时返回 ErrOutOfSequenceRange, 已经被清理或者损坏时返回 ErrMsgNotFound
+func (q *DiskQueue) Get(seq int64) ([]byte, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return nil, ErrQueueClosed
+	}
+	if seq < 0 || seq >= q.active().next() {
+		return nil, ErrOutOfSequenceRange
+	}
+	if seq < q.segments[0].base {
+		return nil, ErrMsgNotFound
+	}
+	// 最后一个 base 不大于 seq 的 segment
+	i := sort.Search(len(q.segments), func(i int) bool { return q.segments[i].base > seq }) - 1
+	return q.segments[i].read(seq)
+}
+
+// HeadSeq 还保存着的第一条消息的 sequence
+func (q *DiskQueue) HeadSeq() int64 {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.segments[0].base
+}
+
+// TailSeq 下一条写入的消息的 sequence
+func (q *DiskQueue) TailSeq() int64 {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.active().next()
+}
+
+// Size 所有 segment 的总字节数
+func (q *DiskQueue) Size() int64 {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.totalSize
+}
+
+// Consumer 返回名为 name 的消费者, 不存在时创建.
+// 消费者从上次 Commit 的位置之后开始消费, 新的消费者从 HeadSeq 开始.
+// name 作为 offset 文件名, 不能为空, 不能包含路径分隔符, 否则返回 ErrInvalidConsumerName
+func (q *DiskQueue) Consumer(name string) (*DiskConsumer, error) {
+	if err := validConsumerName(name); err != nil {
+		return nil, err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, ErrQueueClosed
+	}
+	if c, ok := q.consumers[name]; ok {
+		return c, nil
+	}
+	c, err := openConsumer(q, name, q.segments[0].base-1, q.active().next()-1)
+	if err != nil {
+		return nil, err
+	}
+	q.consumers[name] = c
+	return c, nil
+}
+
+// Cleanup 删除所有消费者都已经 Commit 的 segment, 正在写入的 segment 不删除.
+// 消费者包括重启前持久化过的消费者. 没有消费者时不删除
+func (q *DiskQueue) Cleanup() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if len(q.consumers) == 0 {
+		return nil
+	}
+	committed := q.active().next() - 1
+	for _, c := range q.consumers {
+		if seq := c.CommittedSeq(); seq < committed {
+			committed = seq
+		}
+	}
+
+	removed := 0
+	for _, s := range q.segments[:len(q.segments)-1] {
+		if s.next()-1 > committed {
+			break
+		}
+		if err := s.remove(); err != nil {
+			return err
+		}
+		q.totalSize -= s.size
+		removed++
+	}
+	q.segments = q.segments[removed:]
+	return nil
+}
+
+// Sync 持久化正在写入的 segment
+func (q *DiskQueue) Sync() error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	return q.active().sync()
+}
+
+// Close 持久化并关闭所有 segment, 不会提交消费者的位置
+func (q *DiskQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	err := q.active().sync()
+	q.closeSegments()
+	return err
+}
+
+func (q *DiskQueue) closeSegments() {
+	for _, s := range q.segments {
+		_ = s.close()
+	}
+}
+
+// consumerPath 消费者 offset 文件的路径
+func (q *DiskQueue) consumerPath(name string) string {
+	return filepath.Join(q.dir, consumersDir, name)
+}
+
+// validConsumerName 消费者名称必须是 consumers 目录下的普通文件名
+func validConsumerName(name string) error {
+	if name == "" || name == "." || name == ".." ||
+		strings.ContainsAny(name, `/\`) || strings.HasSuffix(name, consumerTmpSuffix) {
+		return ErrInvalidConsumerName
+	}
+	return nil
+}
@@ -0,0 +1,376 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func putMessages(t *testing.T, q *DiskQueue, from, to int) {
+	for i := from; i < to; i++ {
+		seq, err := q.Put([]byte(fmt.Sprintf("msg-%04d", i)))
+		require.NoError(t, err)
+		require.Equal(t, int64(i), seq)
+	}
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	require.NoError(t, err)
+	return matches
+}
+
+func TestDiskQueuePutGet(t *testing.T) {
+	assert := assert.New(t)
+	q, err := OpenDiskQueue(t.TempDir(), WithMaxMessageSize(16), WithSyncWrite(true))
+	require.NoError(t, err)
+	defer q.Close()
+
+	assert.Equal(int64(0), q.HeadSeq())
+	assert.Equal(int64(0), q.TailSeq())
+	_, err = q.Get(0)
+	assert.Equal(ErrOutOfSequenceRange, err)
+
+	putMessages(t, q, 0, 10)
+	assert.Equal(int64(10), q.TailSeq())
+	assert.Equal(int64(10*(recordHeaderSize+8)), q.Size())
+	for i := 0; i < 10; i++ {
+		msg, err := q.Get(int64(i))
+		assert.Nil(err)
+		assert.Equal(fmt.Sprintf("msg-%04d", i), string(msg))
+	}
+	_, err = q.Get(10)
+	assert.Equal(ErrOutOfSequenceRange, err)
+	_, err = q.Get(-1)
+	assert.Equal(ErrOutOfSequenceRange, err)
+
+	_, err = q.Put(make([]byte, 17))
+	assert.Equal(ErrExceedingMessageSizeLimit, err)
+	seq, err := q.Put(nil)
+	assert.Nil(err)
+	msg, err := q.Get(seq)
+	assert.Nil(err)
+	assert.Empty(msg)
+}
+
+func TestDiskQueueSegments(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	// 每个 segment 放4条消息
+	q, err := OpenDiskQueue(dir, WithSegmentSize(4*(recordHeaderSize+8)))
+	require.NoError(t, err)
+
+	putMessages(t, q, 0, 10)
+	assert.Len(segmentFiles(t, dir), 3)
+	for i := 0; i < 10; i++ {
+		msg, err := q.Get(int64(i))
+		assert.Nil(err)
+		assert.Equal(fmt.Sprintf("msg-%04d", i), string(msg))
+	}
+	assert.Nil(q.Close())
+	assert.Nil(q.Close())
+	_, err = q.Put([]byte("closed"))
+	assert.Equal(ErrQueueClosed, err)
+
+	// 重新打开后继续分配 sequence
+	q, err = OpenDiskQueue(dir, WithSegmentSize(4*(recordHeaderSize+8)))
+	require.NoError(t, err)
+	defer q.Close()
+	assert.Equal(int64(10), q.TailSeq())
+	putMessages(t, q, 10, 12)
+	msg, err := q.Get(11)
+	assert.Nil(err)
+	assert.Equal("msg-0011", string(msg))
+}
+
+func TestDiskQueueConsumer(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	q, err := OpenDiskQueue(dir)
+	require.NoError(t, err)
+	putMessages(t, q, 0, 5)
+
+	c, err := q.Consumer("c1")
+	require.NoError(t, err)
+	same, err := q.Consumer("c1")
+	assert.Nil(err)
+	assert.Equal(c, same)
+	assert.Equal("c1", c.Name())
+	assert.Equal(int64(5), c.Pending())
+
+	for i := 0; i < 3; i++ {
+		seq, msg, err := c.Next()
+		assert.Nil(err)
+		assert.Equal(int64(i), seq)
+		assert.Equal(fmt.Sprintf("msg-%04d", i), string(msg))
+	}
+	assert.Equal(ErrOutOfSequenceRange, c.Ack(3))
+	assert.Nil(c.Ack(1))
+	assert.Equal(ErrOutOfSequenceRange, c.Ack(1))
+	assert.Equal(int64(1), c.AckedSeq())
+	assert.Equal(int64(-1), c.CommittedSeq())
+	assert.Nil(c.Commit())
+	assert.Equal(int64(1), c.CommittedSeq())
+
+	// 没有确认的消息重新消费
+	c.Rewind()
+	assert.Equal(int64(1), c.ConsumedSeq())
+	seq, _, err := c.Next()
+	assert.Nil(err)
+	assert.Equal(int64(2), seq)
+	assert.Nil(c.Ack(2))
+	require.NoError(t, q.Close())
+
+	// 重启后从 Commit 的位置之后消费
+	q, err = OpenDiskQueue(dir)
+	require.NoError(t, err)
+	defer q.Close()
+	c, err = q.Consumer("c1")
+	require.NoError(t, err)
+	for i := 2; i < 5; i++ {
+		seq, _, err := c.Next()
+		assert.Nil(err)
+		assert.Equal(int64(i), seq)
+	}
+	_, _, err = c.Next()
+	assert.Equal(ErrOutOfSequenceRange, err)
+	putMessages(t, q, 5, 6)
+	seq, _, err = c.Next()
+	assert.Nil(err)
+	assert.Equal(int64(5), seq)
+
+	// 新的消费者从头开始
+	c2, err := q.Consumer("c2")
+	require.NoError(t, err)
+	seq, _, err = c2.Next()
+	assert.Nil(err)
+	assert.Equal(int64(0), seq)
+}
+
+func TestDiskQueueCommitAfterClose(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	q, err := OpenDiskQueue(dir)
+	require.NoError(t, err)
+	putMessages(t, q, 0, 2)
+	c, err := q.Consumer("c1")
+	require.NoError(t, err)
+	_, _, err = c.Next()
+	require.NoError(t, err)
+	require.NoError(t, c.Ack(0))
+	require.NoError(t, q.Close())
+
+	// 关闭后不再写入 offset 文件
+	assert.Equal(ErrQueueClosed, c.Commit())
+	_, err = os.Stat(filepath.Join(dir, consumersDir, "c1"))
+	assert.True(os.IsNotExist(err))
+	_, _, err = c.Next()
+	assert.Equal(ErrQueueClosed, err)
+}
+
+func TestDiskQueueCleanup(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	recSize := int64(recordHeaderSize + 8)
+	q, err := OpenDiskQueue(dir, WithSegmentSize(4*recSize), WithMaxTotalSize(10*recSize))
+	require.NoError(t, err)
+	defer q.Close()
+
+	putMessages(t, q, 0, 10)
+	_, err = q.Put([]byte("msg-full"))
+	assert.Equal(ErrExceedingTotalSizeLimit, err)
+
+	// 没有消费者时不清理
+	assert.Nil(q.Cleanup())
+	assert.Len(segmentFiles(t, dir), 3)
+
+	c1, err := q.Consumer("c1")
+	require.NoError(t, err)
+	c2, err := q.Consumer("c2")
+	require.NoError(t, err)
+	for i := 0; i < 9; i++ {
+		_, _, err = c1.Next()
+		assert.Nil(err)
+	}
+	assert.Nil(c1.Ack(8))
+	assert.Nil(c1.Commit())
+	for i := 0; i < 5; i++ {
+		_, _, err = c2.Next()
+		assert.Nil(err)
+	}
+	assert.Nil(c2.Ack(4))
+	assert.Nil(c2.Commit())
+
+	// 只有第一个 segment 被两个消费者都提交了
+	assert.Nil(q.Cleanup())
+	assert.Len(segmentFiles(t, dir), 2)
+	assert.Equal(int64(4), q.HeadSeq())
+	assert.Equal(6*recSize, q.Size())
+	_, err = q.Get(3)
+	assert.Equal(ErrMsgNotFound, err)
+	putMessages(t, q, 10, 14)
+
+	// 正在写入的 segment 不清理
+	for i := 0; i < 9; i++ {
+		_, _, err = c2.Next()
+		assert.Nil(err)
+	}
+	assert.Nil(c2.Ack(13))
+	assert.Nil(c2.Commit())
+	_, _, err = c1.Next()
+	assert.Nil(err)
+	assert.Nil(c1.Ack(9))
+	assert.Nil(c1.Commit())
+	assert.Nil(q.Cleanup())
+	assert.Equal(int64(8), q.HeadSeq())
+}
+
+func TestDiskQueueCleanupAfterRestart(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	recSize := int64(recordHeaderSize + 8)
+	q, err := OpenDiskQueue(dir, WithSegmentSize(4*recSize))
+	require.NoError(t, err)
+	putMessages(t, q, 0, 10)
+	for _, name := range []string{"c1", "c2"} {
+		c, err := q.Consumer(name)
+		require.NoError(t, err)
+		_, _, err = c.Next()
+		require.NoError(t, err)
+		require.NoError(t, c.Ack(0))
+		require.NoError(t, c.Commit())
+	}
+	require.NoError(t, q.Close())
+
+	// 重启后只打开 c1, c2 持久化的位置仍然阻止清理
+	q, err = OpenDiskQueue(dir, WithSegmentSize(4*recSize))
+	require.NoError(t, err)
+	defer q.Close()
+	c1, err := q.Consumer("c1")
+	require.NoError(t, err)
+	for i := 1; i < 9; i++ {
+		_, _, err = c1.Next()
+		assert.Nil(err)
+	}
+	assert.Nil(c1.Ack(8))
+	assert.Nil(c1.Commit())
+	assert.Nil(q.Cleanup())
+	assert.Len(segmentFiles(t, dir), 3)
+	assert.Equal(int64(0), q.HeadSeq())
+
+	c2, err := q.Consumer("c2")
+	require.NoError(t, err)
+	assert.Equal(int64(0), c2.CommittedSeq())
+	seq, _, err := c2.Next()
+	assert.Nil(err)
+	assert.Equal(int64(1), seq)
+}
+
+func TestDiskQueueConsumerName(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	q, err := OpenDiskQueue(dir)
+	require.NoError(t, err)
+	defer q.Close()
+	for _, name := range []string{"", ".", "..", "../x", "a/b", `a\b`, "c.tmp"} {
+		_, err = q.Consumer(name)
+		assert.Equal(ErrInvalidConsumerName, err, name)
+	}
+	_, err = os.Stat(filepath.Join(dir, "x"))
+	assert.True(os.IsNotExist(err))
+	_, err = q.Consumer("c-1")
+	assert.Nil(err)
+}
+
+func TestDiskQueueRecovery(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	q, err := OpenDiskQueue(dir)
+	require.NoError(t, err)
+	putMessages(t, q, 0, 3)
+	require.NoError(t, q.Close())
+
+	// 模拟崩溃时写了一半的消息
+	files := segmentFiles(t, dir)
+	require.Len(t, files, 1)
+	f, err := os.OpenFile(files[0], os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 100, 1, 2, 3})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	q, err = OpenDiskQueue(dir)
+	require.NoError(t, err)
+	assert.Equal(int64(3), q.TailSeq())
+	putMessages(t, q, 3, 4)
+	msg, err := q.Get(3)
+	assert.Nil(err)
+	assert.Equal("msg-0003", string(msg))
+	require.NoError(t, q.Close())
+
+	// 校验失败的消息及之后的消息被截断
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	data[2*(recordHeaderSize+8)+recordHeaderSize] ^= 0xff
+	require.NoError(t, os.WriteFile(files[0], data, 0o644))
+	q, err = OpenDiskQueue(dir)
+	require.NoError(t, err)
+	defer q.Close()
+	assert.Equal(int64(2), q.TailSeq())
+}
+
+func TestDiskQueueLostMessages(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	recSize := int64(recordHeaderSize + 8)
+	q, err := OpenDiskQueue(dir, WithSegmentSize(2*recSize))
+	require.NoError(t, err)
+	putMessages(t, q, 0, 5)
+	require.NoError(t, q.Close())
+
+	// 损坏中间 segment 的最后一条消息
+	files := segmentFiles(t, dir)
+	require.Len(t, files, 3)
+	require.NoError(t, os.Truncate(files[1], recSize+1))
+
+	q, err = OpenDiskQueue(dir, WithSegmentSize(2*recSize))
+	require.NoError(t, err)
+	defer q.Close()
+	assert.Equal(int64(5), q.TailSeq())
+	_, err = q.Get(3)
+	assert.Equal(ErrMsgNotFound, err)
+
+	// 消费者跳过丢失的消息
+	c, err := q.Consumer("c")
+	require.NoError(t, err)
+	var seqs []int64
+	for {
+		seq, _, err := c.Next()
+		if err != nil {
+			assert.Equal(ErrOutOfSequenceRange, err)
+			break
+		}
+		seqs = append(seqs, seq)
+	}
+	assert.Equal([]int64{0, 1, 2, 4}, seqs)
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// segment 文件后缀, 文件名是第一条消息的 sequence
+	segmentSuffix = ".seg"
+	// 每条消息的头: 4字节长度 + 4字节crc32
+	recordHeaderSize = 8
+)
+
+// segment 只追加写的消息文件, 记录每条消息的偏移量
+type segment struct {
+	base    int64  // 第一条消息的 sequence
+	path    string // 文件路径
+	file    *os.File
+	offsets []int64 // 每条消息在文件中的偏移量
+	size    int64   // 文件大小
+}
+
+func segmentPath(dir string, base int64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", base, segmentSuffix))
+}
+
+// parseSegmentName 从文件名解析 base, 不是 segment 文件时返回false
+func parseSegmentName(name string) (int64, bool) {
+	if !strings.HasSuffix(name, segmentSuffix) {
+		return 0, false
+	}
+	base, err := strconv.ParseInt(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+	if err != nil || base < 0 {
+		return 0, false
+	}
+	return base, true
+}
+
+// createSegment 创建空的 segment 文件
+func createSegment(dir string, base int64) (*segment, error) {
+	path := segmentPath(dir, base)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &segment{base: base, path: path, file: file}, nil
+}
+
+// openSegment 打开已有的 segment 文件并重建索引.
+// 崩溃时最后一条消息可能只写了一部分, 从第一条不完整或者校验失败的消息开始截断
+func openSegment(dir string, base int64) (*segment, error) {
+	path := segmentPath(dir, base)
+	file, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := &segment{base: base, path: path, file: file}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	var offset int64
+	header := make([]byte, recordHeaderSize)
+	for offset+recordHeaderSize <= info.Size() {
+		if _, err := file.ReadAt(header, offset); err != nil {
+			break
+		}
+		length := int64(binary.BigEndian.Uint32(header))
+		if offset+recordHeaderSize+length > info.Size() {
+			break
+		}
+		payload := make([]byte, length)
+		if _, err := file.ReadAt(payload, offset+recordHeaderSize); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+			break
+		}
+		s.offsets = append(s.offsets, offset)
+		offset += recordHeaderSize + length
+	}
+	if offset < info.Size() {
+		if err := file.Truncate(offset); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+	s.size = offset
+	return s, nil
+}
+
+// recordSize 消息写入后占用的字节数
+func recordSize(msg []byte) int64 {
+	return recordHeaderSize + int64(len(msg))
+}
+
+// append 在文件末尾追加一条消息
+func (s *segment) append(msg []byte) error {
+	record := make([]byte, recordSize(msg))
+	binary.BigEndian.PutUint32(record, uint32(len(msg)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(msg))
+	copy(record[recordHeaderSize:], msg)
+	if _, err := s.file.WriteAt(record, s.size); err != nil {
+		// 丢弃写了一部分的数据
+		_ = s.file.Truncate(s.size)
+		return err
+	}
+	s.offsets = append(s.offsets, s.size)
+	s.size += int64(len(record))
+	return nil
+}
+
+// read 读取 sequence 为 seq 的消息, 调用者保证 seq 在 segment 内
+func (s *segment) read(seq int64) ([]byte, error) {
+	index := seq - s.base
+	if index < 0 || index >= int64(len(s.offsets)) {
+		return nil, ErrMsgNotFound
+	}
+	header := make([]byte, recordHeaderSize)
+	if _, err := s.file.ReadAt(header, s.offsets[index]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint32(header))
+	if _, err := s.file.ReadAt(msg, s.offsets[index]+recordHeaderSize); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(msg) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, ErrMsgNotFound
+	}
+	return msg, nil
+}
+
+// next 下一条消息的 sequence
+func (s *segment) next() int64 {
+	return s.base + int64(len(s.offsets))
+}
+
+func (s *segment) sync() error {
+	return s.file.Sync()
+}
+
+func (s *segment) close() error {
+	return s.file.Close()
+}
+
+// remove 关闭并删除文件
+func (s *segment) remove() error {
+	_ = s.file.Close()
+	return os.Remove(s.path)
+}
@@ -25,6 +25,8 @@ var (
 	ErrOutOfSequenceRange        = fmt.Errorf("out of sequence range")
 	ErrExceedingTotalSizeLimit   = fmt.Errorf("queue data size exceeds the max size limit")
 	ErrMsgNotFound               = fmt.Errorf("message not found")
+	ErrQueueClosed               = fmt.Errorf("queue is closed")
+	ErrInvalidConsumerName       = fmt.Errorf("invalid consumer name")
 )
 
 type Queue interface {
@@ -34,3 +36,12 @@ type Queue interface {
 	IsEmpty() bool                             // queue是否为空
 	PopMany(count int64) ([]interface{}, bool) // 获取多条消息数据
 }
+
+// QueueOf 泛型队列
+type QueueOf[T any] interface {
+	Push(item T)                     // queue 结尾put 数据
+	Pop() (T, bool)                  // 获取消息数据, 队列为空时返回false
+	Length() int64                   // queue 长度
+	IsEmpty() bool                   // queue是否为空
+	PopMany(count int64) ([]T, bool) // 获取多条消息数据
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// cacheLinePad 避免生产者和消费者的位置在同一个 cache line 上互相伪共享
+type cacheLinePad [64]byte
+
+// mpmcCell 环上的一个槽位, sequence 标记槽位的状态:
+// 等于入队位置时可以写入, 等于入队位置+1时可以读取
+type mpmcCell[T any] struct {
+	sequence uint64
+	data     T
+}
+
+// MPMCQueue 无锁有界多生产者多消费者队列, 基于 Dmitry Vyukov 的 bounded MPMC queue.
+// 容量向上取整为2的幂, TryPush/TryPop 不阻塞, Push 在队列满时自旋等待
+type MPMCQueue[T any] struct {
+	_          cacheLinePad
+	enqueuePos uint64
+	_          cacheLinePad
+	dequeuePos uint64
+	_          cacheLinePad
+	buffer     []mpmcCell[T]
+	mask       uint64
+}
+
+// NewMPMC 创建容纳 capacity 个元素的无锁队列
+func NewMPMC(capacity int64) Queue {
+	return NewMPMCQueue[interface{}](capacity)
+}
+
+// NewMPMCQueue 创建容纳 capacity 个元素的泛型无锁队列, capacity 向上取整为2的幂
+func NewMPMCQueue[T any](capacity int64) *MPMCQueue[T] {
+	size := uint64(2)
+	for size < uint64(capacity) {
+		size <<= 1
+	}
+	q := &MPMCQueue[T]{
+		buffer: make([]mpmcCell[T], size),
+		mask:   size - 1,
+	}
+	for i := range q.buffer {
+		q.buffer[i].sequence = uint64(i)
+	}
+	return q
+}
+
+// TryPush 放入元素, 队列满时返回false
+func (q *MPMCQueue[T]) TryPush(item T) bool {
+	pos := atomic.LoadUint64(&q.enqueuePos)
+	for {
+		cell := &q.buffer[pos&q.mask]
+		seq := atomic.LoadUint64(&cell.sequence)
+		switch dif := int64(seq - pos); {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&q.enqueuePos, pos, pos+1) {
+				cell.data = item
+				atomic.StoreUint64(&cell.sequence, pos+1)
+				return true
+			}
+		case dif < 0:
+			// 槽位还没有被消费, 队列已满
+			return false
+		}
+		pos = atomic.LoadUint64(&q.enqueuePos)
+	}
+}
+
+// TryPop 取出元素, 队列空时返回false
+func (q *MPMCQueue[T]) TryPop() (T, bool) {
+	pos := atomic.LoadUint64(&q.dequeuePos)
+	for {
+		cell := &q.buffer[pos&q.mask]
+		seq := atomic.LoadUint64(&cell.sequence)
+		switch dif := int64(seq - (pos + 1)); {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&q.dequeuePos, pos, pos+1) {
+				item := cell.data
+				var zero T
+				cell.data = zero
+				atomic.StoreUint64(&cell.sequence, pos+q.mask+1)
+				return item, true
+			}
+		case dif < 0:
+			// 槽位还没有被写入, 队列为空
+			var zero T
+			return zero, false
+		}
+		pos = atomic.LoadUint64(&q.dequeuePos)
+	}
+}
+
+// Push 放入元素, 队列满时让出CPU直到有空位
+func (q *MPMCQueue[T]) Push(item T) {
+	for !q.TryPush(item) {
+		runtime.Gosched()
+	}
+}
+
+// Pop 取出元素, 队列空时返回false
+func (q *MPMCQueue[T]) Pop() (T, bool) {
+	return q.TryPop()
+}
+
+// PopMany 最多取出 count 个元素
+func (q *MPMCQueue[T]) PopMany(count int64) ([]T, bool) {
+	var items []T
+	for int64(len(items)) < count {
+		item, ok := q.TryPop()
+		if !ok {
+			break
+		}
+		items = append(items, item)
+	}
+	return items, len(items) > 0
+}
+
+// Length 元素数, 并发读写时是近似值
+func (q *MPMCQueue[T]) Length() int64 {
+	dequeue := atomic.LoadUint64(&q.dequeuePos)
+	enqueue := atomic.LoadUint64(&q.enqueuePos)
+	if enqueue < dequeue {
+		return 0
+	}
+	if n := enqueue - dequeue; n < uint64(len(q.buffer)) {
+		return int64(n)
+	}
+	return int64(len(q.buffer))
+}
+
+func (q *MPMCQueue[T]) IsEmpty() bool {
+	return q.Length() == 0
+}
+
+// Cap 最多容纳的元素数
+func (q *MPMCQueue[T]) Cap() int64 {
+	return int64(len(q.buffer))
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	_ Queue        = (*MPMCQueue[interface{}])(nil)
+	_ QueueOf[int] = (*MPMCQueue[int])(nil)
+)
+
+func TestMPMCQueue(t *testing.T) {
+	assert := assert.New(t)
+	q := NewMPMCQueue[int](3)
+	assert.Equal(int64(4), q.Cap())
+	_, ok := q.Pop()
+	assert.False(ok)
+
+	for i := 0; i < 4; i++ {
+		assert.True(q.TryPush(i))
+	}
+	assert.False(q.TryPush(4))
+	assert.Equal(int64(4), q.Length())
+
+	v, ok := q.Pop()
+	assert.True(ok)
+	assert.Equal(0, v)
+	assert.True(q.TryPush(4))
+
+	vs, ok := q.PopMany(10)
+	assert.True(ok)
+	assert.Equal([]int{1, 2, 3, 4}, vs)
+	assert.True(q.IsEmpty())
+	_, ok = q.PopMany(10)
+	assert.False(ok)
+}
+
+func TestMPMCQueueInterface(t *testing.T) {
+	assert := assert.New(t)
+	q := NewMPMC(10)
+	for i := 0; i < 100; i++ {
+		q.Push("hello")
+		res, ok := q.Pop()
+		assert.True(ok)
+		assert.Equal("hello", res)
+	}
+	assert.True(q.IsEmpty())
+}
+
+func TestMPMCQueueConsistency(t *testing.T) {
+	assert := assert.New(t)
+	const (
+		producers = 8
+		consumers = 8
+		perWorker = 10000
+	)
+	q := NewMPMCQueue[int](64)
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				q.Push(p*perWorker + i)
+			}
+		}(p)
+	}
+
+	seen := make([][]int, consumers)
+	for c := 0; c < consumers; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			for len(seen[c]) < perWorker {
+				if v, ok := q.Pop(); ok {
+					seen[c] = append(seen[c], v)
+				} else {
+					runtime.Gosched()
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	all := make([]bool, producers*perWorker)
+	for _, vs := range seen {
+		// 同一个生产者的元素按顺序出队
+		last := make(map[int]int)
+		for _, v := range vs {
+			assert.False(all[v])
+			all[v] = true
+			p := v / perWorker
+			if prev, ok := last[p]; ok {
+				assert.True(prev < v)
+			}
+			last[p] = v
+		}
+	}
+	for _, ok := range all {
+		assert.True(ok)
+	}
+	assert.True(q.IsEmpty())
+}
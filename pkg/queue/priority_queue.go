@@ -0,0 +1,149 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// Element PriorityQueue 中元素的句柄, 用于 Update 和 Remove
+type Element[T any] struct {
+	value T
+	index int // 在堆中的下标, 不在队列中时为-1
+}
+
+// Value 元素的值, 不要与 Update 并发调用
+func (e *Element[T]) Value() T {
+	return e.value
+}
+
+// elementHeap 最小堆, 实现 heap.Interface, 非协程安全
+type elementHeap[T any] struct {
+	items []*Element[T]
+	less  func(a, b T) bool
+}
+
+func (h *elementHeap[T]) Len() int { return len(h.items) }
+
+func (h *elementHeap[T]) Less(i, j int) bool { return h.less(h.items[i].value, h.items[j].value) }
+
+func (h *elementHeap[T]) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *elementHeap[T]) Push(x interface{}) {
+	e := x.(*Element[T])
+	e.index = len(h.items)
+	h.items = append(h.items, e)
+}
+
+func (h *elementHeap[T]) Pop() interface{} {
+	n := len(h.items) - 1
+	e := h.items[n]
+	h.items[n] = nil
+	h.items = h.items[:n]
+	e.index = -1
+	return e
+}
+
+// peek 堆顶元素, 堆为空时返回nil
+func (h *elementHeap[T]) peek() *Element[T] {
+	if len(h.items) == 0 {
+		return nil
+	}
+	return h.items[0]
+}
+
+// contains e 是否在当前堆中
+func (h *elementHeap[T]) contains(e *Element[T]) bool {
+	return e != nil && e.index >= 0 && e.index < len(h.items) && h.items[e.index] == e
+}
+
+// PriorityQueue 泛型优先级队列, less 为true的元素先出队. 通过 Push 返回的句柄可以更新或者删除元素
+type PriorityQueue[T any] struct {
+	mu   sync.Mutex
+	heap elementHeap[T]
+}
+
+// NewPriorityQueue 创建优先级队列, less(a, b) 为true时a先于b出队
+func NewPriorityQueue[T any](less func(a, b T) bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{heap: elementHeap[T]{less: less}}
+}
+
+// Push 加入元素, 返回元素的句柄
+func (q *PriorityQueue[T]) Push(value T) *Element[T] {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e := &Element[T]{value: value}
+	heap.Push(&q.heap, e)
+	return e
+}
+
+// Pop 取出优先级最高的元素, 队列为空时返回false
+func (q *PriorityQueue[T]) Pop() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.heap.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	return heap.Pop(&q.heap).(*Element[T]).value, true
+}
+
+// Peek 返回优先级最高的元素, 不取出
+func (q *PriorityQueue[T]) Peek() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if e := q.heap.peek(); e != nil {
+		return e.value, true
+	}
+	var zero T
+	return zero, false
+}
+
+// Update 更新元素的值并调整位置, 元素已经出队或者被删除时返回false
+func (q *PriorityQueue[T]) Update(e *Element[T], value T) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.heap.contains(e) {
+		return false
+	}
+	e.value = value
+	heap.Fix(&q.heap, e.index)
+	return true
+}
+
+// Remove 删除元素, 元素已经出队或者被删除时返回false
+func (q *PriorityQueue[T]) Remove(e *Element[T]) (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.heap.contains(e) {
+		var zero T
+		return zero, false
+	}
+	return heap.Remove(&q.heap, e.index).(*Element[T]).value, true
+}
+
+// Len 元素数
+func (q *PriorityQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.heap.Len()
+}
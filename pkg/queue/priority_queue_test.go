@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	assert := assert.New(t)
+	q := NewPriorityQueue(func(a, b int) bool { return a < b })
+	_, ok := q.Pop()
+	assert.False(ok)
+	_, ok = q.Peek()
+	assert.False(ok)
+
+	values := rand.Perm(100)
+	for _, v := range values {
+		q.Push(v)
+	}
+	assert.Equal(100, q.Len())
+	v, ok := q.Peek()
+	assert.True(ok)
+	assert.Equal(0, v)
+	for i := 0; i < 100; i++ {
+		v, ok := q.Pop()
+		assert.True(ok)
+		assert.Equal(i, v)
+	}
+	assert.Equal(0, q.Len())
+}
+
+func TestPriorityQueueUpdateRemove(t *testing.T) {
+	assert := assert.New(t)
+	type task struct {
+		name     string
+		priority int
+	}
+	q := NewPriorityQueue(func(a, b task) bool { return a.priority > b.priority })
+	a := q.Push(task{"a", 1})
+	b := q.Push(task{"b", 2})
+	c := q.Push(task{"c", 3})
+	q.Push(task{"d", 4})
+	assert.Equal("a", a.Value().name)
+
+	assert.True(q.Update(a, task{"a", 10}))
+	v, _ := q.Peek()
+	assert.Equal("a", v.name)
+
+	removed, ok := q.Remove(c)
+	assert.True(ok)
+	assert.Equal("c", removed.name)
+	_, ok = q.Remove(c)
+	assert.False(ok)
+	assert.False(q.Update(c, task{"c", 100}))
+
+	var names []string
+	for q.Len() > 0 {
+		v, _ := q.Pop()
+		names = append(names, v.name)
+	}
+	assert.Equal([]string{"a", "d", "b"}, names)
+	// 出队后的句柄失效
+	assert.False(q.Update(b, task{"b", 1}))
+	_, ok = q.Remove(b)
+	assert.False(ok)
+}
+
+func TestPriorityQueueRandomUpdates(t *testing.T) {
+	assert := assert.New(t)
+	q := NewPriorityQueue(func(a, b int) bool { return a < b })
+	elements := make([]*Element[int], 200)
+	for i := range elements {
+		elements[i] = q.Push(rand.Intn(1000))
+	}
+	for i := 0; i < 100; i++ {
+		q.Update(elements[rand.Intn(len(elements))], rand.Intn(1000))
+	}
+	var want []int
+	for i, e := range elements {
+		if i%3 == 0 {
+			_, ok := q.Remove(e)
+			assert.True(ok)
+			continue
+		}
+		want = append(want, e.Value())
+	}
+	sort.Ints(want)
+
+	var got []int
+	for q.Len() > 0 {
+		v, _ := q.Pop()
+		got = append(got, v)
+	}
+	assert.Equal(want, got)
+}
@@ -87,9 +87,13 @@ func (q *RingQueue) Pop() (interface{}, bool) {
 	if q.IsEmpty() {
 		return nil, false
 	}
-	// as we are a single consumer, no other thread can have poped the items there are guaranteed to be items now
 
 	q.lock.Lock()
+	// check again under the lock, other consumers may have poped the items
+	if q.len == 0 {
+		q.lock.Unlock()
+		return nil, false
+	}
 	c := q.content
 	c.head = (c.head + 1) % c.mod
 	res := c.buffer[c.head]
@@ -105,6 +109,10 @@ func (q *RingQueue) PopMany(count int64) ([]interface{}, bool) {
 	}
 
 	q.lock.Lock()
+	if q.len == 0 {
+		q.lock.Unlock()
+		return nil, false
+	}
 	c := q.content
 
 	if count >= q.len {
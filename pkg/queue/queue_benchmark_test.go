@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// 队列容量, 每个 Benchmark 使用相同的容量
+const benchmarkQueueSize = 1024
+
+// benchmarkContention 启动 workers 对生产者和消费者, 总共传递 b.N 个元素
+func benchmarkContention(b *testing.B, workers int, push func(int), pop func() bool) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	per := b.N/workers + 1
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < per; i++ {
+				push(i)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < per; {
+				if pop() {
+					i++
+				} else {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func benchmarkMPMC(b *testing.B, workers int) {
+	q := NewMPMCQueue[int](benchmarkQueueSize)
+	benchmarkContention(b, workers, q.Push, func() bool {
+		_, ok := q.Pop()
+		return ok
+	})
+}
+
+func benchmarkRingQueue(b *testing.B, workers int) {
+	q := New(benchmarkQueueSize)
+	benchmarkContention(b, workers, func(i int) { q.Push(i) }, func() bool {
+		_, ok := q.Pop()
+		return ok
+	})
+}
+
+func benchmarkChannel(b *testing.B, workers int) {
+	ch := make(chan int, benchmarkQueueSize)
+	benchmarkContention(b, workers, func(i int) { ch <- i }, func() bool {
+		<-ch
+		return true
+	})
+}
+
+func BenchmarkMPMCQueue_1(b *testing.B)  { benchmarkMPMC(b, 1) }
+func BenchmarkMPMCQueue_4(b *testing.B)  { benchmarkMPMC(b, 4) }
+func BenchmarkMPMCQueue_16(b *testing.B) { benchmarkMPMC(b, 16) }
+
+func BenchmarkRingQueue_1(b *testing.B)  { benchmarkRingQueue(b, 1) }
+func BenchmarkRingQueue_4(b *testing.B)  { benchmarkRingQueue(b, 4) }
+func BenchmarkRingQueue_16(b *testing.B) { benchmarkRingQueue(b, 16) }
+
+func BenchmarkChannel_1(b *testing.B)  { benchmarkChannel(b, 1) }
+func BenchmarkChannel_4(b *testing.B)  { benchmarkChannel(b, 4) }
+func BenchmarkChannel_16(b *testing.B) { benchmarkChannel(b, 16) }
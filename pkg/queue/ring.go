@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ring 泛型环形缓冲区, 满时扩容为2倍, 元素数降到容量的1/4时缩容为一半, 不小于 minSize. 非协程安全
+type ring[T any] struct {
+	buffer  []T
+	head    int // 第一个元素的下标
+	size    int // 元素数
+	minSize int // 缩容的下限
+}
+
+func newRing[T any](minSize int) *ring[T] {
+	if minSize < 1 {
+		minSize = 1
+	}
+	return &ring[T]{
+		buffer:  make([]T, minSize),
+		minSize: minSize,
+	}
+}
+
+func (r *ring[T]) push(item T) {
+	if r.size == len(r.buffer) {
+		r.resize(len(r.buffer) * 2)
+	}
+	r.buffer[(r.head+r.size)%len(r.buffer)] = item
+	r.size++
+}
+
+func (r *ring[T]) pop() (T, bool) {
+	var zero T
+	if r.size == 0 {
+		return zero, false
+	}
+	item := r.buffer[r.head]
+	r.buffer[r.head] = zero
+	r.head = (r.head + 1) % len(r.buffer)
+	r.size--
+	if len(r.buffer) > r.minSize && r.size <= len(r.buffer)/4 {
+		size := len(r.buffer) / 2
+		if size < r.minSize {
+			size = r.minSize
+		}
+		r.resize(size)
+	}
+	return item, true
+}
+
+func (r *ring[T]) peek() (T, bool) {
+	if r.size == 0 {
+		var zero T
+		return zero, false
+	}
+	return r.buffer[r.head], true
+}
+
+func (r *ring[T]) resize(size int) {
+	buffer := make([]T, size)
+	for i := 0; i < r.size; i++ {
+		buffer[i] = r.buffer[(r.head+i)%len(r.buffer)]
+	}
+	r.buffer = buffer
+	r.head = 0
+}
+
+// RingQueueOf 泛型无界队列, 突发写入后会缩容
+type RingQueueOf[T any] struct {
+	len     int64
+	content *ring[T]
+	lock    sync.Mutex
+}
+
+// NewOf 创建泛型队列, initialSize 同时是缩容的下限
+func NewOf[T any](initialSize int64) QueueOf[T] {
+	return &RingQueueOf[T]{
+		content: newRing[T](int(initialSize)),
+	}
+}
+
+func (q *RingQueueOf[T]) Push(item T) {
+	q.lock.Lock()
+	q.content.push(item)
+	atomic.AddInt64(&q.len, 1)
+	q.lock.Unlock()
+}
+
+func (q *RingQueueOf[T]) Length() int64 {
+	return atomic.LoadInt64(&q.len)
+}
+
+func (q *RingQueueOf[T]) IsEmpty() bool {
+	return q.Length() == 0
+}
+
+func (q *RingQueueOf[T]) Pop() (T, bool) {
+	q.lock.Lock()
+	item, ok := q.content.pop()
+	if ok {
+		atomic.AddInt64(&q.len, -1)
+	}
+	q.lock.Unlock()
+	return item, ok
+}
+
+func (q *RingQueueOf[T]) PopMany(count int64) ([]T, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.content.size == 0 {
+		return nil, false
+	}
+	if count > int64(q.content.size) {
+		count = int64(q.content.size)
+	}
+	buffer := make([]T, count)
+	for i := range buffer {
+		buffer[i], _ = q.content.pop()
+	}
+	atomic.AddInt64(&q.len, -count)
+	return buffer, true
+}
+
+// capacity 当前缓冲区大小
+func (q *RingQueueOf[T]) capacity() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.content.buffer)
+}
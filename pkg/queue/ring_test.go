@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingQueueOf(t *testing.T) {
+	assert := assert.New(t)
+	q := NewOf[int](2)
+	_, ok := q.Pop()
+	assert.False(ok)
+	_, ok = q.PopMany(3)
+	assert.False(ok)
+
+	for i := 0; i < 10; i++ {
+		q.Push(i)
+	}
+	assert.Equal(int64(10), q.Length())
+	v, ok := q.Pop()
+	assert.True(ok)
+	assert.Equal(0, v)
+	vs, ok := q.PopMany(3)
+	assert.True(ok)
+	assert.Equal([]int{1, 2, 3}, vs)
+	vs, ok = q.PopMany(100)
+	assert.True(ok)
+	assert.Equal([]int{4, 5, 6, 7, 8, 9}, vs)
+	assert.True(q.IsEmpty())
+}
+
+func TestRingQueueOfShrink(t *testing.T) {
+	assert := assert.New(t)
+	q := NewOf[string](4).(*RingQueueOf[string])
+	for i := 0; i < 1000; i++ {
+		q.Push("burst")
+	}
+	assert.Equal(1024, q.capacity())
+	for i := 0; i < 990; i++ {
+		_, ok := q.Pop()
+		assert.True(ok)
+	}
+	assert.True(q.capacity() < 64)
+	_, _ = q.PopMany(10)
+	assert.Equal(4, q.capacity())
+	assert.True(q.IsEmpty())
+}
+
+func TestRingQueueOfConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	q := NewOf[int](1)
+	var wg sync.WaitGroup
+	for p := 0; p < 8; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				q.Push(i)
+			}
+		}()
+	}
+	var (
+		mu  sync.Mutex
+		sum int
+	)
+	for c := 0; c < 4; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 2000; {
+				if v, ok := q.Pop(); ok {
+					mu.Lock()
+					sum += v
+					mu.Unlock()
+					n++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(8*999*1000/2, sum)
+	assert.True(q.IsEmpty())
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimiter
+
+import (
+	"sync"
+)
+
+const (
+	CONCURRENCY = "CONCURRENCY"
+)
+
+// inflight 正在处理的请求数
+type inflight struct {
+	sync.Mutex
+	limit   int
+	current int
+	deleted bool // 已经被 DeleteRateLimiter 删除, 等待正在处理的请求归还名额
+}
+
+func newInflight(burst int) *inflight {
+	return &inflight{limit: inflightLimit(burst)}
+}
+
+func inflightLimit(burst int) int {
+	if burst < 1 {
+		return DefaultRate
+	}
+	return burst
+}
+
+func (f *inflight) tryAcquire() bool {
+	f.Lock()
+	defer f.Unlock()
+	if f.current >= f.limit {
+		return false
+	}
+	f.current++
+	return true
+}
+
+// release 归还名额, 返回是否已经删除且所有名额都已归还
+func (f *inflight) release() bool {
+	f.Lock()
+	defer f.Unlock()
+	if f.current > 0 {
+		f.current--
+	}
+	return f.drained()
+}
+
+func (f *inflight) drained() bool {
+	return f.deleted && f.current == 0
+}
+
+// ConcurrencyLimiters 并发限流, 限制同时处理的请求数不超过 burst, qps 不生效.
+// 请求处理完成后必须调用 Release 或者 TryAcquire 返回的释放函数归还名额.
+// DeleteRateLimiter 时还有请求在处理的, 计数保留到名额全部归还, 期间同名的请求仍然按这个计数限流, 不会超过上限
+type ConcurrencyLimiters struct {
+	sync.RWMutex
+	m map[string]*inflight
+}
+
+var (
+	concurrencyOnce    = new(sync.Once)
+	concurrencyLimiter *ConcurrencyLimiters
+)
+
+func NewConcurrencyLimiters() Limiter {
+	concurrencyOnce.Do(func() {
+		concurrencyLimiter = &ConcurrencyLimiters{m: make(map[string]*inflight)}
+	})
+	return concurrencyLimiter
+}
+
+// lookup 返回没有被删除的计数
+func (l *ConcurrencyLimiters) lookup(name string) (*inflight, bool) {
+	l.RLock()
+	f, ok := l.m[name]
+	l.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	f.Lock()
+	defer f.Unlock()
+	return f, !f.deleted
+}
+
+// add 返回名为 name 的计数, 不存在时创建. 等待归还名额的计数重新启用, 继续计算正在处理的请求
+func (l *ConcurrencyLimiters) add(name string, burst int) *inflight {
+	l.Lock()
+	defer l.Unlock()
+	f, ok := l.m[name]
+	if !ok {
+		f = newInflight(burst)
+		l.m[name] = f
+		return f
+	}
+	f.Lock()
+	if f.deleted {
+		f.deleted = false
+		f.limit = inflightLimit(burst)
+	}
+	f.Unlock()
+	return f
+}
+
+func (l *ConcurrencyLimiters) TryAccept(name string, qps, burst int) bool {
+	f, ok := l.lookup(name)
+	if !ok {
+		return l.addLimiter(name, qps, burst) //新增
+	}
+	return f.tryAcquire()
+}
+
+func (l *ConcurrencyLimiters) addLimiter(name string, qps, burst int) bool {
+	return l.add(name, burst).tryAcquire()
+}
+
+// TryAcquire 占用一个名额, 成功时返回释放函数, 释放函数多次调用只归还一次
+func (l *ConcurrencyLimiters) TryAcquire(name string, qps, burst int) (func(), bool) {
+	f, ok := l.lookup(name)
+	if !ok {
+		f = l.add(name, burst)
+	}
+	if !f.tryAcquire() {
+		return nil, false
+	}
+	once := new(sync.Once)
+	return func() { once.Do(func() { l.release(name, f) }) }, true
+}
+
+// Release 归还 TryAccept 占用的名额
+func (l *ConcurrencyLimiters) Release(name string) {
+	l.RLock()
+	f, ok := l.m[name]
+	l.RUnlock()
+	if ok {
+		l.release(name, f)
+	}
+}
+
+// release 归还名额, 已经删除的计数在名额全部归还后移除
+func (l *ConcurrencyLimiters) release(name string, f *inflight) {
+	if !f.release() {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	if l.m[name] != f {
+		return
+	}
+	f.Lock()
+	if f.drained() {
+		delete(l.m, name)
+	}
+	f.Unlock()
+}
+
+// InFlight 返回正在处理的请求数
+func (l *ConcurrencyLimiters) InFlight(name string) int {
+	l.RLock()
+	f, ok := l.m[name]
+	l.RUnlock()
+	if !ok {
+		return 0
+	}
+	f.Lock()
+	defer f.Unlock()
+	return f.current
+}
+
+func (l *ConcurrencyLimiters) UpdateRateLimit(name string, qps, burst int) {
+	f := l.add(name, burst)
+	f.Lock()
+	f.limit = inflightLimit(burst)
+	f.Unlock()
+}
+
+// DeleteRateLimiter 删除限流. 还有请求在处理时保留计数, 名额全部归还后移除
+func (l *ConcurrencyLimiters) DeleteRateLimiter(name string) {
+	l.Lock()
+	defer l.Unlock()
+	f, ok := l.m[name]
+	if !ok {
+		return
+	}
+	f.Lock()
+	if f.current == 0 {
+		delete(l.m, name)
+	} else {
+		f.deleted = true
+	}
+	f.Unlock()
+}
+
+func init() {
+	Register(CONCURRENCY, NewConcurrencyLimiters)
+}
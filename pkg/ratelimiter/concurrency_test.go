@@ -0,0 +1,137 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimiter
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Concurrency(t *testing.T) {
+	assert := assert.New(t)
+	l := NewConcurrencyLimiters().(*ConcurrencyLimiters)
+	defer l.DeleteRateLimiter("inflight")
+
+	assert.Equal(2, acceptN(l, "inflight", 0, 2, 5))
+	assert.Equal(2, l.InFlight("inflight"))
+	l.Release("inflight")
+	assert.True(l.TryAccept("inflight", 0, 2))
+	assert.False(l.TryAccept("inflight", 0, 2))
+
+	l.Release("inflight")
+	l.Release("inflight")
+	l.Release("inflight")
+	assert.Equal(0, l.InFlight("inflight"))
+	assert.Equal(0, l.InFlight("empty"))
+	l.Release("empty")
+}
+
+func Test_ConcurrencyTryAcquire(t *testing.T) {
+	assert := assert.New(t)
+	l := NewConcurrencyLimiters().(*ConcurrencyLimiters)
+	defer l.DeleteRateLimiter("acquire")
+
+	release, ok := l.TryAcquire("acquire", 0, 1)
+	if !assert.True(ok) {
+		return
+	}
+	_, ok = l.TryAcquire("acquire", 0, 1)
+	assert.False(ok)
+
+	// 多次调用只归还一次
+	release()
+	release()
+	assert.Equal(0, l.InFlight("acquire"))
+
+	l.UpdateRateLimit("acquire", 0, 3)
+	assert.Equal(3, acceptN(l, "acquire", 0, 1, 5))
+	for i := 0; i < 3; i++ {
+		l.Release("acquire")
+	}
+	assert.Equal(0, l.InFlight("acquire"))
+}
+
+func Test_ConcurrencyParallel(t *testing.T) {
+	assert := assert.New(t)
+	l := NewConcurrencyLimiters().(*ConcurrencyLimiters)
+	defer l.DeleteRateLimiter("parallel")
+
+	var (
+		mu      sync.Mutex
+		current int
+		peak    int
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				release, ok := l.TryAcquire("parallel", 0, 4)
+				if !ok {
+					continue
+				}
+				mu.Lock()
+				current++
+				if current > peak {
+					peak = current
+				}
+				mu.Unlock()
+				mu.Lock()
+				current--
+				mu.Unlock()
+				release()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(peak, 4)
+	assert.Equal(0, l.InFlight("parallel"))
+}
+
+func Test_ConcurrencyDeleteInFlight(t *testing.T) {
+	assert := assert.New(t)
+	l := NewConcurrencyLimiters().(*ConcurrencyLimiters)
+	defer l.DeleteRateLimiter("delete")
+
+	release, ok := l.TryAcquire("delete", 0, 2)
+	if !assert.True(ok) {
+		return
+	}
+	assert.True(l.TryAccept("delete", 0, 2))
+
+	// 删除后正在处理的请求仍然计数, 不会超过上限
+	l.DeleteRateLimiter("delete")
+	assert.Equal(2, l.InFlight("delete"))
+	assert.False(l.TryAccept("delete", 0, 2))
+
+	// 名额全部归还后移除
+	l.DeleteRateLimiter("delete")
+	release()
+	l.Release("delete")
+	assert.Equal(0, l.InFlight("delete"))
+	l.RLock()
+	_, ok = l.m["delete"]
+	l.RUnlock()
+	assert.False(ok)
+	assert.Equal(2, acceptN(l, "delete", 0, 2, 5))
+	l.Release("delete")
+	l.Release("delete")
+	assert.Equal(0, l.InFlight("delete"))
+}
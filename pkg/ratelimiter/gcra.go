@@ -0,0 +1,239 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	GCRA = "GCRA"
+)
+
+// gcraState 通用信元速率算法(GCRA)状态, 只记录理论到达时间
+type gcraState struct {
+	sync.Mutex
+	interval  int64 // 两个请求之间的间隔(纳秒), 即 1s/qps
+	tolerance int64 // 允许提前到达的时间(纳秒), 即 interval*(burst-1)
+	burst     int   // 令牌桶容量
+	tat       int64 // 理论到达时间(纳秒)
+}
+
+func newGCRAState(qps, burst int) *gcraState {
+	g := &gcraState{}
+	g.setRate(qps, burst)
+	return g
+}
+
+func (g *gcraState) setRate(qps, burst int) {
+	if qps < 1 {
+		qps = DefaultRate
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	g.interval = int64(time.Second) / int64(qps)
+	g.tolerance = g.interval * int64(burst-1)
+	g.burst = burst
+}
+
+func (g *gcraState) tryAccept(now int64) bool {
+	g.Lock()
+	defer g.Unlock()
+	tat := g.tat
+	if tat < now {
+		tat = now
+	}
+	if tat-now > g.tolerance {
+		return false
+	}
+	g.tat = tat + g.interval
+	return true
+}
+
+// allowN 申请 n 个令牌, n 个令牌全部可用时才放行
+func (g *gcraState) allowN(now int64, n int) (Result, error) {
+	g.Lock()
+	defer g.Unlock()
+	if n < 1 {
+		return g.result(now, false, 0), ErrLimiterInvalidN
+	}
+	if n > g.burst {
+		return g.result(now, false, 0), ErrLimiterExceedsBurst
+	}
+	tat := g.tat
+	if tat < now {
+		tat = now
+	}
+	// n 个令牌全部可用的时间
+	at := tat + int64(n)*g.interval - g.tolerance - g.interval
+	if at > now {
+		return g.result(now, false, at-now), nil
+	}
+	g.tat = tat + int64(n)*g.interval
+	return g.result(now, true, 0), nil
+}
+
+// reserve 预留 n 个令牌, 返回可以使用的时间
+func (g *gcraState) reserve(now int64, n int) (*Reservation, error) {
+	g.Lock()
+	defer g.Unlock()
+	if n < 1 {
+		return nil, ErrLimiterInvalidN
+	}
+	if n > g.burst {
+		return nil, ErrLimiterExceedsBurst
+	}
+	tat := g.tat
+	if tat < now {
+		tat = now
+	}
+	cost := int64(n) * g.interval
+	at := tat + cost - g.tolerance - g.interval
+	if at < now {
+		at = now
+	}
+	g.tat = tat + cost
+	return &Reservation{
+		at: time.Unix(0, at),
+		cancel: func(t time.Time) {
+			// 已经到了使用时间的令牌视为已经使用, 不再归还
+			if t.UnixNano() >= at {
+				return
+			}
+			g.Lock()
+			g.tat -= cost
+			g.Unlock()
+		},
+	}, nil
+}
+
+// result 调用方需持有锁
+func (g *gcraState) result(now int64, allowed bool, retryAfter int64) Result {
+	r := Result{Allowed: allowed, Limit: g.burst, Remaining: g.burst, RetryAfter: time.Duration(retryAfter)}
+	if g.interval == 0 || g.tat <= now {
+		return r
+	}
+	r.ResetAfter = time.Duration(g.tat - now)
+	r.Remaining = int((g.tolerance + g.interval - (g.tat - now)) / g.interval)
+	if r.Remaining < 0 {
+		r.Remaining = 0
+	}
+	return r
+}
+
+// GCRALimiters GCRA限流, 效果等同于速率为 qps, 容量为 burst 的令牌桶, 每个 name 只需要保存一个时间戳.
+// 实现了 Reserver, 支持一次申请多个令牌, 阻塞等待和预留令牌
+type GCRALimiters struct {
+	sync.RWMutex
+	m map[string]*gcraState
+}
+
+var (
+	gcraOnce    = new(sync.Once)
+	gcraLimiter *GCRALimiters
+)
+
+func NewGCRALimiters() Limiter {
+	gcraOnce.Do(func() {
+		gcraLimiter = &GCRALimiters{m: make(map[string]*gcraState)}
+	})
+	return gcraLimiter
+}
+
+func (l *GCRALimiters) TryAccept(name string, qps, burst int) bool {
+	l.RLock()
+	g, ok := l.m[name]
+	l.RUnlock()
+	if !ok {
+		return l.addLimiter(name, qps, burst) //新增
+	}
+	return g.tryAccept(nowFunc().UnixNano())
+}
+
+func (l *GCRALimiters) get(name string) (*gcraState, bool) {
+	l.RLock()
+	defer l.RUnlock()
+	g, ok := l.m[name]
+	return g, ok
+}
+
+// AllowN 申请 n 个令牌, 不阻塞
+func (l *GCRALimiters) AllowN(name string, n int) (Result, error) {
+	g, ok := l.get(name)
+	if !ok {
+		return Result{}, ErrLimiterNotFound
+	}
+	return g.allowN(nowFunc().UnixNano(), n)
+}
+
+// Reserve 预留 n 个令牌, 调用方需要等待 Delay 之后再使用, 不使用时调用 Cancel 归还
+func (l *GCRALimiters) Reserve(name string, n int) (*Reservation, error) {
+	g, ok := l.get(name)
+	if !ok {
+		return nil, ErrLimiterNotFound
+	}
+	return g.reserve(nowFunc().UnixNano(), n)
+}
+
+// Wait 阻塞直到拿到 n 个令牌; ctx 结束时返回 ctx.Err(), ctx 的截止时间之前拿不到时立即返回 ErrLimiterWaitExceedsDeadline
+func (l *GCRALimiters) Wait(ctx context.Context, name string, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r, err := l.Reserve(name, n)
+	if err != nil {
+		return err
+	}
+	return waitReservation(ctx, r)
+}
+
+func (l *GCRALimiters) addLimiter(name string, qps, burst int) bool {
+	l.Lock()
+	g, ok := l.m[name]
+	if !ok {
+		g = newGCRAState(qps, burst)
+		l.m[name] = g
+	}
+	l.Unlock()
+	return g.tryAccept(nowFunc().UnixNano())
+}
+
+func (l *GCRALimiters) UpdateRateLimit(name string, qps, burst int) {
+	l.Lock()
+	defer l.Unlock()
+	g, ok := l.m[name]
+	if !ok {
+		l.m[name] = newGCRAState(qps, burst)
+		return
+	}
+	g.Lock()
+	g.setRate(qps, burst)
+	g.Unlock()
+}
+
+func (l *GCRALimiters) DeleteRateLimiter(name string) {
+	l.Lock()
+	delete(l.m, name)
+	l.Unlock()
+}
+
+func init() {
+	Register(GCRA, NewGCRALimiters)
+}
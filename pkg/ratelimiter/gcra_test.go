@@ -0,0 +1,57 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GCRA(t *testing.T) {
+	assert := assert.New(t)
+	advance := fakeClock(t)
+	l := NewGCRALimiters()
+	defer l.DeleteRateLimiter("gcra")
+
+	// 先放行 burst 个, 之后每 1s/qps 放行一个
+	assert.Equal(3, acceptN(l, "gcra", 10, 3, 10))
+	advance(50 * time.Millisecond)
+	assert.Equal(0, acceptN(l, "gcra", 10, 3, 10))
+	advance(50 * time.Millisecond)
+	assert.Equal(1, acceptN(l, "gcra", 10, 3, 10))
+	advance(time.Second)
+	assert.Equal(3, acceptN(l, "gcra", 10, 3, 10))
+
+	l.UpdateRateLimit("gcra", 10, 5)
+	advance(100 * time.Millisecond)
+	assert.Equal(3, acceptN(l, "gcra", 10, 3, 10))
+
+	l.UpdateRateLimit("gcra.new", 1, 1)
+	defer l.DeleteRateLimiter("gcra.new")
+	assert.Equal(1, acceptN(l, "gcra.new", 100, 100, 10))
+}
+
+func Test_GCRADefaultRate(t *testing.T) {
+	assert := assert.New(t)
+	fakeClock(t)
+	l := NewGCRALimiters()
+	defer l.DeleteRateLimiter("gcra.default")
+
+	assert.Equal(1000, acceptN(l, "gcra.default", 0, 0, 1000))
+}
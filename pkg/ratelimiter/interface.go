@@ -17,12 +17,17 @@ limitations under the License.
 package ratelimiter
 
 import (
+	"context"
 	"fmt"
 )
 
 var (
 	ErrLimiterRegisterAdapterNil    = fmt.Errorf("Limiter: Register adapter is nil")
 	ErrLimiterDoubleRegisterAdapter = fmt.Errorf("Limiter: Register called twice for adapter")
+	ErrLimiterNotFound              = fmt.Errorf("Limiter: limiter not found")
+	ErrLimiterExceedsBurst          = fmt.Errorf("Limiter: request exceeds burst")
+	ErrLimiterInvalidN              = fmt.Errorf("Limiter: n must be at least 1")
+	ErrLimiterWaitExceedsDeadline   = fmt.Errorf("Limiter: wait would exceed context deadline")
 )
 
 const (
@@ -36,6 +41,16 @@ type Limiter interface {
 	DeleteRateLimiter(name string)               // 清理 limiter
 }
 
+// Reserver 支持一次申请 n 个令牌, 以及阻塞等待和预留令牌的限流器.
+// name 对应的限流器需要先通过 TryAccept 或者 UpdateRateLimit 创建, 否则返回 ErrLimiterNotFound; n 小于1时返回 ErrLimiterInvalidN.
+// 令牌桶类的 RATELIMITER 和 GCRA 实现了 Reserver
+type Reserver interface {
+	Limiter
+	AllowN(name string, n int) (Result, error)          // 申请 n 个令牌, 不阻塞, Result 中带有剩余令牌数和重试时间
+	Reserve(name string, n int) (*Reservation, error)   // 预留 n 个令牌, 返回需要等待的时间, 不使用时可以 Cancel 归还
+	Wait(ctx context.Context, name string, n int) error // 阻塞直到拿到 n 个令牌, 或者 ctx 结束
+}
+
 type Instance func() Limiter
 
 var adapters = make(map[string]Instance)
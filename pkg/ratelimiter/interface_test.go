@@ -46,6 +46,22 @@ func Test_HasRegister(t *testing.T) {
 	ok := HasRegister("RATELIMITER")
 	assert.True(ok)
 
+	for _, name := range []string{SLIDINGWINDOWLOG, SLIDINGWINDOWCOUNTER, GCRA, CONCURRENCY} {
+		assert.True(HasRegister(name))
+	}
+
 	ok = HasRegister("empty")
 	assert.False(ok)
 }
+
+func Test_ReserverAdapters(t *testing.T) {
+	assert := assert.New(t)
+	for _, name := range []string{RATELIMITER, GCRA} {
+		_, ok := adapters[name]().(Reserver)
+		assert.True(ok, name)
+	}
+	for _, name := range []string{SLIDINGWINDOWLOG, SLIDINGWINDOWCOUNTER, CONCURRENCY} {
+		_, ok := adapters[name]().(Reserver)
+		assert.False(ok, name)
+	}
+}
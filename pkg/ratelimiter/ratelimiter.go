@@ -17,18 +17,151 @@ limitations under the License.
 package ratelimiter
 
 import (
+	"context"
 	"sync"
-
-	"k8s.io/client-go/util/flowcontrol"
+	"time"
 )
 
 const (
 	RATELIMITER = "RATELIMITER"
 )
 
+// tokenBucket 令牌桶, 每 interval 纳秒生成一个令牌, 最多存放 burst 个, 初始是满的.
+// 令牌数乘以 interval 记录为 credit, 用整数纳秒计算, 避免浮点误差
+type tokenBucket struct {
+	sync.Mutex
+	interval int64 // 生成一个令牌的时间(纳秒), 即 1s/qps
+	burst    int   // 令牌桶容量
+	credit   int64 // 桶中的令牌数 * interval, 为负时表示被预留透支
+	last     int64 // 上次补充令牌的时间(纳秒)
+}
+
+func newTokenBucket(now int64, qps, burst int) *tokenBucket {
+	b := &tokenBucket{last: now}
+	b.setRate(qps, burst)
+	b.credit = b.capacity()
+	return b
+}
+
+func (b *tokenBucket) setRate(qps, burst int) {
+	if qps < 1 {
+		qps = DefaultRate
+	}
+	if burst < 0 {
+		burst = 0
+	}
+	b.interval = int64(time.Second) / int64(qps)
+	b.burst = burst
+}
+
+// update 修改速率和容量, 保留桶中的令牌数
+func (b *tokenBucket) update(now int64, qps, burst int) {
+	b.Lock()
+	defer b.Unlock()
+	b.advance(now)
+	credit, interval := b.credit, b.interval
+	b.setRate(qps, burst)
+	if interval == 0 {
+		b.credit = b.capacity()
+	} else {
+		// 按新的 interval 换算, 分开计算整数和小数部分避免溢出
+		b.credit = credit/interval*b.interval + credit%interval*b.interval/interval
+	}
+	b.advance(now)
+}
+
+func (b *tokenBucket) capacity() int64 {
+	return b.interval * int64(b.burst)
+}
+
+// advance 补充 last 到 now 之间生成的令牌, 调用方需持有锁
+func (b *tokenBucket) advance(now int64) {
+	if now > b.last {
+		b.credit += now - b.last
+		b.last = now
+	}
+	if c := b.capacity(); b.credit > c {
+		b.credit = c
+	}
+}
+
+func (b *tokenBucket) tryAccept(now int64) bool {
+	r, err := b.allowN(now, 1)
+	return err == nil && r.Allowed
+}
+
+// allowN 申请 n 个令牌, n 个令牌全部可用时才放行
+func (b *tokenBucket) allowN(now int64, n int) (Result, error) {
+	b.Lock()
+	defer b.Unlock()
+	if n < 1 {
+		return b.result(false, 0), ErrLimiterInvalidN
+	}
+	if n > b.burst {
+		return b.result(false, 0), ErrLimiterExceedsBurst
+	}
+	b.advance(now)
+	cost := int64(n) * b.interval
+	if b.credit < cost {
+		return b.result(false, cost-b.credit), nil
+	}
+	b.credit -= cost
+	return b.result(true, 0), nil
+}
+
+// reserve 预留 n 个令牌, 令牌不够时透支, 返回可以使用的时间
+func (b *tokenBucket) reserve(now int64, n int) (*Reservation, error) {
+	b.Lock()
+	defer b.Unlock()
+	if n < 1 {
+		return nil, ErrLimiterInvalidN
+	}
+	if n > b.burst {
+		return nil, ErrLimiterExceedsBurst
+	}
+	b.advance(now)
+	cost := int64(n) * b.interval
+	b.credit -= cost
+	at := now
+	if b.credit < 0 {
+		at -= b.credit
+	}
+	return &Reservation{
+		at: time.Unix(0, at),
+		cancel: func(t time.Time) {
+			// 已经到了使用时间的令牌视为已经使用, 不再归还
+			if t.UnixNano() >= at {
+				return
+			}
+			b.Lock()
+			b.advance(t.UnixNano())
+			b.credit += cost
+			// 归还后不超过容量
+			b.advance(t.UnixNano())
+			b.Unlock()
+		},
+	}, nil
+}
+
+// result 调用方需持有锁
+func (b *tokenBucket) result(allowed bool, retryAfter int64) Result {
+	r := Result{Allowed: allowed, Limit: b.burst, Remaining: b.burst, RetryAfter: time.Duration(retryAfter)}
+	if b.interval == 0 || b.credit >= b.capacity() {
+		return r
+	}
+	r.ResetAfter = time.Duration(b.capacity() - b.credit)
+	r.Remaining = int(b.credit / b.interval)
+	if r.Remaining < 0 {
+		r.Remaining = 0
+	}
+	return r
+}
+
+// RateLimiters 令牌桶限流, 速率为 qps, 容量为 burst.
+// 实现了 Reserver, 支持一次申请多个令牌, 阻塞等待和预留令牌
 type RateLimiters struct {
 	sync.RWMutex //协程 安全
-	m            map[string]flowcontrol.RateLimiter
+	m            map[string]*tokenBucket
 }
 
 var (
@@ -38,7 +171,7 @@ var (
 
 func NewRateLimiters() Limiter {
 	once.Do(func() {
-		qpsLimiter = &RateLimiters{m: make(map[string]flowcontrol.RateLimiter)}
+		qpsLimiter = &RateLimiters{m: make(map[string]*tokenBucket)}
 	})
 	return qpsLimiter
 }
@@ -51,27 +184,70 @@ func (l *RateLimiters) TryAccept(name string, qps, burst int) bool {
 		return l.addLimiter(name, qps, burst) //新增
 	}
 	l.RUnlock()
-	return limiter.TryAccept()
+	return limiter.tryAccept(nowFunc().UnixNano())
 
 }
 
-func (l *RateLimiters) addLimiter(name string, qps, burst int) bool {
-	var bucketSize int
-	if qps >= 1 {
-		bucketSize = qps
-	} else {
-		bucketSize = DefaultRate
+func (l *RateLimiters) get(name string) (*tokenBucket, bool) {
+	l.RLock()
+	defer l.RUnlock()
+	b, ok := l.m[name]
+	return b, ok
+}
+
+// AllowN 申请 n 个令牌, 不阻塞
+func (l *RateLimiters) AllowN(name string, n int) (Result, error) {
+	b, ok := l.get(name)
+	if !ok {
+		return Result{}, ErrLimiterNotFound
+	}
+	return b.allowN(nowFunc().UnixNano(), n)
+}
+
+// Reserve 预留 n 个令牌, 调用方需要等待 Delay 之后再使用, 不使用时调用 Cancel 归还
+func (l *RateLimiters) Reserve(name string, n int) (*Reservation, error) {
+	b, ok := l.get(name)
+	if !ok {
+		return nil, ErrLimiterNotFound
 	}
+	return b.reserve(nowFunc().UnixNano(), n)
+}
+
+// Wait 阻塞直到拿到 n 个令牌; ctx 结束时返回 ctx.Err(), ctx 的截止时间之前拿不到时立即返回 ErrLimiterWaitExceedsDeadline
+func (l *RateLimiters) Wait(ctx context.Context, name string, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r, err := l.Reserve(name, n)
+	if err != nil {
+		return err
+	}
+	return waitReservation(ctx, r)
+}
+
+func (l *RateLimiters) addLimiter(name string, qps, burst int) bool {
+	now := nowFunc().UnixNano()
 	l.Lock()
 	// 新建token bucket
-	r := flowcontrol.NewTokenBucketRateLimiter(float32(bucketSize), burst)
-	l.m[name] = r
+	b, ok := l.m[name]
+	if !ok {
+		b = newTokenBucket(now, qps, burst)
+		l.m[name] = b
+	}
 	l.Unlock()
-	return r.TryAccept()
+	return b.tryAccept(now)
 }
 
 func (l *RateLimiters) UpdateRateLimit(name string, qps, burst int) {
-	l.addLimiter(name, qps, burst)
+	now := nowFunc().UnixNano()
+	l.Lock()
+	defer l.Unlock()
+	b, ok := l.m[name]
+	if !ok {
+		l.m[name] = newTokenBucket(now, qps, burst)
+		return
+	}
+	b.update(now, qps, burst)
 }
 
 func (l *RateLimiters) DeleteRateLimiter(name string) {
@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimiter
+
+import (
+	"testing"
+)
+
+var benchmarkLimiters = []string{RATELIMITER, SLIDINGWINDOWLOG, SLIDINGWINDOWCOUNTER, GCRA}
+
+func BenchmarkTryAccept(b *testing.B) {
+	for _, name := range benchmarkLimiters {
+		l := adapters[name]()
+		b.Run(name, func(b *testing.B) {
+			defer l.DeleteRateLimiter("benchmark")
+			for i := 0; i < b.N; i++ {
+				l.TryAccept("benchmark", 1000, 100)
+			}
+		})
+	}
+}
+
+func BenchmarkTryAcceptParallel(b *testing.B) {
+	for _, name := range benchmarkLimiters {
+		l := adapters[name]()
+		b.Run(name, func(b *testing.B) {
+			defer l.DeleteRateLimiter("benchmark.parallel")
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					l.TryAccept("benchmark.parallel", 1000, 100)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkConcurrencyTryAcquire(b *testing.B) {
+	l := NewConcurrencyLimiters().(*ConcurrencyLimiters)
+	defer l.DeleteRateLimiter("benchmark")
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if release, ok := l.TryAcquire("benchmark", 0, 64); ok {
+				release()
+			}
+		}
+	})
+}
@@ -63,3 +63,45 @@ func Test_RateLimitersTryAccept(t *testing.T) {
 		}
 	}
 }
+
+func Test_TokenBucket(t *testing.T) {
+	assert := assert.New(t)
+	advance := fakeClock(t)
+	l := NewRateLimiters()
+	defer l.DeleteRateLimiter("bucket")
+
+	// 初始是满的, 之后每 1s/qps 生成一个令牌
+	assert.Equal(3, acceptN(l, "bucket", 10, 3, 10))
+	advance(50 * time.Millisecond)
+	assert.Equal(0, acceptN(l, "bucket", 10, 3, 10))
+	advance(50 * time.Millisecond)
+	assert.Equal(1, acceptN(l, "bucket", 10, 3, 10))
+	advance(time.Second)
+	assert.Equal(3, acceptN(l, "bucket", 10, 3, 10))
+
+	// 修改速率保留桶中的令牌数
+	l.UpdateRateLimit("bucket", 10, 5)
+	advance(400 * time.Millisecond)
+	l.UpdateRateLimit("bucket", 20, 5)
+	assert.Equal(4, acceptN(l, "bucket", 20, 5, 10))
+	advance(50 * time.Millisecond)
+	assert.Equal(1, acceptN(l, "bucket", 20, 5, 10))
+
+	// 新建的限流器不消耗令牌
+	l.UpdateRateLimit("bucket.new", 1, 2)
+	defer l.DeleteRateLimiter("bucket.new")
+	assert.Equal(2, acceptN(l, "bucket.new", 100, 100, 10))
+
+	// burst 为0时不放行
+	defer l.DeleteRateLimiter("bucket.zero")
+	assert.Equal(0, acceptN(l, "bucket.zero", 10, 0, 10))
+}
+
+func Test_TokenBucketDefaultRate(t *testing.T) {
+	assert := assert.New(t)
+	fakeClock(t)
+	l := NewRateLimiters()
+	defer l.DeleteRateLimiter("bucket.default")
+
+	assert.Equal(1000, acceptN(l, "bucket.default", 0, 1, 1000))
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Result 一次限流判断的结果
+type Result struct {
+	Allowed    bool          // 是否放行
+	Limit      int           // 令牌桶容量, 即 burst
+	Remaining  int           // 当前剩余可以立即使用的令牌数
+	RetryAfter time.Duration // 被拒绝时, 需要等待多久才能再次申请成功; 放行时为0
+	ResetAfter time.Duration // 多久之后令牌桶恢复满
+}
+
+// SetHeaders 设置 X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset 响应头, 被拒绝时额外设置 Retry-After, 时间单位为秒(向上取整)
+func (r Result) SetHeaders(h http.Header) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(r.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(r.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(ceilSeconds(r.ResetAfter), 10))
+	if !r.Allowed {
+		h.Set("Retry-After", strconv.FormatInt(ceilSeconds(r.RetryAfter), 10))
+	}
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
+
+// Reservation 预留的令牌, 到 Delay 之后才能使用
+type Reservation struct {
+	once   sync.Once
+	at     time.Time // 可以使用预留令牌的时间
+	cancel func(now time.Time)
+}
+
+// Delay 返回还需要等待的时间, 为0时可以立即使用
+func (r *Reservation) Delay() time.Duration {
+	return r.DelayFrom(nowFunc())
+}
+
+// DelayFrom 返回从 now 开始还需要等待的时间
+func (r *Reservation) DelayFrom(now time.Time) time.Duration {
+	if d := r.at.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Cancel 放弃预留的令牌, 还没到使用时间的令牌会归还给限流器, 多次调用只生效一次
+func (r *Reservation) Cancel() {
+	r.once.Do(func() {
+		if r.cancel != nil {
+			r.cancel(nowFunc())
+		}
+	})
+}
+
+// waitReservation 等待预留的令牌可以使用, ctx 提前结束或者截止时间早于可以使用的时间时取消预留
+func waitReservation(ctx context.Context, r *Reservation) error {
+	if deadline, ok := ctx.Deadline(); ok && r.at.After(deadline) {
+		r.Cancel()
+		return ErrLimiterWaitExceedsDeadline
+	}
+	delay := r.Delay()
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// reserverAdapters 实现了 Reserver 的限流器
+var reserverAdapters = []string{RATELIMITER, GCRA}
+
+func newReserver(t *testing.T, adapter, name string, qps, burst int) Reserver {
+	l, ok := adapters[adapter]().(Reserver)
+	assert.True(t, ok)
+	l.UpdateRateLimit(name, qps, burst)
+	t.Cleanup(func() { l.DeleteRateLimiter(name) })
+	return l
+}
+
+func Test_AllowN(t *testing.T) {
+	for _, adapter := range reserverAdapters {
+		t.Run(adapter, func(t *testing.T) {
+			assert := assert.New(t)
+			advance := fakeClock(t)
+			l := newReserver(t, adapter, "allow", 10, 5)
+
+			r, err := l.AllowN("allow", 3)
+			assert.Nil(err)
+			assert.Equal(Result{Allowed: true, Limit: 5, Remaining: 2, ResetAfter: 300 * time.Millisecond}, r)
+
+			// 剩余令牌不够时不扣减
+			r, err = l.AllowN("allow", 4)
+			assert.Nil(err)
+			assert.False(r.Allowed)
+			assert.Equal(2, r.Remaining)
+			assert.Equal(200*time.Millisecond, r.RetryAfter)
+
+			advance(200 * time.Millisecond)
+			r, err = l.AllowN("allow", 4)
+			assert.Nil(err)
+			assert.True(r.Allowed)
+			assert.Equal(0, r.Remaining)
+			assert.Equal(500*time.Millisecond, r.ResetAfter)
+
+			advance(time.Second)
+			r, err = l.AllowN("allow", 1)
+			assert.Nil(err)
+			assert.Equal(4, r.Remaining)
+
+			_, err = l.AllowN("allow", 6)
+			assert.Equal(ErrLimiterExceedsBurst, err)
+			for _, n := range []int{0, -1} {
+				r, err = l.AllowN("allow", n)
+				assert.Equal(ErrLimiterInvalidN, err)
+				assert.False(r.Allowed)
+			}
+			r, err = l.AllowN("allow", 1)
+			assert.Nil(err)
+			assert.Equal(3, r.Remaining)
+			_, err = l.AllowN("empty", 1)
+			assert.Equal(ErrLimiterNotFound, err)
+		})
+	}
+}
+
+func Test_ResultSetHeaders(t *testing.T) {
+	assert := assert.New(t)
+	h := http.Header{}
+	Result{Allowed: true, Limit: 10, Remaining: 9, ResetAfter: 100 * time.Millisecond}.SetHeaders(h)
+	assert.Equal("10", h.Get("X-RateLimit-Limit"))
+	assert.Equal("9", h.Get("X-RateLimit-Remaining"))
+	assert.Equal("1", h.Get("X-RateLimit-Reset"))
+	assert.Equal("", h.Get("Retry-After"))
+
+	h = http.Header{}
+	Result{Limit: 10, RetryAfter: 2500 * time.Millisecond, ResetAfter: 3 * time.Second}.SetHeaders(h)
+	assert.Equal("0", h.Get("X-RateLimit-Remaining"))
+	assert.Equal("3", h.Get("X-RateLimit-Reset"))
+	assert.Equal("3", h.Get("Retry-After"))
+}
+
+func Test_Reserve(t *testing.T) {
+	for _, adapter := range reserverAdapters {
+		t.Run(adapter, func(t *testing.T) {
+			assert := assert.New(t)
+			advance := fakeClock(t)
+			l := newReserver(t, adapter, "reserve", 10, 2)
+
+			r1, err := l.Reserve("reserve", 2)
+			assert.Nil(err)
+			assert.Equal(time.Duration(0), r1.Delay())
+			r2, err := l.Reserve("reserve", 2)
+			assert.Nil(err)
+			assert.Equal(200*time.Millisecond, r2.Delay())
+			r3, err := l.Reserve("reserve", 1)
+			assert.Nil(err)
+			assert.Equal(300*time.Millisecond, r3.Delay())
+
+			// 取消还没到使用时间的预留, 令牌归还
+			r3.Cancel()
+			r3.Cancel()
+			r4, err := l.Reserve("reserve", 1)
+			assert.Nil(err)
+			assert.Equal(300*time.Millisecond, r4.Delay())
+			r4.Cancel()
+
+			// 已经到了使用时间的预留不再归还
+			advance(200 * time.Millisecond)
+			assert.Equal(time.Duration(0), r2.Delay())
+			r2.Cancel()
+			r5, err := l.Reserve("reserve", 2)
+			assert.Nil(err)
+			assert.Equal(200*time.Millisecond, r5.Delay())
+
+			_, err = l.Reserve("reserve", 3)
+			assert.Equal(ErrLimiterExceedsBurst, err)
+			_, err = l.Reserve("reserve", 0)
+			assert.Equal(ErrLimiterInvalidN, err)
+			_, err = l.Reserve("empty", 1)
+			assert.Equal(ErrLimiterNotFound, err)
+		})
+	}
+}
+
+func Test_Wait(t *testing.T) {
+	for _, adapter := range reserverAdapters {
+		t.Run(adapter, func(t *testing.T) {
+			assert := assert.New(t)
+			l := newReserver(t, adapter, "wait", 100, 1)
+
+			start := time.Now()
+			for i := 0; i < 5; i++ {
+				assert.Nil(l.Wait(context.Background(), "wait", 1))
+			}
+			assert.GreaterOrEqual(time.Since(start), 35*time.Millisecond)
+
+			assert.Equal(ErrLimiterExceedsBurst, l.Wait(context.Background(), "wait", 2))
+			assert.Equal(ErrLimiterInvalidN, l.Wait(context.Background(), "wait", -1))
+			assert.Equal(ErrLimiterNotFound, l.Wait(context.Background(), "empty", 1))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			assert.Equal(context.Canceled, l.Wait(ctx, "wait", 1))
+		})
+	}
+}
+
+func Test_WaitDeadline(t *testing.T) {
+	for _, adapter := range reserverAdapters {
+		t.Run(adapter, func(t *testing.T) {
+			assert := assert.New(t)
+			l := newReserver(t, adapter, "wait.deadline", 10, 1)
+			assert.True(l.TryAccept("wait.deadline", 10, 1))
+
+			// 截止时间之前拿不到令牌, 立即返回并归还预留
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			assert.Equal(ErrLimiterWaitExceedsDeadline, l.Wait(ctx, "wait.deadline", 1))
+			assert.Less(time.Since(start), 10*time.Millisecond)
+
+			// ctx 在等待期间被取消
+			ctx, cancel = context.WithCancel(context.Background())
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				cancel()
+			}()
+			assert.Equal(context.Canceled, l.Wait(ctx, "wait.deadline", 1))
+
+			// 两次失败的等待都已经归还令牌
+			r, err := l.Reserve("wait.deadline", 1)
+			assert.Nil(err)
+			assert.LessOrEqual(r.Delay(), 100*time.Millisecond)
+			r.Cancel()
+		})
+	}
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimiter
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	SLIDINGWINDOWLOG     = "SLIDINGWINDOWLOG"
+	SLIDINGWINDOWCOUNTER = "SLIDINGWINDOWCOUNTER"
+
+	slidingWindow = int64(time.Second) // 滑动窗口长度
+)
+
+// 测试时替换时钟
+var nowFunc = time.Now
+
+// windowLog 记录最近 limit 个请求的时间戳, 环形覆盖
+type windowLog struct {
+	sync.Mutex
+	limit int
+	log   []int64 // 请求时间戳(纳秒)
+	next  int     // 下一个写入位置, 同时也是最早的一条记录
+}
+
+func newWindowLog(qps int) *windowLog {
+	w := &windowLog{}
+	w.setLimit(qps)
+	return w
+}
+
+// setLimit 调整窗口内允许的请求数, 保留最近的记录
+func (w *windowLog) setLimit(qps int) {
+	if qps < 1 {
+		w.limit, w.log, w.next = 0, nil, 0 // 不限制
+		return
+	}
+	log := make([]int64, qps)
+	n := len(w.log)
+	for i := 1; i <= n && i <= qps; i++ {
+		log[qps-i] = w.log[(w.next-i+n)%n]
+	}
+	w.limit, w.log, w.next = qps, log, 0
+}
+
+func (w *windowLog) tryAccept(now int64) bool {
+	w.Lock()
+	defer w.Unlock()
+	if w.limit == 0 {
+		return true
+	}
+	// 往前数第 limit 个请求仍在窗口内, 说明窗口已满
+	if oldest := w.log[w.next]; oldest != 0 && oldest > now-slidingWindow {
+		return false
+	}
+	w.log[w.next] = now
+	w.next = (w.next + 1) % w.limit
+	return true
+}
+
+// SlidingWindowLogLimiters 滑动窗口日志限流, 任意 1s 窗口内最多 qps 个请求, 精确但每个 name 占用 qps 个时间戳的内存
+type SlidingWindowLogLimiters struct {
+	sync.RWMutex
+	m map[string]*windowLog
+}
+
+var (
+	windowLogOnce    = new(sync.Once)
+	windowLogLimiter *SlidingWindowLogLimiters
+)
+
+func NewSlidingWindowLogLimiters() Limiter {
+	windowLogOnce.Do(func() {
+		windowLogLimiter = &SlidingWindowLogLimiters{m: make(map[string]*windowLog)}
+	})
+	return windowLogLimiter
+}
+
+func (l *SlidingWindowLogLimiters) TryAccept(name string, qps, burst int) bool {
+	l.RLock()
+	w, ok := l.m[name]
+	l.RUnlock()
+	if !ok {
+		return l.addLimiter(name, qps, burst) //新增
+	}
+	return w.tryAccept(nowFunc().UnixNano())
+}
+
+func (l *SlidingWindowLogLimiters) addLimiter(name string, qps, burst int) bool {
+	l.Lock()
+	w, ok := l.m[name]
+	if !ok {
+		w = newWindowLog(qps)
+		l.m[name] = w
+	}
+	l.Unlock()
+	return w.tryAccept(nowFunc().UnixNano())
+}
+
+func (l *SlidingWindowLogLimiters) UpdateRateLimit(name string, qps, burst int) {
+	l.Lock()
+	defer l.Unlock()
+	w, ok := l.m[name]
+	if !ok {
+		l.m[name] = newWindowLog(qps)
+		return
+	}
+	w.Lock()
+	w.setLimit(qps)
+	w.Unlock()
+}
+
+func (l *SlidingWindowLogLimiters) DeleteRateLimiter(name string) {
+	l.Lock()
+	delete(l.m, name)
+	l.Unlock()
+}
+
+// windowCounter 当前窗口和上一个窗口的计数, 按当前窗口已过去的比例加权估算
+type windowCounter struct {
+	sync.Mutex
+	limit int64
+	start int64 // 当前窗口起始时间(纳秒)
+	curr  int64 // 当前窗口计数
+	prev  int64 // 上一个窗口计数
+}
+
+func newWindowCounter(qps int) *windowCounter {
+	return &windowCounter{limit: windowCounterLimit(qps)}
+}
+
+func windowCounterLimit(qps int) int64 {
+	if qps < 1 {
+		return DefaultRate
+	}
+	return int64(qps)
+}
+
+func (w *windowCounter) tryAccept(now int64) bool {
+	w.Lock()
+	defer w.Unlock()
+	switch {
+	case now-w.start >= 2*slidingWindow:
+		w.start, w.prev, w.curr = now-now%slidingWindow, 0, 0
+	case now-w.start >= slidingWindow:
+		w.start, w.prev, w.curr = w.start+slidingWindow, w.curr, 0
+	}
+	elapsed := now - w.start
+	estimate := float64(w.prev)*float64(slidingWindow-elapsed)/float64(slidingWindow) + float64(w.curr)
+	if estimate+1 > float64(w.limit) {
+		return false
+	}
+	w.curr++
+	return true
+}
+
+// SlidingWindowCounterLimiters 滑动窗口计数限流, 用前后两个固定窗口的计数近似任意 1s 窗口内最多 qps 个请求, 每个 name 常数内存
+type SlidingWindowCounterLimiters struct {
+	sync.RWMutex
+	m map[string]*windowCounter
+}
+
+var (
+	windowCounterOnce    = new(sync.Once)
+	windowCounterLimiter *SlidingWindowCounterLimiters
+)
+
+func NewSlidingWindowCounterLimiters() Limiter {
+	windowCounterOnce.Do(func() {
+		windowCounterLimiter = &SlidingWindowCounterLimiters{m: make(map[string]*windowCounter)}
+	})
+	return windowCounterLimiter
+}
+
+func (l *SlidingWindowCounterLimiters) TryAccept(name string, qps, burst int) bool {
+	l.RLock()
+	w, ok := l.m[name]
+	l.RUnlock()
+	if !ok {
+		return l.addLimiter(name, qps, burst) //新增
+	}
+	return w.tryAccept(nowFunc().UnixNano())
+}
+
+func (l *SlidingWindowCounterLimiters) addLimiter(name string, qps, burst int) bool {
+	l.Lock()
+	w, ok := l.m[name]
+	if !ok {
+		w = newWindowCounter(qps)
+		l.m[name] = w
+	}
+	l.Unlock()
+	return w.tryAccept(nowFunc().UnixNano())
+}
+
+func (l *SlidingWindowCounterLimiters) UpdateRateLimit(name string, qps, burst int) {
+	l.Lock()
+	defer l.Unlock()
+	w, ok := l.m[name]
+	if !ok {
+		l.m[name] = newWindowCounter(qps)
+		return
+	}
+	w.Lock()
+	w.limit = windowCounterLimit(qps)
+	w.Unlock()
+}
+
+func (l *SlidingWindowCounterLimiters) DeleteRateLimiter(name string) {
+	l.Lock()
+	delete(l.m, name)
+	l.Unlock()
+}
+
+func init() {
+	Register(SLIDINGWINDOWLOG, NewSlidingWindowLogLimiters)
+	Register(SLIDINGWINDOWCOUNTER, NewSlidingWindowCounterLimiters)
+}
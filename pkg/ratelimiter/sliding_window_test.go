@@ -0,0 +1,115 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock 替换 nowFunc, 返回推进时钟的函数
+func fakeClock(t *testing.T) func(d time.Duration) {
+	now := time.Unix(1700000000, 0)
+	nowFunc = func() time.Time { return now }
+	t.Cleanup(func() { nowFunc = time.Now })
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func acceptN(l Limiter, name string, qps, burst, n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		if l.TryAccept(name, qps, burst) {
+			count++
+		}
+	}
+	return count
+}
+
+func Test_SlidingWindowLog(t *testing.T) {
+	assert := assert.New(t)
+	advance := fakeClock(t)
+	l := NewSlidingWindowLogLimiters()
+	defer l.DeleteRateLimiter("log")
+
+	assert.Equal(5, acceptN(l, "log", 5, 0, 10))
+	advance(600 * time.Millisecond)
+	assert.Equal(0, acceptN(l, "log", 5, 0, 10))
+	advance(400 * time.Millisecond)
+	assert.Equal(5, acceptN(l, "log", 5, 0, 10))
+
+	// 请求分散在窗口内, 只有最早的那批过期后才放行
+	advance(time.Second)
+	assert.Equal(3, acceptN(l, "log", 5, 0, 3))
+	advance(500 * time.Millisecond)
+	assert.Equal(2, acceptN(l, "log", 5, 0, 10))
+	advance(500 * time.Millisecond)
+	assert.Equal(3, acceptN(l, "log", 5, 0, 10))
+}
+
+func Test_SlidingWindowLogUpdate(t *testing.T) {
+	assert := assert.New(t)
+	advance := fakeClock(t)
+	l := NewSlidingWindowLogLimiters()
+	defer l.DeleteRateLimiter("log.update")
+
+	assert.Equal(4, acceptN(l, "log.update", 4, 0, 10))
+	// 调大后保留已有的记录
+	l.UpdateRateLimit("log.update", 6, 0)
+	assert.Equal(2, acceptN(l, "log.update", 4, 0, 10))
+	// 调小后窗口内的记录已经超过上限
+	l.UpdateRateLimit("log.update", 3, 0)
+	assert.Equal(0, acceptN(l, "log.update", 4, 0, 10))
+	advance(time.Second)
+	assert.Equal(3, acceptN(l, "log.update", 4, 0, 10))
+
+	l.UpdateRateLimit("log.update", 0, 0)
+	assert.Equal(100, acceptN(l, "log.update", 0, 0, 100))
+	l.UpdateRateLimit("log.update", 2, 0)
+	assert.Equal(2, acceptN(l, "log.update", 2, 0, 10))
+
+	l.UpdateRateLimit("log.new", 1, 0)
+	defer l.DeleteRateLimiter("log.new")
+	assert.Equal(1, acceptN(l, "log.new", 100, 0, 10))
+}
+
+func Test_SlidingWindowCounter(t *testing.T) {
+	assert := assert.New(t)
+	advance := fakeClock(t)
+	l := NewSlidingWindowCounterLimiters()
+	defer l.DeleteRateLimiter("counter")
+
+	assert.Equal(10, acceptN(l, "counter", 10, 0, 20))
+	advance(500 * time.Millisecond)
+	assert.Equal(0, acceptN(l, "counter", 10, 0, 20))
+
+	// 下一个窗口过去 1/4 时, 上一个窗口按 3/4 计入
+	advance(750 * time.Millisecond)
+	assert.Equal(2, acceptN(l, "counter", 10, 0, 20))
+
+	// 空闲超过两个窗口后计数清零
+	advance(2 * time.Second)
+	assert.Equal(10, acceptN(l, "counter", 10, 0, 20))
+
+	l.UpdateRateLimit("counter", 15, 0)
+	assert.Equal(5, acceptN(l, "counter", 10, 0, 20))
+
+	l.UpdateRateLimit("counter.new", 1, 0)
+	defer l.DeleteRateLimiter("counter.new")
+	assert.Equal(1, acceptN(l, "counter.new", 100, 0, 10))
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// job status in JobInfo
+const (
+	JobStatusIdle    = "idle"
+	JobStatusRunning = "running"
+	JobStatusPaused  = "paused"
+	JobStatusFailed  = "failed" // the last run failed, or the job can not be scheduled
+)
+
+// JobInfo is a snapshot of a job for operators
+type JobInfo struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name,omitempty"`
+	Func    string    `json:"func"`
+	Spec    string    `json:"spec"`
+	Tags    []string  `json:"tags"`
+	NextRun time.Time `json:"nextRun"`
+	LastRun time.Time `json:"lastRun,omitempty"` // start of the latest run in the history
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Jobs returns the snapshots of the jobs, ordered by their next run time
+func (s *Scheduler) Jobs() []JobInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks := s.tasks()
+	infos := make([]JobInfo, 0, len(tasks))
+	for _, job := range tasks {
+		info := JobInfo{
+			ID:      job.ID(),
+			Name:    job.name,
+			Func:    job.taskFunc,
+			Spec:    job.spec(),
+			Tags:    append([]string{}, job.tags...),
+			NextRun: job.nextRun,
+			Status:  JobStatusIdle,
+		}
+		job.mu.Lock()
+		var last *RunRecord
+		if records := job.history.list(); len(records) > 0 {
+			last = &records[len(records)-1]
+			info.LastRun = last.Start
+		}
+		switch {
+		case job.paused:
+			info.Status = JobStatusPaused
+		case job.active > 0:
+			info.Status = JobStatusRunning
+		case job.err != nil || (last != nil && last.Err != nil):
+			info.Status = JobStatusFailed
+		}
+		job.mu.Unlock()
+
+		if job.err != nil {
+			info.Error = job.err.Error()
+		} else if last != nil && last.Err != nil {
+			info.Error = last.Err.Error()
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// PauseByTag pauses the jobs with tag, they are kept scheduled but not run.
+// It returns the number of jobs paused
+func (s *Scheduler) PauseByTag(tag string) int {
+	return s.forTag(tag, func(job *Task) bool {
+		return job.setPaused(true)
+	})
+}
+
+// ResumeByTag resumes the paused jobs with tag. It returns the number of jobs resumed
+func (s *Scheduler) ResumeByTag(tag string) int {
+	return s.forTag(tag, func(job *Task) bool {
+		return job.setPaused(false)
+	})
+}
+
+// RunByTag runs the jobs with tag at once, regardless of their schedule and pause.
+// It returns the number of runs started
+func (s *Scheduler) RunByTag(tag string) int {
+	return s.forTag(tag, func(job *Task) bool {
+		return s.dispatch(job, nil)
+	})
+}
+
+// forTag calls fn for the jobs with tag and counts the jobs for which fn returns true
+func (s *Scheduler) forTag(tag string, fn func(*Task) bool) int {
+	n := 0
+	for _, job := range s.Tasks() {
+		if !job.hasTag(tag) {
+			continue
+		}
+		if fn(job) {
+			n++
+		}
+	}
+	return n
+}
+
+// AdminHandler returns the http handler for operators:
+//
+//	GET  /jobs                 lists the jobs as JobInfo
+//	POST /jobs/pause?tag=xxx   pauses the jobs with tag
+//	POST /jobs/resume?tag=xxx  resumes the jobs with tag
+//	POST /jobs/trigger?tag=xxx runs the jobs with tag at once
+//
+// The actions respond with {"matched": n}. Mount it with http.StripPrefix under a sub path
+func (s *Scheduler) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, s.Jobs())
+	})
+	actions := map[string]func(string) int{
+		"/jobs/pause":   s.PauseByTag,
+		"/jobs/resume":  s.ResumeByTag,
+		"/jobs/trigger": s.RunByTag,
+	}
+	for path, action := range actions {
+		action := action
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+				return
+			}
+			tag := r.URL.Query().Get("tag")
+			if tag == "" {
+				writeAdminError(w, http.StatusBadRequest, "tag is required")
+				return
+			}
+			writeAdminJSON(w, http.StatusOK, map[string]int{"matched": action(tag)})
+		})
+	}
+	return mux
+}
+
+func writeAdminJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, code int, msg string) {
+	writeAdminJSON(w, code, map[string]string{"error": msg})
+}
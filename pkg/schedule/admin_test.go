@@ -0,0 +1,142 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SchedulerPauseResume(t *testing.T) {
+	assert := assert.New(t)
+	sched := NewScheduler()
+
+	var count int32
+	task := sched.Every(1).Second()
+	task.Tag("batch")
+	assert.Nil(task.Do(func() { atomic.AddInt32(&count, 1) }))
+
+	assert.Equal(1, sched.PauseByTag("batch"))
+	assert.Equal(0, sched.PauseByTag("batch"))
+	assert.Equal(0, sched.PauseByTag("none"))
+	assert.True(task.Paused())
+
+	sched.mu.Lock()
+	task.nextRun = time.Now()
+	sched.mu.Unlock()
+	sched.RunPending()
+	assert.True(task.NextScheduledTime().After(time.Now()))
+
+	// A paused job can still be run manually
+	assert.Equal(1, sched.RunByTag("batch"))
+	assert.Nil(sched.Stop(context.Background()))
+	assert.Equal(int32(1), atomic.LoadInt32(&count))
+
+	assert.Equal(1, sched.ResumeByTag("batch"))
+	assert.False(task.Paused())
+}
+
+func Test_SchedulerJobs(t *testing.T) {
+	assert := assert.New(t)
+	sched := NewScheduler()
+
+	task := sched.Every(1).Hour().Name("failing")
+	task.Tag("a", "b")
+	assert.Nil(task.Do(func() error { return errors.New("failed") }))
+	assert.Nil(sched.Every(1).Day().At("10:30").Do(functionNameC))
+	assert.Nil(sched.Every(1).Do(functionNameC))
+
+	sched.RunAll()
+	assert.Nil(sched.Stop(context.Background()))
+
+	jobs := sched.Jobs()
+	assert.Len(jobs, 3)
+	assert.Equal("failing", jobs[0].Name)
+	assert.Equal([]string{"a", "b"}, jobs[0].Tags)
+	assert.Equal("every 1 hours", jobs[0].Spec)
+	assert.Equal(JobStatusFailed, jobs[0].Status)
+	assert.Equal("failed", jobs[0].Error)
+
+	assert.Equal("every 1 days at 10:30", jobs[1].Spec)
+	assert.Equal(JobStatusIdle, jobs[1].Status)
+	assert.Contains(jobs[1].Func, "functionNameC")
+	assert.False(jobs[1].LastRun.IsZero())
+
+	assert.Equal(never, jobs[2].NextRun)
+}
+
+func Test_AdminHandler(t *testing.T) {
+	assert := assert.New(t)
+	sched := NewScheduler()
+	task := sched.Every(1).Hour()
+	task.Tag("batch")
+	assert.Nil(task.Do(functionNameC))
+
+	server := httptest.NewServer(http.StripPrefix("/admin", sched.AdminHandler()))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/admin/jobs")
+	assert.Nil(err)
+	var jobs []JobInfo
+	assert.Nil(json.NewDecoder(resp.Body).Decode(&jobs))
+	resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Len(jobs, 1)
+	assert.Equal([]string{"batch"}, jobs[0].Tags)
+
+	for _, tt := range []struct {
+		path    string
+		matched int
+	}{
+		{"/admin/jobs/pause?tag=batch", 1},
+		{"/admin/jobs/pause?tag=other", 0},
+		{"/admin/jobs/trigger?tag=batch", 1},
+		{"/admin/jobs/resume?tag=batch", 1},
+	} {
+		resp, err := http.Post(server.URL+tt.path, "", nil)
+		assert.Nil(err)
+		var res map[string]int
+		assert.Nil(json.NewDecoder(resp.Body).Decode(&res))
+		resp.Body.Close()
+		assert.Equal(http.StatusOK, resp.StatusCode, tt.path)
+		assert.Equal(tt.matched, res["matched"], tt.path)
+	}
+
+	resp, err = http.Post(server.URL+"/admin/jobs/pause", "", nil)
+	assert.Nil(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Get(server.URL + "/admin/jobs/pause?tag=batch")
+	assert.Nil(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusMethodNotAllowed, resp.StatusCode)
+
+	resp, err = http.Post(server.URL+"/admin/jobs", "", nil)
+	assert.Nil(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Nil(sched.Stop(context.Background()))
+}
@@ -21,14 +21,40 @@ import (
 	"time"
 )
 
-const MAXJOBNUM = 1000
-
 var (
 	ErrTimeFormat           = errors.New("time format error")
 	ErrParamsNotAdapted     = errors.New("the number of params is not adapted")
 	ErrNotAFunction         = errors.New("only functions can be schedule into the job queue")
 	ErrPeriodNotSpecified   = errors.New("unspecified job period")
 	ErrParameterCannotBeNil = errors.New("nil paramaters cannot be used with reflection")
+	ErrCronFormat           = errors.New("cron expression format error")
+	ErrIntervalNotValid     = errors.New("interval must be greater than 0")
+	ErrDayOfMonthNotValid   = errors.New("day of month must be in [1, 31] or [-31, -1]")
+	ErrNoNextRun            = errors.New("schedule has no next run time")
+	ErrTaskPanic            = errors.New("task panic")
+	ErrJobNotFound          = errors.New("job not found in store")
+)
+
+// CatchUpPolicy decides how the runs missed during a downtime are handled,
+// according to the last run in the Store
+type CatchUpPolicy int
+
+const (
+	CatchUpSkip CatchUpPolicy = iota // skip the missed runs, the default
+	CatchUpOnce                      // run once for all the missed runs
+	CatchUpAll                       // run every missed run, one after another
+)
+
+// MaxCatchUpRuns limits the missed runs executed with CatchUpAll
+const MaxCatchUpRuns = 1000
+
+// OverlapPolicy decides what to do when a job is due while its previous run is still in flight
+type OverlapPolicy int
+
+const (
+	OverlapAllow OverlapPolicy = iota // start another run concurrently, the default
+	OverlapSkip                       // skip the run
+	OverlapQueue                      // run again as soon as the in-flight run returns
 )
 
 type timeUnit int
@@ -39,8 +65,28 @@ const (
 	hours
 	days
 	weeks
+	months
 )
 
+func (t timeUnit) name() string {
+	switch t {
+	case seconds:
+		return "seconds"
+	case minutes:
+		return "minutes"
+	case hours:
+		return "hours"
+	case days:
+		return "days"
+	case weeks:
+		return "weeks"
+	case months:
+		return "months"
+	default:
+		return "unspecified"
+	}
+}
+
 func (t timeUnit) String() time.Duration {
 	switch t {
 	case seconds:
@@ -0,0 +1,350 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// The cron expression parser and the Next algorithm in this file are adapted
+// from github.com/robfig/cron/v3 (spec.go and parser.go), which carries the
+// following license:
+//
+// Copyright (C) 2012 Rob Figueroa
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes the next run time of a job
+type Schedule interface {
+	// Next returns the next run time strictly after t, or the zero time if there is none
+	Next(t time.Time) time.Time
+}
+
+// cronBounds is the range of values accepted by a cron field
+type cronBounds struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	cronSeconds = cronBounds{0, 59, nil}
+	cronMinutes = cronBounds{0, 59, nil}
+	cronHours   = cronBounds{0, 23, nil}
+	cronDom     = cronBounds{1, 31, nil}
+	cronMonths  = cronBounds{1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDow = cronBounds{0, 7, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// cronStar is set when a field is "*" or "?", used for the OR semantics of day-of-month and day-of-week
+const cronStar = 1 << 63
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// cronSchedule is a standard cron expression, each field stored as a bit set
+type cronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+}
+
+// everySchedule is a fixed "@every <duration>" interval
+type everySchedule struct {
+	delay time.Duration
+}
+
+// ParseCron parses a cron expression:
+//
+//	5 fields: minute hour day-of-month month day-of-week
+//	6 fields: second minute hour day-of-month month day-of-week
+//	descriptors: @yearly @annually @monthly @weekly @daily @midnight @hourly @every <duration>
+//
+// Fields accept "*", "?", lists "1,2,3", ranges "1-5" and steps "*/15" "1-30/5".
+// Month and day-of-week also accept English abbreviations.
+func ParseCron(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("%w: empty cron expression", ErrCronFormat)
+	}
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(expr[len("@every "):]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCronFormat, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("%w: @every duration must be >= 1s", ErrCronFormat)
+		}
+		return everySchedule{delay: d}, nil
+	}
+	if strings.HasPrefix(expr, "@") {
+		spec, ok := cronDescriptors[strings.ToLower(expr)]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown descriptor %s", ErrCronFormat, expr)
+		}
+		expr = spec
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("%w: expected 5 or 6 fields, found %d: %s", ErrCronFormat, len(fields), expr)
+	}
+
+	var (
+		s   cronSchedule
+		err error
+	)
+	if s.second, err = parseCronField(fields[0], cronSeconds); err != nil {
+		return nil, err
+	}
+	if s.minute, err = parseCronField(fields[1], cronMinutes); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseCronField(fields[2], cronHours); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseCronField(fields[3], cronDom); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseCronField(fields[4], cronMonths); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseCronField(fields[5], cronDow); err != nil {
+		return nil, err
+	}
+	// Sunday may be written as 7
+	if s.dow&(1<<7) != 0 {
+		s.dow = (s.dow | 1) &^ (1 << 7)
+	}
+	return &s, nil
+}
+
+// parseCronField parses one field and returns the bit set of its values
+func parseCronField(field string, b cronBounds) (uint64, error) {
+	var bits uint64
+	for _, expr := range strings.Split(field, ",") {
+		bit, err := parseCronRange(expr, b)
+		if err != nil {
+			return 0, err
+		}
+		bits |= bit
+	}
+	return bits, nil
+}
+
+func parseCronRange(expr string, b cronBounds) (uint64, error) {
+	var (
+		start, end, step uint = 0, 0, 1
+		extra            uint64
+		err              error
+	)
+	rangeAndStep := strings.Split(expr, "/")
+	lowAndHigh := strings.Split(rangeAndStep[0], "-")
+	singleDigit := len(lowAndHigh) == 1
+
+	if lowAndHigh[0] == "*" || lowAndHigh[0] == "?" {
+		if !singleDigit {
+			return 0, fmt.Errorf("%w: invalid range %s", ErrCronFormat, expr)
+		}
+		start, end = b.min, b.max
+		extra = cronStar
+	} else {
+		if start, err = parseCronValue(lowAndHigh[0], b); err != nil {
+			return 0, err
+		}
+		switch len(lowAndHigh) {
+		case 1:
+			end = start
+		case 2:
+			if end, err = parseCronValue(lowAndHigh[1], b); err != nil {
+				return 0, err
+			}
+		default:
+			return 0, fmt.Errorf("%w: too many hyphens %s", ErrCronFormat, expr)
+		}
+	}
+
+	switch len(rangeAndStep) {
+	case 1:
+	case 2:
+		n, err := strconv.ParseUint(rangeAndStep[1], 10, 32)
+		if err != nil || n == 0 {
+			return 0, fmt.Errorf("%w: invalid step %s", ErrCronFormat, expr)
+		}
+		step = uint(n)
+		// "N/step" means from N up to the maximum
+		if singleDigit && extra == 0 {
+			end = b.max
+		}
+		if step > 1 {
+			extra = 0
+		}
+	default:
+		return 0, fmt.Errorf("%w: too many slashes %s", ErrCronFormat, expr)
+	}
+
+	if start < b.min || end > b.max {
+		return 0, fmt.Errorf("%w: %s out of range [%d, %d]", ErrCronFormat, expr, b.min, b.max)
+	}
+	if start > end {
+		return 0, fmt.Errorf("%w: %s beginning of range after end", ErrCronFormat, expr)
+	}
+
+	var bits uint64
+	for i := start; i <= end; i += step {
+		bits |= 1 << i
+	}
+	return bits | extra, nil
+}
+
+func parseCronValue(expr string, b cronBounds) (uint, error) {
+	if b.names != nil {
+		if v, ok := b.names[strings.ToLower(expr)]; ok {
+			return v, nil
+		}
+	}
+	n, err := strconv.ParseUint(expr, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid value %s", ErrCronFormat, expr)
+	}
+	return uint(n), nil
+}
+
+// Next computes the next run time in the location of t, handling daylight saving transitions
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	// Start from the next second
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	added := false
+	yearLimit := t.Year() + 5
+
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for 1<<uint(t.Month())&s.month == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+
+	for !s.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		// A daylight saving transition may make the day start at an hour other than 0
+		if t.Hour() != 0 {
+			if t.Hour() > 12 {
+				t = t.Add(time.Duration(24-t.Hour()) * time.Hour)
+			} else {
+				t = t.Add(time.Duration(-t.Hour()) * time.Hour)
+			}
+		}
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+
+	for 1<<uint(t.Hour())&s.hour == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+
+	for 1<<uint(t.Minute())&s.minute == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+
+	for 1<<uint(t.Second())&s.second == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+	return t
+}
+
+// dayMatches reports whether t matches either day-of-month or day-of-week when both are restricted
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := 1<<uint(t.Day())&s.dom > 0
+	dowMatch := 1<<uint(t.Weekday())&s.dow > 0
+	if s.dom&cronStar > 0 || s.dow&cronStar > 0 {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next adds the fixed interval, aligned to the second
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.delay - time.Duration(t.Nanosecond()))
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustTime(t *testing.T, loc *time.Location, value string) time.Time {
+	tm, err := time.ParseInLocation("2006-01-02 15:04:05", value, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
+func Test_ParseCronError(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"1-2-3 * * * *",
+		"*/2/3 * * * *",
+		"*-5 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@unknown",
+		"@every abc",
+		"@every 10ms",
+	} {
+		_, err := ParseCron(expr)
+		assert.ErrorIs(err, ErrCronFormat, expr)
+	}
+}
+
+func Test_CronNext(t *testing.T) {
+	assert := assert.New(t)
+	loc := time.UTC
+
+	tests := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"* * * * *", "2023-01-01 10:00:30", "2023-01-01 10:01:00"},
+		{"*/15 * * * *", "2023-01-01 10:01:00", "2023-01-01 10:15:00"},
+		{"0 9-18/3 * * *", "2023-01-01 10:00:00", "2023-01-01 12:00:00"},
+		{"0 0 1,15 * *", "2023-01-02 00:00:00", "2023-01-15 00:00:00"},
+		{"30 8 * * MON-FRI", "2023-01-06 09:00:00", "2023-01-09 08:30:00"},
+		{"0 0 * * 7", "2023-01-02 00:00:00", "2023-01-08 00:00:00"},
+		{"0 0 29 FEB *", "2023-01-01 00:00:00", "2024-02-29 00:00:00"},
+		{"0 0 31 * *", "2023-04-01 00:00:00", "2023-05-31 00:00:00"},
+		{"*/10 * * * * *", "2023-01-01 10:00:01", "2023-01-01 10:00:10"},
+		{"0 0 12 13 * 5", "2023-01-01 00:00:00", "2023-01-06 12:00:00"},
+		{"@hourly", "2023-01-01 10:00:00", "2023-01-01 11:00:00"},
+		{"@daily", "2023-01-01 10:00:00", "2023-01-02 00:00:00"},
+		{"@weekly", "2023-01-01 10:00:00", "2023-01-08 00:00:00"},
+		{"@monthly", "2023-01-01 10:00:00", "2023-02-01 00:00:00"},
+		{"@yearly", "2023-01-01 10:00:00", "2024-01-01 00:00:00"},
+		{"@every 90s", "2023-01-01 10:00:00", "2023-01-01 10:01:30"},
+	}
+	for _, tt := range tests {
+		s, err := ParseCron(tt.expr)
+		assert.Nil(err, tt.expr)
+		assert.Equal(mustTime(t, loc, tt.want), s.Next(mustTime(t, loc, tt.from)), tt.expr)
+	}
+
+	// Dates that never occur
+	s, err := ParseCron("0 0 30 2 *")
+	assert.Nil(err)
+	assert.True(s.Next(mustTime(t, loc, "2023-01-01 00:00:00")).IsZero())
+}
+
+func Test_CronNextDST(t *testing.T) {
+	assert := assert.New(t)
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("timezone data not available")
+	}
+
+	// Daylight saving starts at 2023-03-12 02:00, there is no 02:30 that day
+	s, err := ParseCron("30 2 * * *")
+	assert.Nil(err)
+	assert.Equal(mustTime(t, loc, "2023-03-13 02:30:00"), s.Next(mustTime(t, loc, "2023-03-11 03:00:00")))
+
+	// Daily at 09:00 stays at 09:00 local time across the transition
+	s, err = ParseCron("0 9 * * *")
+	assert.Nil(err)
+	next := s.Next(mustTime(t, loc, "2023-03-11 10:00:00"))
+	assert.Equal(mustTime(t, loc, "2023-03-12 09:00:00"), next)
+	assert.Equal(22*time.Hour, next.Sub(mustTime(t, loc, "2023-03-11 10:00:00")))
+}
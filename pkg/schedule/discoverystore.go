@@ -0,0 +1,178 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kubeservice-stack/common/pkg/discovery"
+)
+
+// DiscoveryStore is a Store on Discovery for replicas on different hosts.
+// Definitions are kept under prefix/jobs/<name> and run states under prefix/runs/<name>,
+// runs are claimed by compare-and-swap transactions on the mod revision
+type DiscoveryStore struct {
+	ds     discovery.Discovery
+	prefix string
+}
+
+// runState is the value of prefix/runs/<name>
+type runState struct {
+	LastRun time.Time `json:"lastRun"`
+	Owner   string    `json:"owner,omitempty"`
+}
+
+// NewDiscoveryStore creates a store on ds with the key prefix
+func NewDiscoveryStore(ds discovery.Discovery, prefix string) *DiscoveryStore {
+	return &DiscoveryStore{ds: ds, prefix: strings.TrimSuffix(prefix, "/")}
+}
+
+func (s *DiscoveryStore) jobKey(name string) string {
+	return s.prefix + "/jobs/" + name
+}
+
+func (s *DiscoveryStore) runKey(name string) string {
+	return s.prefix + "/runs/" + name
+}
+
+// Save persists the definition of the job
+func (s *DiscoveryStore) Save(ctx context.Context, state *JobState) error {
+	data, err := json.Marshal(&JobState{Name: state.Name, Spec: state.Spec, Tags: state.Tags})
+	if err != nil {
+		return err
+	}
+	return s.ds.Put(ctx, s.jobKey(state.Name), data)
+}
+
+// Load returns the state of the job
+func (s *DiscoveryStore) Load(ctx context.Context, name string) (*JobState, error) {
+	st := &JobState{Name: name}
+	data, err := s.ds.Get(ctx, s.jobKey(name))
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, st); err != nil {
+			return nil, err
+		}
+	case err != discovery.ErrNotExist:
+		return nil, err
+	}
+
+	run, _, err := s.getRun(ctx, name)
+	if err == discovery.ErrNotExist {
+		if data == nil {
+			return nil, ErrJobNotFound
+		}
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	st.LastRun, st.Owner = run.LastRun, run.Owner
+	return st, nil
+}
+
+// List returns the states of all the jobs
+func (s *DiscoveryStore) List(ctx context.Context) ([]*JobState, error) {
+	states := make(map[string]*JobState)
+	jobs, err := s.ds.ListPrefix(ctx, s.prefix+"/jobs/")
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range jobs.KVs {
+		st := &JobState{}
+		if err := json.Unmarshal(kv.Value, st); err != nil {
+			return nil, err
+		}
+		st.Name = strings.TrimPrefix(kv.Key, s.prefix+"/jobs/")
+		states[st.Name] = st
+	}
+
+	runs, err := s.ds.ListPrefix(ctx, s.prefix+"/runs/")
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range runs.KVs {
+		run := &runState{}
+		if err := json.Unmarshal(kv.Value, run); err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(kv.Key, s.prefix+"/runs/")
+		st, ok := states[name]
+		if !ok {
+			st = &JobState{Name: name}
+			states[name] = st
+		}
+		st.LastRun, st.Owner = run.LastRun, run.Owner
+	}
+
+	res := make([]*JobState, 0, len(states))
+	for _, st := range states {
+		res = append(res, st)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res, nil
+}
+
+// Claim takes the run of the job scheduled at tick
+func (s *DiscoveryStore) Claim(ctx context.Context, name string, tick time.Time, owner string) (bool, error) {
+	run, rev, err := s.getRun(ctx, name)
+	switch {
+	case err == discovery.ErrNotExist:
+		run = &runState{}
+	case err != nil:
+		return false, err
+	}
+	if !run.LastRun.Before(tick) {
+		return false, nil
+	}
+
+	data, err := json.Marshal(&runState{LastRun: tick, Owner: owner})
+	if err != nil {
+		return false, err
+	}
+	txn := s.ds.NewTransaction()
+	// A revision of 0 means the key does not exist
+	txn.ModRevisionCmp(s.runKey(name), "=", rev)
+	txn.Put(s.runKey(name), data)
+	err = s.ds.Commit(ctx, txn)
+	if err == discovery.ErrTxnFailed {
+		// Another replica claimed it first
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *DiscoveryStore) getRun(ctx context.Context, name string) (*runState, int64, error) {
+	data, rev, err := s.ds.GetWithRevision(ctx, s.runKey(name))
+	if err != nil {
+		return nil, 0, err
+	}
+	run := &runState{}
+	if err := json.Unmarshal(data, run); err != nil {
+		return nil, 0, err
+	}
+	return run, rev, nil
+}
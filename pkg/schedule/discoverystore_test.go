@@ -0,0 +1,97 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/integration"
+
+	"github.com/kubeservice-stack/common/pkg/config"
+	"github.com/kubeservice-stack/common/pkg/discovery"
+)
+
+func Test_DiscoveryStore(t *testing.T) {
+	assert := assert.New(t)
+	cluster := integration.NewClusterV3(t, &integration.ClusterConfig{Size: 1})
+	defer cluster.Terminate(t)
+
+	ds, err := discovery.NewDiscoveryFactory("nobody").CreateDiscovery(config.Discovery{
+		Namespace: "/test/schedule",
+		Endpoints: []string{cluster.Members[0].GRPCAddr()},
+	})
+	assert.Nil(err)
+	defer ds.Close()
+
+	ctx := context.Background()
+	store := NewDiscoveryStore(ds, "/schedule/")
+
+	_, err = store.Load(ctx, "job")
+	assert.Equal(ErrJobNotFound, err)
+
+	assert.Nil(store.Save(ctx, &JobState{Name: "job", Spec: "@hourly", Tags: []string{"a"}}))
+	st, err := store.Load(ctx, "job")
+	assert.Nil(err)
+	assert.Equal("@hourly", st.Spec)
+	assert.True(st.LastRun.IsZero())
+
+	// Several replicas race to claim the same run
+	tick := time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC)
+	var claimed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := store.Claim(ctx, "job", tick, "replica")
+			assert.Nil(err)
+			if ok {
+				atomic.AddInt32(&claimed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(int32(1), claimed)
+
+	ok, err := store.Claim(ctx, "job", tick.Add(time.Hour), "replica-2")
+	assert.Nil(err)
+	assert.True(ok)
+
+	// A job that only has run state
+	ok, err = store.Claim(ctx, "another", tick, "replica-1")
+	assert.Nil(err)
+	assert.True(ok)
+
+	st, err = store.Load(ctx, "job")
+	assert.Nil(err)
+	assert.True(tick.Add(time.Hour).Equal(st.LastRun))
+	assert.Equal("replica-2", st.Owner)
+	assert.Equal([]string{"a"}, st.Tags)
+
+	states, err := store.List(ctx)
+	assert.Nil(err)
+	assert.Len(states, 2)
+	assert.Equal("another", states[0].Name)
+	assert.Equal("replica-1", states[0].Owner)
+	assert.Equal("job", states[1].Name)
+	assert.Equal("@hourly", states[1].Spec)
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileStore is a Store which keeps the states of all jobs in a JSON file. The file is
+// read on every call and replaced atomically on writes, so it survives restarts.
+// It is safe for concurrent use within one process, replicas on different hosts
+// should use DiscoveryStore
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileStore creates a file store with the path of the JSON file, which is created on the first write
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Save persists the definition of the job
+func (fs *FileStore) Save(ctx context.Context, state *JobState) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	states, err := fs.read()
+	if err != nil {
+		return err
+	}
+	st, ok := states[state.Name]
+	if !ok {
+		st = &JobState{Name: state.Name}
+		states[state.Name] = st
+	}
+	st.Spec = state.Spec
+	st.Tags = state.Tags
+	return fs.write(states)
+}
+
+// Load returns the state of the job
+func (fs *FileStore) Load(ctx context.Context, name string) (*JobState, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	states, err := fs.read()
+	if err != nil {
+		return nil, err
+	}
+	st, ok := states[name]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return st, nil
+}
+
+// List returns the states of all the jobs
+func (fs *FileStore) List(ctx context.Context) ([]*JobState, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	states, err := fs.read()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*JobState, 0, len(states))
+	for _, st := range states {
+		res = append(res, st)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res, nil
+}
+
+// Claim takes the run of the job scheduled at tick
+func (fs *FileStore) Claim(ctx context.Context, name string, tick time.Time, owner string) (bool, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	states, err := fs.read()
+	if err != nil {
+		return false, err
+	}
+	st, ok := states[name]
+	if !ok {
+		st = &JobState{Name: name}
+		states[name] = st
+	}
+	if !st.LastRun.Before(tick) {
+		return false, nil
+	}
+	st.LastRun = tick
+	st.Owner = owner
+	if err := fs.write(states); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (fs *FileStore) read() (map[string]*JobState, error) {
+	states := make(map[string]*JobState)
+	data, err := os.ReadFile(fs.path)
+	if os.IsNotExist(err) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return states, nil
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// write replaces the file by renaming a temporary file in the same directory
+func (fs *FileStore) write(states map[string]*JobState) error {
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(fs.path), filepath.Base(fs.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fs.path)
+}
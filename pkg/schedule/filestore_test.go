@@ -0,0 +1,103 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FileStore(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	fs := NewFileStore(path)
+
+	_, err := fs.Load(ctx, "job")
+	assert.Equal(ErrJobNotFound, err)
+	states, err := fs.List(ctx)
+	assert.Nil(err)
+	assert.Len(states, 0)
+
+	assert.Nil(fs.Save(ctx, &JobState{Name: "job", Spec: "every 1 minutes", Tags: []string{"a"}}))
+	tick := time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC)
+	ok, err := fs.Claim(ctx, "job", tick, "replica-1")
+	assert.Nil(err)
+	assert.True(ok)
+	ok, err = fs.Claim(ctx, "job", tick, "replica-2")
+	assert.Nil(err)
+	assert.False(ok)
+	ok, err = fs.Claim(ctx, "job", tick.Add(-time.Minute), "replica-2")
+	assert.Nil(err)
+	assert.False(ok)
+
+	// Save keeps the run state
+	assert.Nil(fs.Save(ctx, &JobState{Name: "job", Spec: "every 2 minutes"}))
+	assert.Nil(fs.Save(ctx, &JobState{Name: "another"}))
+
+	// Reopen the file
+	fs = NewFileStore(path)
+	st, err := fs.Load(ctx, "job")
+	assert.Nil(err)
+	assert.Equal("every 2 minutes", st.Spec)
+	assert.True(tick.Equal(st.LastRun))
+	assert.Equal("replica-1", st.Owner)
+
+	states, err = fs.List(ctx)
+	assert.Nil(err)
+	assert.Len(states, 2)
+	assert.Equal("another", states[0].Name)
+	assert.Equal("job", states[1].Name)
+
+	// No temporary file is left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.Nil(err)
+	assert.Len(entries, 1)
+
+	assert.Nil(os.WriteFile(path, []byte("bad"), 0o644))
+	_, err = fs.Load(ctx, "job")
+	assert.NotNil(err)
+}
+
+func Test_FileStoreClaimConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	fs := NewFileStore(filepath.Join(t.TempDir(), "schedule.json"))
+	tick := time.Now()
+
+	var claimed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := fs.Claim(context.Background(), "job", tick, "replica")
+			assert.Nil(err)
+			if ok {
+				atomic.AddInt32(&claimed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(int32(1), claimed)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"context"
+)
+
+// TaskFunc is the typed job function, a non-nil error fails the run
+type TaskFunc func(ctx context.Context) error
+
+// DoFunc specifies the typed function called every time the job runs, without reflection.
+// The context is cancelled on timeout or when the scheduler stops
+//
+//	s.Every(1).Minute().Name("sync").DoFunc(func(ctx context.Context) error { return nil })
+func (j *Task) DoFunc(fn TaskFunc) error {
+	if fn == nil {
+		return ErrNotAFunction
+	}
+	return j.doFunc(getFunctionName(fn), fn)
+}
+
+// doFunc sets the function of the job before submitting it, the job is shared with the scheduler after submit
+func (j *Task) doFunc(funcName string, fn TaskFunc) error {
+	if j.err != nil {
+		return j.err
+	}
+	j.taskFunc = funcName
+	j.invoke = func(ctx context.Context) ([]interface{}, error) {
+		return nil, fn(ctx)
+	}
+	return j.submit()
+}
+
+// DoWith specifies the typed function called with param every time the job runs
+//
+//	DoWith(s.Every(1).Hour(), func(ctx context.Context, region string) error { return nil }, "cn-north")
+func DoWith[P any](j *Task, fn func(ctx context.Context, param P) error, param P) error {
+	if fn == nil {
+		return ErrNotAFunction
+	}
+	return j.doFunc(getFunctionName(fn), func(ctx context.Context) error {
+		return fn(ctx, param)
+	})
+}
+
+// reflectInvoker adapts a function called by reflection to invoker,
+// the last return value is taken as the error if it implements error
+func reflectInvoker(taskFun interface{}, params []interface{}) invoker {
+	return func(ctx context.Context) ([]interface{}, error) {
+		values, err := callTaskFuncWithContext(ctx, taskFun, params)
+		if err != nil {
+			return nil, err
+		}
+		results := make([]interface{}, len(values))
+		for i, v := range values {
+			results[i] = v.Interface()
+		}
+		if n := len(values); n > 0 && values[n-1].Type().Implements(errorType) && !values[n-1].IsNil() {
+			err = values[n-1].Interface().(error)
+		}
+		return results, err
+	}
+}
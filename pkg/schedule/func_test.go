@@ -0,0 +1,147 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCounterFunc(counter *int32) TaskFunc {
+	return func(ctx context.Context) error {
+		atomic.AddInt32(counter, 1)
+		return nil
+	}
+}
+
+func Test_DoFunc(t *testing.T) {
+	assert := assert.New(t)
+	sched := NewScheduler()
+
+	// Two closures created by the same function do not interfere
+	var a, b int32
+	taskA := sched.Every(1).Hour()
+	assert.Nil(taskA.DoFunc(newCounterFunc(&a)))
+	taskB := sched.Every(1).Hour()
+	assert.Nil(taskB.DoFunc(newCounterFunc(&b)))
+	assert.NotEqual(taskA.ID(), taskB.ID())
+	assert.Equal(taskA.taskFunc, taskB.taskFunc)
+
+	taskA.run(context.Background())
+	assert.Equal(int32(1), atomic.LoadInt32(&a))
+	assert.Equal(int32(0), atomic.LoadInt32(&b))
+
+	sched.RemoveByID(taskA.ID())
+	assert.Equal(1, sched.Len())
+	assert.Equal(taskB, sched.Tasks()[0])
+
+	named := sched.Every(1).Hour().Name("named")
+	assert.Nil(named.DoFunc(newCounterFunc(&a)))
+	assert.Equal("named", named.ID())
+
+	errFailed := errors.New("failed")
+	task := NewTask(1).Hour()
+	assert.Nil(task.DoFunc(func(ctx context.Context) error { return errFailed }))
+	record := task.run(context.Background())
+	assert.Equal(errFailed, record.Err)
+	assert.Nil(record.Results)
+
+	assert.Equal(ErrNotAFunction, NewTask(1).Hour().DoFunc(nil))
+	task = NewTask(1).Cron("bad")
+	assert.ErrorIs(task.DoFunc(newCounterFunc(&a)), ErrCronFormat)
+}
+
+func Test_DoWith(t *testing.T) {
+	assert := assert.New(t)
+
+	var got string
+	task := NewTask(1).Hour()
+	err := DoWith(task, func(ctx context.Context, region string) error {
+		got = region
+		return nil
+	}, "cn-north")
+	assert.Nil(err)
+	assert.True(task.run(context.Background()).Succeeded())
+	assert.Equal("cn-north", got)
+	assert.Contains(task.taskFunc, "Test_DoWith")
+
+	assert.Equal(ErrNotAFunction, DoWith[int](NewTask(1).Hour(), nil, 1))
+
+	// The function name is set before the job is published to the scheduler
+	sched := NewScheduler()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			sched.Scheduled(Test_DoWith)
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		assert.Nil(DoWith(sched.Every(1).Hour(), func(ctx context.Context, n int) error { return nil }, i))
+	}
+	<-done
+}
+
+type keyLocker struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (l *keyLocker) Lock(key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.keys = append(l.keys, key)
+	return true, nil
+}
+
+func (l *keyLocker) Unlock(key string) error {
+	return nil
+}
+
+func Test_DoFuncLockKey(t *testing.T) {
+	assert := assert.New(t)
+	l := &keyLocker{}
+	SetLocker(l)
+	defer SetLocker(&fakeLocker{})
+
+	// Named jobs are locked by name, unnamed jobs by the function name, the same on every instance
+	var a, b int32
+	taskA := NewTask(1).Hour().Lock()
+	assert.Nil(taskA.DoFunc(newCounterFunc(&a)))
+	taskB := NewTask(1).Hour().Name("b").Lock()
+	assert.Nil(taskB.DoFunc(newCounterFunc(&b)))
+	taskA.run(context.Background())
+	taskB.run(context.Background())
+	assert.Equal([]string{getFunctionKey(taskA.taskFunc), getFunctionKey("b")}, l.keys)
+	assert.NotContains(l.keys, getFunctionKey(taskA.ID()))
+}
+
+func Test_TaskID(t *testing.T) {
+	assert := assert.New(t)
+	a, b := NewTask(1), NewTask(1)
+	assert.NotEqual(a.ID(), b.ID())
+	assert.Contains(a.ID(), "task-")
+	assert.Equal("job", a.Name("job").ID())
+
+	// Running without a function fails
+	assert.Equal(ErrNotAFunction, NewTask(1).run(context.Background()).Err)
+}
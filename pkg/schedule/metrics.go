@@ -0,0 +1,56 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"github.com/uber-go/tally"
+
+	"github.com/kubeservice-stack/common/pkg/metrics"
+)
+
+// unnamedJob tags the metrics of the jobs without a name, their ids are not stable across restarts
+const unnamedJob = "unnamed"
+
+// taskMetrics are the metrics of a job in the scheduler scope, tagged by job name.
+// Jobs without a name share the metrics tagged unnamedJob, keeping the number of series bounded
+type taskMetrics struct {
+	runs       tally.Counter   // finished runs
+	failures   tally.Counter   // failed runs, after all retries
+	panics     tally.Counter   // attempts which panicked
+	skipped    tally.Counter   // runs skipped by OverlapSkip
+	queued     tally.Counter   // runs queued by OverlapQueue
+	claimsLost tally.Counter   // runs claimed by other replicas
+	duration   tally.Histogram // run duration in seconds, retries included
+	startLag   tally.Histogram // seconds between the planned and the actual start
+}
+
+func newTaskMetrics(scope tally.Scope, job string) *taskMetrics {
+	if job == "" {
+		job = unnamedJob
+	}
+	scope = scope.Tagged(map[string]string{"job": job})
+	return &taskMetrics{
+		runs:       scope.Counter("runs"),
+		failures:   scope.Counter("failures"),
+		panics:     scope.Counter("panics"),
+		skipped:    scope.Counter("skipped_overlaps"),
+		queued:     scope.Counter("queued_overlaps"),
+		claimsLost: scope.Counter("claims_lost"),
+		duration:   scope.Histogram("run_duration", metrics.DefaultTallyBuckets),
+		startLag:   scope.Histogram("start_lag", metrics.DefaultTallyBuckets),
+	}
+}
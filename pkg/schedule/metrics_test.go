@@ -0,0 +1,71 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+
+	"github.com/kubeservice-stack/common/pkg/metrics"
+)
+
+func Test_SchedulerMetrics(t *testing.T) {
+	assert := assert.New(t)
+	scope := tally.NewTestScope("", nil)
+	sched := NewScheduler()
+	sched.SetTallyScope(&metrics.TallyScope{Scope: scope})
+
+	release := make(chan struct{})
+	assert.Nil(sched.Every(1).Hour().Name("slow").Overlap(OverlapSkip).Do(func() { <-release }))
+	assert.Nil(sched.Every(1).Hour().Name("failing").Do(func() error { return errors.New("failed") }))
+	assert.Nil(sched.Every(1).Hour().Name("panic").Do(CallBackPanic, "aaa"))
+	assert.Nil(sched.Every(1).Hour().Do(func() {}))
+	assert.Nil(sched.Every(1).Hour().Do(func() {}))
+
+	sched.RunAll()
+	sched.RunAll()
+	close(release)
+	assert.Nil(sched.Stop(context.Background()))
+
+	counters := scope.Snapshot().Counters()
+	counter := func(name, job string) int64 {
+		c, ok := counters["schedule."+name+"+job="+job]
+		if !ok {
+			return 0
+		}
+		return c.Value()
+	}
+	assert.Equal(int64(1), counter("runs", "slow"))
+	assert.Equal(int64(1), counter("skipped_overlaps", "slow"))
+	assert.Equal(int64(2), counter("runs", "failing"))
+	assert.Equal(int64(2), counter("failures", "failing"))
+	assert.Equal(int64(2), counter("panics", "panic"))
+	assert.Equal(int64(2), counter("failures", "panic"))
+	// unnamed jobs share one series
+	assert.Equal(int64(4), counter("runs", unnamedJob))
+	for name := range counters {
+		assert.NotContains(name, "job=task-")
+	}
+
+	histograms := scope.Snapshot().Histograms()
+	_, ok := histograms["schedule.run_duration+job=slow"]
+	assert.True(ok)
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"time"
+)
+
+// DefaultHistorySize is the number of runs kept by a task by default
+const DefaultHistorySize = 10
+
+// RunRecord is the outcome of a run of the job, retries included
+type RunRecord struct {
+	Start    time.Time     // start time of the first attempt
+	Duration time.Duration // total duration of all attempts and backoff waits
+	Attempts int           // number of calls of the job function
+	Results  []interface{} // values returned by the last attempt
+	Err      error         // error of the last attempt, nil if succeeded
+	Panic    interface{}   // value recovered from the last attempt, nil if not panicked
+}
+
+// Succeeded returns true if the last attempt neither returned an error nor panicked
+func (r RunRecord) Succeeded() bool {
+	return r.Err == nil
+}
+
+// history is a fixed size ring of the latest run records
+type history struct {
+	records []RunRecord
+	next    int  // index of the slot to write
+	full    bool // records are all written at least once
+}
+
+func newHistory(size int) *history {
+	if size < 0 {
+		size = 0
+	}
+	return &history{records: make([]RunRecord, size)}
+}
+
+func (h *history) add(r RunRecord) {
+	if len(h.records) == 0 {
+		return
+	}
+	h.records[h.next] = r
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the records from the oldest to the latest
+func (h *history) list() []RunRecord {
+	if !h.full {
+		return append([]RunRecord(nil), h.records[:h.next]...)
+	}
+	res := make([]RunRecord, 0, len(h.records))
+	res = append(res, h.records[h.next:]...)
+	return append(res, h.records[:h.next]...)
+}
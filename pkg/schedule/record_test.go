@@ -0,0 +1,50 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_History(t *testing.T) {
+	assert := assert.New(t)
+
+	h := newHistory(3)
+	assert.Len(h.list(), 0)
+	for i := 1; i <= 2; i++ {
+		h.add(RunRecord{Attempts: i})
+	}
+	assert.Equal([]RunRecord{{Attempts: 1}, {Attempts: 2}}, h.list())
+
+	for i := 3; i <= 5; i++ {
+		h.add(RunRecord{Attempts: i})
+	}
+	assert.Equal([]RunRecord{{Attempts: 3}, {Attempts: 4}, {Attempts: 5}}, h.list())
+
+	h = newHistory(0)
+	h.add(RunRecord{Attempts: 1})
+	assert.Len(h.list(), 0)
+}
+
+func Test_RunRecordSucceeded(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(RunRecord{}.Succeeded())
+	assert.False(RunRecord{Err: errors.New("failed")}.Succeeded())
+}
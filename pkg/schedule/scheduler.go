@@ -17,92 +17,242 @@ limitations under the License.
 package schedule
 
 import (
+	"container/heap"
+	"context"
 	"sort"
+	"sync"
 	"time"
+
+	"github.com/uber-go/tally"
+
+	"github.com/kubeservice-stack/common/pkg/logger"
+	"github.com/kubeservice-stack/common/pkg/metrics"
 )
 
-// Scheduler struct, the only data member is the list of jobs.
+// idleWait is the max time the loop of Start sleeps, e.g. when there is no job
+const idleWait = time.Hour
+
+// storeTimeout is the timeout of loading and saving a job in the Store when it is added
+const storeTimeout = 5 * time.Second
+
+// never is the next run time of jobs which can not be scheduled, they stay in
+// the scheduler but only run by RunAll
+var never = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// Scheduler struct, keeps the jobs in a min-heap ordered by their next run time.
+// It is safe for concurrent use
 type Scheduler struct {
-	jobs [MAXJOBNUM]*Task // Array store jobs
-	size int              // Size of jobs which jobs holding.
-	loc  *time.Location   // Location to use when scheduling jobs with specified times
+	mu     sync.Mutex     // guards jobs and the schedule of the jobs
+	jobs   taskHeap       // min-heap of jobs by nextRun
+	loc    *time.Location // Location to use when scheduling jobs with specified times
+	wakeup chan struct{}  // wakes the loop of Start up when jobs change
+	store  Store          // optional store of the named jobs, shared by replicas
+	owner  string         // the replica name which claims runs in store
+	scope  tally.Scope    // metrics scope of the jobs
+	logger *logger.Logger // logs the runs of the jobs
+
+	ctx     context.Context    // parent context of all runs
+	cancel  context.CancelFunc // cancels running jobs when Stop times out
+	wg      sync.WaitGroup     // tracks the runs in flight
+	runMu   sync.Mutex         // guards stopped against dispatching
+	stopped bool               // no more runs are dispatched once stopped
+	stopCh  chan struct{}      // closed by Stop to end the loop of Start
 }
 
 // NewScheduler creates a new scheduler
 func NewScheduler() *Scheduler {
+	return NewSchedulerWithContext(context.Background())
+}
+
+// NewSchedulerWithContext creates a new scheduler whose jobs run with contexts derived from ctx
+func NewSchedulerWithContext(ctx context.Context) *Scheduler {
+	ctx, cancel := context.WithCancel(ctx)
 	return &Scheduler{
-		jobs: [MAXJOBNUM]*Task{},
-		size: 0,
-		loc:  time.Local,
+		jobs:   taskHeap{},
+		loc:    time.Local,
+		wakeup: make(chan struct{}, 1),
+		scope:  metrics.DefaultTallyScope.Scope.SubScope("schedule"),
+		logger: logger.GetLogger("pkg/common/schedule", "Scheduler"),
+		ctx:    ctx,
+		cancel: cancel,
+		stopCh: make(chan struct{}),
 	}
 }
 
-// Tasks returns the list of Tasks from the Scheduler
+// Tasks returns the list of Tasks from the Scheduler, ordered by their next run time
 func (s *Scheduler) Tasks() []*Task {
-	return s.jobs[:s.size]
-}
-
-func (s *Scheduler) Len() int {
-	return s.size
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tasks()
 }
 
-func (s *Scheduler) Swap(i, j int) {
-	s.jobs[i], s.jobs[j] = s.jobs[j], s.jobs[i]
+func (s *Scheduler) tasks() []*Task {
+	tasks := append([]*Task(nil), s.jobs...)
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].nextRun.Before(tasks[j].nextRun)
+	})
+	return tasks
 }
 
-func (s *Scheduler) Less(i, j int) bool {
-	return s.jobs[j].nextRun.Unix() >= s.jobs[i].nextRun.Unix()
+// Len returns the number of jobs
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs.Len()
 }
 
 // ChangeLoc changes the default time location
 func (s *Scheduler) ChangeLoc(newLocation *time.Location) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.loc = newLocation
 }
 
-// Get the current runnable jobs, which shouldRun is True
-func (s *Scheduler) getRunnableTasks() (runningTasks [MAXJOBNUM]*Task, n int) {
-	runnableTasks := [MAXJOBNUM]*Task{}
-	n = 0
-	sort.Sort(s)
-	for i := 0; i < s.size; i++ {
-		if s.jobs[i].shouldRun() {
-			runnableTasks[n] = s.jobs[i]
-			n++
-		} else {
-			break
+// SetStore persists the named jobs in store, and claims their runs as owner so that
+// every run is executed by one replica only. owner should be unique per replica.
+// It should be called before jobs are added
+func (s *Scheduler) SetStore(store Store, owner string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = store
+	s.owner = owner
+}
+
+// SetTallyScope publishes the metrics of the jobs added afterwards through ts, in the "schedule" sub scope
+func (s *Scheduler) SetTallyScope(ts *metrics.TallyScope) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scope = ts.Scope.SubScope("schedule")
+}
+
+// SetLogger sets the logger of the runs of the jobs added afterwards
+func (s *Scheduler) SetLogger(l *logger.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = l
+}
+
+// add schedules the job, called by Task.Do. Named jobs are saved in the store,
+// and the runs missed since the last run in the store follow the job's catch-up policy.
+// It returns the error of the store, jobs which can not be scheduled are kept but never run
+func (s *Scheduler) add(job *Task) error {
+	s.mu.Lock()
+	store, owner := s.store, s.owner
+	if job.metrics == nil {
+		job.logger = s.logger
+		job.metrics = newTaskMetrics(s.scope, job.name)
+	}
+	s.mu.Unlock()
+
+	var lastTick time.Time
+	if store != nil && job.name != "" {
+		ctx, cancel := context.WithTimeout(s.ctx, storeTimeout)
+		defer cancel()
+
+		state, err := store.Load(ctx, job.name)
+		switch {
+		case err == nil:
+			lastTick = state.LastRun
+		case err != ErrJobNotFound:
+			return err
 		}
+		if err := store.Save(ctx, &JobState{Name: job.name, Spec: job.spec(), Tags: job.tags}); err != nil {
+			return err
+		}
+		job.store, job.owner = store, owner
+	}
+
+	s.mu.Lock()
+	if err := job.catchUpFrom(lastTick); err != nil {
+		job.nextRun = never
 	}
-	return runnableTasks, n
+	if job.index < 0 {
+		heap.Push(&s.jobs, job)
+	} else {
+		heap.Fix(&s.jobs, job.index)
+	}
+	s.mu.Unlock()
+
+	s.notify()
+	return nil
+}
+
+// notify wakes the loop of Start up to recompute the sleep time
+func (s *Scheduler) notify() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Get the current runnable jobs, which shouldRun is True
+func (s *Scheduler) getRunnableTasks() []*Task {
+	var runnableTasks []*Task
+	for s.jobs.Len() > 0 && s.jobs[0].shouldRun() {
+		runnableTasks = append(runnableTasks, heap.Pop(&s.jobs).(*Task))
+	}
+	return runnableTasks
 }
 
 // NextRun datetime when the next job should run.
 func (s *Scheduler) NextRun() (*Task, time.Time) {
-	if s.size <= 0 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.jobs.Len() <= 0 {
 		return nil, time.Now()
 	}
-	sort.Sort(s)
 	return s.jobs[0], s.jobs[0].nextRun
 }
 
-// Every schedule a new periodic job with interval
+// untilNext returns how long to wait for the next due job
+func (s *Scheduler) untilNext() time.Duration {
+	job, next := s.NextRun()
+	if job == nil {
+		return idleWait
+	}
+	d := time.Until(next)
+	if d < 0 {
+		return 0
+	}
+	if d > idleWait {
+		return idleWait
+	}
+	return d
+}
+
+// Every schedule a new periodic job with interval.
+// The job is added to the scheduler by Do
 func (s *Scheduler) Every(interval uint64) *Task {
-	job := NewTask(interval).Loc(s.loc)
-	s.jobs[s.size] = job
-	s.size++
+	s.mu.Lock()
+	loc := s.loc
+	s.mu.Unlock()
+
+	job := NewTask(interval).Loc(loc)
+	job.scheduler = s
 	return job
 }
 
-// RunPending runs all the jobs that are scheduled to run.
+// RunPending runs all the jobs that are scheduled to run, paused jobs are skipped.
+// Jobs which can not be scheduled again keep the error in Err and do not run again
 func (s *Scheduler) RunPending() {
-	runnableTasks, n := s.getRunnableTasks()
-
-	if n != 0 {
-		for i := 0; i < n; i++ {
-			go runnableTasks[i].run()
-			runnableTasks[i].lastRun = time.Now()
-			runnableTasks[i].scheduleNextRun()
+	s.mu.Lock()
+	runnableTasks := s.getRunnableTasks()
+	now := time.Now()
+	for _, job := range runnableTasks {
+		if job.Paused() {
+			job.catchingUp = false
+		} else {
+			job.metrics.startLag.RecordValue(now.Sub(job.nextRun).Seconds())
+			s.dispatch(job, job.dueTicks(now))
+		}
+		job.lastRun = time.Now()
+		if err := job.scheduleNextRun(); err != nil {
+			job.err = err
+			job.nextRun = never
 		}
+		heap.Push(&s.jobs, job)
 	}
+	s.mu.Unlock()
 }
 
 // RunAll run all jobs regardless if they are scheduled to run or not
@@ -112,14 +262,25 @@ func (s *Scheduler) RunAll() {
 
 // RunAllwithDelay runs all jobs with delay seconds
 func (s *Scheduler) RunAllwithDelay(d int) {
-	for i := 0; i < s.size; i++ {
-		go s.jobs[i].run()
+	for _, job := range s.Tasks() {
+		s.dispatch(job, nil)
 		if d != 0 {
 			time.Sleep(time.Duration(d))
 		}
 	}
 }
 
+// dispatch starts a run of job for the scheduled ticks unless the scheduler is stopped,
+// ticks is empty for runs not scheduled
+func (s *Scheduler) dispatch(job *Task, ticks []time.Time) bool {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.stopped {
+		return false
+	}
+	return job.dispatch(s.ctx, ticks, s.stopCh, &s.wg)
+}
+
 // Remove specific job j by function
 func (s *Scheduler) Remove(j interface{}) {
 	s.removeByCondition(func(someTask *Task) bool {
@@ -134,49 +295,46 @@ func (s *Scheduler) RemoveByRef(j *Task) {
 	})
 }
 
+// RemoveByID removes the job with id, see Task.ID
+func (s *Scheduler) RemoveByID(id string) {
+	s.removeByCondition(func(someTask *Task) bool {
+		return someTask.ID() == id
+	})
+}
+
 // RemoveByTag removes specific job j by tag
 func (s *Scheduler) RemoveByTag(t string) {
 	s.removeByCondition(func(someTask *Task) bool {
-		for _, a := range someTask.tags {
-			if a == t {
-				return true
-			}
-		}
-		return false
+		return someTask.hasTag(t)
 	})
 }
 
 func (s *Scheduler) removeByCondition(shouldRemove func(*Task) bool) {
-	i := 0
-
-	// keep deleting until no more jobs match the criteria
-	for {
-		found := false
-
-		for ; i < s.size; i++ {
-			if shouldRemove(s.jobs[i]) {
-				found = true
-				break
-			}
-		}
-
-		if !found {
-			return
-		}
-
-		for j := (i + 1); j < s.size; j++ {
-			s.jobs[i] = s.jobs[j]
-			i++
+	s.mu.Lock()
+	jobs := s.jobs[:0]
+	for _, job := range s.jobs {
+		if shouldRemove(job) {
+			job.index = -1
+			continue
 		}
-		s.size--
-		s.jobs[s.size] = nil
+		jobs = append(jobs, job)
+	}
+	for i := len(jobs); i < len(s.jobs); i++ {
+		s.jobs[i] = nil
 	}
+	s.jobs = jobs
+	heap.Init(&s.jobs)
+	s.mu.Unlock()
+
+	s.notify()
 }
 
 // Scheduled checks if specific job j was already added
 func (s *Scheduler) Scheduled(j interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	for _, job := range s.jobs {
-		if job != nil && job.taskFunc == getFunctionName(j) {
+		if job.taskFunc == getFunctionName(j) {
 			return true
 		}
 	}
@@ -185,33 +343,108 @@ func (s *Scheduler) Scheduled(j interface{}) bool {
 
 // Clear delete all scheduled jobs
 func (s *Scheduler) Clear() {
-	for i := 0; i < s.size; i++ {
-		s.jobs[i] = nil
+	s.mu.Lock()
+	for _, job := range s.jobs {
+		job.index = -1
 	}
-	s.size = 0
+	s.jobs = taskHeap{}
+	s.mu.Unlock()
+
+	s.notify()
 }
 
-// Start all the pending jobs
-// Add seconds ticker
+// Start all the pending jobs.
+// The loop sleeps until the next job is due, and is woken up when jobs are added or removed
 func (s *Scheduler) Start() chan bool {
 	stopped := make(chan bool, 1)
-	ticker := time.NewTicker(1 * time.Second)
 
 	go func() {
+		timer := time.NewTimer(s.untilNext())
+		defer timer.Stop()
 		for {
 			select {
-			case <-ticker.C:
+			case <-timer.C:
 				s.RunPending()
+			case <-s.wakeup:
 			case <-stopped:
-				ticker.Stop()
 				return
+			case <-s.stopCh:
+				return
+			}
+
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
 			}
+			timer.Reset(s.untilNext())
 		}
 	}()
 
 	return stopped
 }
 
+// Stop stops dispatching jobs and waits for the runs in flight to return.
+// If ctx is done first, the contexts of the running jobs are cancelled and ctx.Err() is returned.
+// Queued runs are dropped. A stopped scheduler can not be started again
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.runMu.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.stopCh)
+	}
+	s.runMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+// taskHeap is a min-heap of tasks ordered by nextRun, implements heap.Interface
+type taskHeap []*Task
+
+func (h taskHeap) Len() int {
+	return len(h)
+}
+
+func (h taskHeap) Less(i, j int) bool {
+	return h[i].nextRun.Before(h[j].nextRun)
+}
+
+func (h taskHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *taskHeap) Push(x interface{}) {
+	task := x.(*Task)
+	task.index = len(*h)
+	*h = append(*h, task)
+}
+
+func (h *taskHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	task := old[n-1]
+	old[n-1] = nil
+	task.index = -1
+	*h = old[:n-1]
+	return task
+}
+
 // The following methods are shortcuts for not having to
 // create a Scheduler instance
 
@@ -250,6 +483,11 @@ func Start() chan bool {
 	return defaultScheduler.Start()
 }
 
+// Stop stops the default scheduler and waits for the running jobs
+func Stop(ctx context.Context) error {
+	return defaultScheduler.Stop(ctx)
+}
+
 // Clear all scheduled jobs
 func Clear() {
 	defaultScheduler.Clear()
@@ -262,12 +500,7 @@ func Remove(j interface{}) {
 
 // Scheduled checks if specific job j was already added
 func Scheduled(j interface{}) bool {
-	for _, job := range defaultScheduler.jobs {
-		if job != nil && job.taskFunc == getFunctionName(j) {
-			return true
-		}
-	}
-	return false
+	return defaultScheduler.Scheduled(j)
 }
 
 // NextRun gets the next running time
@@ -17,7 +17,11 @@ limitations under the License.
 package schedule
 
 import (
+	"container/heap"
+	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -116,3 +120,321 @@ func Test_DefaultScheduler(t *testing.T) {
 	Remove(fmt.Println)
 	Clear()
 }
+
+func Test_SchedulerOverlap(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tt := range []struct {
+		policy OverlapPolicy
+		want   int32
+	}{
+		{OverlapAllow, 3},
+		{OverlapSkip, 1},
+		{OverlapQueue, 3},
+	} {
+		sched := NewScheduler()
+		var count, concurrent, maxConcurrent int32
+		release := make(chan struct{})
+		err := sched.Every(1).Hour().Overlap(tt.policy).Do(func() {
+			c := atomic.AddInt32(&concurrent, 1)
+			if c > atomic.LoadInt32(&maxConcurrent) {
+				atomic.StoreInt32(&maxConcurrent, c)
+			}
+			<-release
+			atomic.AddInt32(&concurrent, -1)
+			atomic.AddInt32(&count, 1)
+		})
+		assert.Nil(err)
+
+		sched.RunAll()
+		sched.RunAll()
+		sched.RunAll()
+		assert.True(sched.Tasks()[0].Running())
+		close(release)
+
+		assert.Eventually(func() bool {
+			return atomic.LoadInt32(&count) == tt.want
+		}, time.Second, 10*time.Millisecond, tt.policy)
+		assert.Nil(sched.Stop(context.Background()))
+		assert.Equal(tt.want, atomic.LoadInt32(&count), tt.policy)
+		if tt.policy != OverlapAllow {
+			assert.Equal(int32(1), atomic.LoadInt32(&maxConcurrent), tt.policy)
+		}
+		assert.False(sched.Tasks()[0].Running())
+	}
+}
+
+func Test_SchedulerTimeout(t *testing.T) {
+	assert := assert.New(t)
+	sched := NewScheduler()
+
+	errCh := make(chan error, 1)
+	err := sched.Every(1).Hour().Timeout(50*time.Millisecond).Do(func(ctx context.Context, name string) {
+		<-ctx.Done()
+		errCh <- ctx.Err()
+	}, "timeout")
+	assert.Nil(err)
+
+	sched.RunAll()
+	select {
+	case err := <-errCh:
+		assert.Equal(context.DeadlineExceeded, err)
+	case <-time.After(time.Second):
+		t.Fatal("job context was not cancelled")
+	}
+	assert.Nil(sched.Stop(context.Background()))
+}
+
+func Test_SchedulerStop(t *testing.T) {
+	assert := assert.New(t)
+	sched := NewScheduler()
+
+	var finished int32
+	err := sched.Every(1).Hour().DoSafely(func(ctx context.Context) {
+		select {
+		case <-ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+			atomic.StoreInt32(&finished, 1)
+		}
+	})
+	assert.Nil(err)
+	sched.Start()
+	sched.RunAll()
+
+	// Wait for the running job to finish
+	assert.Nil(sched.Stop(context.Background()))
+	assert.Equal(int32(1), atomic.LoadInt32(&finished))
+
+	// No jobs are dispatched after Stop
+	sched.RunAll()
+	assert.False(sched.Tasks()[0].Running())
+
+	// Running jobs are cancelled when the deadline passes
+	sched = NewScheduler()
+	cancelled := make(chan struct{})
+	err = sched.Every(1).Hour().Do(func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	})
+	assert.Nil(err)
+	sched.RunAll()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, sched.Stop(ctx))
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("running job was not cancelled")
+	}
+}
+
+func Test_SchedulerUnlimitedJobs(t *testing.T) {
+	assert := assert.New(t)
+	sched := NewScheduler()
+
+	for i := 0; i < 2000; i++ {
+		assert.Nil(sched.Every(uint64(i%10 + 1)).Minutes().Do(functionNameC))
+	}
+	assert.Equal(2000, sched.Len())
+
+	tasks := sched.Tasks()
+	for i := 1; i < len(tasks); i++ {
+		assert.False(tasks[i].nextRun.Before(tasks[i-1].nextRun))
+	}
+	task, next := sched.NextRun()
+	assert.Equal(tasks[0], task)
+	assert.Equal(tasks[0].nextRun, next)
+
+	sched.Remove(functionNameC)
+	assert.Equal(0, sched.Len())
+}
+
+func Test_SchedulerConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	sched := NewScheduler()
+	sched.Start()
+	defer sched.Stop(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for k := 0; k < 50; k++ {
+				task := sched.Every(1).Second()
+				task.Tag(fmt.Sprintf("tag-%d", i))
+				assert.Nil(task.Do(func() {}))
+				sched.RunPending()
+				sched.NextRun()
+			}
+			sched.RemoveByTag(fmt.Sprintf("tag-%d", i))
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(0, sched.Len())
+}
+
+func Test_SchedulerWakeup(t *testing.T) {
+	assert := assert.New(t)
+	sched := NewScheduler()
+	sched.Start()
+	defer sched.Stop(context.Background())
+
+	// A job added while an empty scheduler sleeps wakes it and runs on time
+	var count int32
+	task := sched.Every(1).Second()
+	assert.Nil(task.Do(func() { atomic.AddInt32(&count, 1) }))
+	assert.Eventually(func() bool {
+		return atomic.LoadInt32(&count) >= 2
+	}, 3*time.Second, 10*time.Millisecond)
+
+	sched.RemoveByRef(task)
+	assert.Equal(0, sched.Len())
+}
+
+func Test_SchedulerUnschedulable(t *testing.T) {
+	assert := assert.New(t)
+	sched := NewScheduler()
+
+	// A job without a period stays in the scheduler but never runs on its own
+	assert.Nil(sched.Every(1).Do(functionNameC))
+	assert.Equal(1, sched.Len())
+	task, next := sched.NextRun()
+	assert.NotNil(task)
+	assert.Equal(never, next)
+	assert.Equal(idleWait, sched.untilNext())
+
+	sched.RunPending()
+	assert.False(task.Running())
+}
+
+func Test_SchedulerStoreClaim(t *testing.T) {
+	assert := assert.New(t)
+	store := NewFileStore(t.TempDir() + "/schedule.json")
+
+	// Two replicas share a store and each run executes only once
+	var count int32
+	tick := time.Now().Add(-time.Second).Truncate(time.Second)
+	replicas := []*Scheduler{NewScheduler(), NewScheduler()}
+	for i, sched := range replicas {
+		sched.SetStore(store, fmt.Sprintf("replica-%d", i))
+		task := sched.Every(1).Hour().Name("job")
+		assert.Nil(task.Do(func() { atomic.AddInt32(&count, 1) }))
+
+		sched.mu.Lock()
+		task.tick, task.nextRun = tick, tick
+		heap.Fix(&sched.jobs, task.index)
+		sched.mu.Unlock()
+	}
+	for _, sched := range replicas {
+		sched.RunPending()
+	}
+	for _, sched := range replicas {
+		assert.Nil(sched.Stop(context.Background()))
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&count))
+
+	st, err := store.Load(context.Background(), "job")
+	assert.Nil(err)
+	assert.True(tick.Equal(st.LastRun))
+	assert.Equal("every 1 hours", st.Spec)
+
+	// Manual runs do not need a claim
+	replicas[0] = NewScheduler()
+	replicas[0].SetStore(store, "replica-0")
+	assert.Nil(replicas[0].Every(1).Hour().Name("job").Do(func() { atomic.AddInt32(&count, 1) }))
+	replicas[0].RunAll()
+	assert.Nil(replicas[0].Stop(context.Background()))
+	assert.Equal(int32(2), atomic.LoadInt32(&count))
+}
+
+func Test_SchedulerStoreReplicas(t *testing.T) {
+	assert := assert.New(t)
+	store := NewFileStore(t.TempDir() + "/schedule.json")
+
+	// Replicas started at different times compute the same ticks, each tick runs once
+	var (
+		mu   sync.Mutex
+		runs = map[int64]int{}
+	)
+	var replicas []*Scheduler
+	for i := 0; i < 2; i++ {
+		sched := NewScheduler()
+		sched.SetStore(store, fmt.Sprintf("replica-%d", i))
+		assert.Nil(sched.Every(1).Second().Name("job").Do(func() {
+			mu.Lock()
+			runs[time.Now().Unix()]++
+			mu.Unlock()
+		}))
+		sched.Start()
+		replicas = append(replicas, sched)
+		time.Sleep(300 * time.Millisecond)
+	}
+	time.Sleep(3500 * time.Millisecond)
+	for _, sched := range replicas {
+		assert.Nil(sched.Stop(context.Background()))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.GreaterOrEqual(len(runs), 3)
+	for tick, n := range runs {
+		assert.Equal(1, n, "tick %d", tick)
+	}
+}
+
+func Test_SchedulerCatchUp(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	// ticks are aligned to the minute, 9 runs are missed since lastTick
+	now := time.Now()
+	lastTick := now.Truncate(time.Minute).Add(-9 * time.Minute)
+
+	newStore := func() Store {
+		store := NewFileStore(t.TempDir() + "/schedule.json")
+		ok, err := store.Claim(ctx, "job", lastTick, "old")
+		assert.Nil(err)
+		assert.True(ok)
+		return store
+	}
+
+	// skip: schedule from now
+	sched := NewScheduler()
+	sched.SetStore(newStore(), "replica")
+	task := sched.Every(1).Minute().Name("job")
+	assert.Nil(task.Do(func() {}))
+	assert.True(task.NextScheduledTime().After(now))
+
+	// once: catch up a single run
+	var count int32
+	sched = NewScheduler()
+	sched.SetStore(newStore(), "replica")
+	task = sched.Every(1).Minute().Name("job").CatchUp(CatchUpOnce)
+	assert.Nil(task.Do(func() { atomic.AddInt32(&count, 1) }))
+	assert.Equal(lastTick.Add(time.Minute), task.NextScheduledTime())
+	sched.RunPending()
+	sched.RunPending()
+	assert.Nil(sched.Stop(ctx))
+	assert.Equal(int32(1), atomic.LoadInt32(&count))
+	assert.True(task.NextScheduledTime().After(now))
+
+	// all: catch up every missed run
+	count = 0
+	sched = NewScheduler()
+	store := newStore()
+	sched.SetStore(store, "replica")
+	task = sched.Every(1).Minute().Name("job").CatchUp(CatchUpAll)
+	assert.Nil(task.Do(func() { atomic.AddInt32(&count, 1) }))
+	sched.RunPending()
+	assert.Eventually(func() bool {
+		return atomic.LoadInt32(&count) == 9
+	}, time.Second, 10*time.Millisecond)
+	sched.RunPending()
+	assert.Nil(sched.Stop(ctx))
+	assert.Equal(int32(9), atomic.LoadInt32(&count))
+	assert.True(task.NextScheduledTime().After(now))
+
+	st, err := store.Load(ctx, "job")
+	assert.Nil(err)
+	assert.True(lastTick.Add(9 * time.Minute).Equal(st.LastRun))
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"context"
+	"time"
+)
+
+// JobState is the persisted definition and run state of a named job
+type JobState struct {
+	Name    string    `json:"name"`
+	Spec    string    `json:"spec,omitempty"`    // human readable schedule of the job
+	Tags    []string  `json:"tags,omitempty"`    // tags of the job
+	LastRun time.Time `json:"lastRun,omitempty"` // scheduled time of the last claimed run
+	Owner   string    `json:"owner,omitempty"`   // replica which claimed the last run
+}
+
+// Store persists job definitions and the last run of jobs, shared by the replicas of a scheduler.
+// Only named jobs are persisted, see Task.Name
+type Store interface {
+	// Save persists the definition of the job, Name, Spec and Tags, the run state is kept
+	Save(ctx context.Context, state *JobState) error
+	// Load returns the state of the job, ErrJobNotFound if it is not saved or claimed
+	Load(ctx context.Context, name string) (*JobState, error)
+	// List returns the states of all the jobs, ordered by name
+	List(ctx context.Context) ([]*JobState, error)
+	// Claim takes the run of the job scheduled at tick for owner. It returns false if the run,
+	// or a later one, is already claimed, so that a run is executed by at most one replica
+	Claim(ctx context.Context, name string, tick time.Time, owner string) (bool, error)
+}
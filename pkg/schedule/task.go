@@ -17,12 +17,27 @@ limitations under the License.
 package schedule
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"math/rand"
 	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/kubeservice-stack/common/pkg/logger"
+	"github.com/kubeservice-stack/common/pkg/utils"
 )
 
+var taskLogger = logger.GetLogger("pkg/common/schedule", "Task")
+
+// taskSeq generates the ids of tasks
+var taskSeq uint64
+
+// invoker calls the job function once, returning its results and error
+type invoker func(ctx context.Context) ([]interface{}, error)
+
 // Task struct keeping information about job
 type Task struct {
 	interval uint64                   // pause interval * unit between runs
@@ -34,10 +49,48 @@ type Task struct {
 	lastRun  time.Time                // datetime of last run
 	nextRun  time.Time                // datetime of next run
 	startDay time.Weekday             // Specific day of the week to start on
-	funcs    map[string]interface{}   // Map for the function task store
-	fparams  map[string][]interface{} // Map for function and  params of function
+	invoke   invoker                  // calls the job function, set by Do or DoFunc
 	lock     bool                     // lock the job from running at same time form multiple instances
 	tags     []string                 // allow the user to tag jobs with certain labels
+	schedule Schedule                 // optional cron schedule, takes precedence over interval and unit
+	jitter   time.Duration            // max random delay added to every scheduled run
+	monthDay int                      // day of month for monthly jobs, negative counts from the end of month
+	overlap  OverlapPolicy            // what to do when the job is due while still running
+	timeout  time.Duration            // optional timeout of a single run, applied to the job's context
+
+	hooks    taskHooks                // callbacks around every run
+	retries  int                      // max retries of a failed run
+	retryMin time.Duration            // first backoff wait between retries
+	retryMax time.Duration            // max backoff wait between retries
+
+	mu      sync.Mutex    // guards active, queued and history
+	active  int           // number of runs in flight
+	queued  [][]time.Time // ticks of the runs waiting for the in-flight run, only with OverlapQueue
+	history *history      // latest run records
+
+	scheduler *Scheduler // the scheduler which the job is added to by Do, nil for a standalone task
+	index     int        // index in the heap of scheduler, -1 if not in it
+
+	name       string        // unique name of the job in the Store, unnamed jobs are not persisted
+	cronExpr   string        // the cron expression of schedule, for the Store
+	catchUp    CatchUpPolicy // how runs missed during a downtime are handled
+	catchingUp bool          // the due run executes all the missed ticks, with CatchUpAll
+	tick       time.Time     // scheduled time of the next run without jitter, identifies the run in the Store
+	store      Store         // the Store of scheduler, set for named jobs
+	owner      string        // the replica name which claims runs in the Store
+
+	id      string         // unique id of the task in the process, see ID
+	logger  *logger.Logger // logs the runs, the scheduler's logger once added
+	metrics *taskMetrics   // metrics of the job, nil for a standalone task
+	paused  bool           // paused jobs are not run by the scheduler, guarded by mu
+}
+
+// taskHooks are the callbacks of a task, nil ones are skipped
+type taskHooks struct {
+	before  func(*Task)
+	after   func(*Task, RunRecord)
+	onError func(*Task, error)
+	onPanic func(*Task, interface{})
 }
 
 // NewTask creates a new job with the time interval.
@@ -48,33 +101,267 @@ func NewTask(interval uint64) *Task {
 		lastRun:  time.Unix(0, 0),
 		nextRun:  time.Unix(0, 0),
 		startDay: time.Sunday,
-		funcs:    make(map[string]interface{}),
-		fparams:  make(map[string][]interface{}),
+		id:       "task-" + strconv.FormatUint(atomic.AddUint64(&taskSeq, 1), 10),
 		tags:     []string{},
+		history:  newHistory(DefaultHistorySize),
+		index:    -1,
+		logger:   taskLogger,
 	}
 }
 
 // True if the job should be run now
 func (j *Task) shouldRun() bool {
-	return time.Now().Unix() >= j.nextRun.Unix()
+	return !time.Now().Before(j.nextRun)
+}
+
+// dispatch starts a run of the job in a new goroutine tracked by wg, following the job's
+// overlap policy. The run executes the ticks one after another, see runTicks.
+// Queued runs are dropped once stop is closed or ctx is done.
+// It returns false if the run is skipped or queued
+func (j *Task) dispatch(ctx context.Context, ticks []time.Time, stop <-chan struct{}, wg *sync.WaitGroup) bool {
+	j.mu.Lock()
+	if j.active > 0 {
+		switch j.overlap {
+		case OverlapSkip:
+			j.mu.Unlock()
+			if j.metrics != nil {
+				j.metrics.skipped.Inc(1)
+			}
+			return false
+		case OverlapQueue:
+			j.queued = append(j.queued, ticks)
+			j.mu.Unlock()
+			if j.metrics != nil {
+				j.metrics.queued.Inc(1)
+			}
+			return false
+		}
+	}
+	j.active++
+	j.mu.Unlock()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			j.runTicks(ctx, ticks, stop)
+
+			j.mu.Lock()
+			if len(j.queued) > 0 && !isDone(ctx, stop) {
+				ticks = j.queued[0]
+				j.queued = j.queued[1:]
+				j.mu.Unlock()
+				continue
+			}
+			j.queued = nil
+			j.active--
+			j.mu.Unlock()
+			return
+		}
+	}()
+	return true
+}
+
+// runTicks runs the job once for every tick claimed, in order. Without ticks,
+// e.g. by RunAll, the job runs once unclaimed
+func (j *Task) runTicks(ctx context.Context, ticks []time.Time, stop <-chan struct{}) {
+	if len(ticks) == 0 {
+		j.runWithTimeout(ctx)
+		return
+	}
+	for i, tick := range ticks {
+		if i > 0 && isDone(ctx, stop) {
+			return
+		}
+		if j.claim(ctx, tick) {
+			j.runWithTimeout(ctx)
+		}
+	}
+}
+
+// claim takes the run scheduled at tick in the Store, so that it runs on one replica only.
+// Runs of unnamed jobs are always taken
+func (j *Task) claim(ctx context.Context, tick time.Time) bool {
+	if j.store == nil || j.name == "" {
+		return true
+	}
+	ok, err := j.store.Claim(ctx, j.name, tick, j.owner)
+	if err != nil {
+		j.logger.Error("claim job run error", logger.String("job", j.name),
+			logger.Any("tick", tick), logger.Error(err))
+		return false
+	}
+	if !ok && j.metrics != nil {
+		j.metrics.claimsLost.Inc(1)
+	}
+	return ok
+}
+
+// Running returns true if a run of the job is in flight
+func (j *Task) Running() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.active > 0
+}
+
+// runWithTimeout runs the job with the job's timeout applied to ctx
+func (j *Task) runWithTimeout(ctx context.Context) RunRecord {
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+	return j.run(ctx)
 }
 
 //Run the job and immediately reschedule it
-func (j *Task) run() ([]reflect.Value, error) {
+func (j *Task) run(ctx context.Context) RunRecord {
+	record := RunRecord{Start: time.Now()}
+	if j.hooks.before != nil {
+		j.hooks.before(j)
+	}
+
 	if j.lock {
 		if locker == nil {
-			return nil, fmt.Errorf("trying to lock %s with nil locker", j.taskFunc)
+			record.Err = fmt.Errorf("trying to lock %s with nil locker", j.taskFunc)
+			return j.finish(record)
+		}
+		name := j.name
+		if name == "" {
+			name = j.taskFunc
 		}
-		key := getFunctionKey(j.taskFunc)
+		key := getFunctionKey(name)
 
 		locker.Lock(key)
 		defer locker.Unlock(key)
 	}
-	result, err := callTaskFuncWithParams(j.funcs[j.taskFunc], j.fparams[j.taskFunc])
-	if err != nil {
-		return nil, err
+
+	var backoff *utils.Backoff
+	for {
+		record.Attempts++
+		record.Results, record.Panic, record.Err = j.call(ctx)
+		if record.Err == nil || record.Attempts > j.retries {
+			break
+		}
+		if backoff == nil {
+			backoff = utils.NewBackoff(j.retryMin, j.retryMax, 2, 0.2)
+		}
+		timer := time.NewTimer(backoff.Next())
+		select {
+		case <-timer.C:
+			continue
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		break
 	}
-	return result, nil
+	return j.finish(record)
+}
+
+// call calls the job function once, panics are recovered as ErrTaskPanic
+func (j *Task) call(ctx context.Context) (results []interface{}, recovered interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			results, recovered, err = nil, r, fmt.Errorf("%w: %v", ErrTaskPanic, r)
+			if j.metrics != nil {
+				j.metrics.panics.Inc(1)
+			}
+			if j.hooks.onPanic != nil {
+				j.hooks.onPanic(j, r)
+			} else {
+				j.logger.Error("job panic", logger.String("job", j.ID()), logger.Any("panic", r), logger.Stack())
+			}
+		}
+	}()
+
+	if j.invoke == nil {
+		return nil, nil, ErrNotAFunction
+	}
+	results, err = j.invoke(ctx)
+	return results, nil, err
+}
+
+// finish records the run and calls the error and after hooks
+func (j *Task) finish(record RunRecord) RunRecord {
+	record.Duration = time.Since(record.Start)
+
+	j.mu.Lock()
+	j.history.add(record)
+	j.mu.Unlock()
+
+	if j.metrics != nil {
+		j.metrics.runs.Inc(1)
+		j.metrics.duration.RecordValue(record.Duration.Seconds())
+		if record.Err != nil {
+			j.metrics.failures.Inc(1)
+		}
+	}
+	if record.Err != nil {
+		j.logger.Error("job run failed", logger.String("job", j.ID()), logger.Any("duration", record.Duration),
+			logger.Int64("attempts", int64(record.Attempts)), logger.Error(record.Err))
+	} else {
+		j.logger.Debug("job run", logger.String("job", j.ID()), logger.Any("duration", record.Duration),
+			logger.Int64("attempts", int64(record.Attempts)))
+	}
+
+	if record.Err != nil && j.hooks.onError != nil {
+		j.hooks.onError(j, record.Err)
+	}
+	if j.hooks.after != nil {
+		j.hooks.after(j, record)
+	}
+	return record
+}
+
+// History returns the latest runs of the job, from the oldest to the latest
+func (j *Task) History() []RunRecord {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.history.list()
+}
+
+// KeepHistory sets the number of latest runs kept by the job, 0 disables the history
+func (j *Task) KeepHistory(n int) *Task {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.history = newHistory(n)
+	return j
+}
+
+// BeforeRun sets the hook called before every run
+func (j *Task) BeforeRun(fn func(*Task)) *Task {
+	j.hooks.before = fn
+	return j
+}
+
+// AfterRun sets the hook called after every run, retries included, with the run's record
+func (j *Task) AfterRun(fn func(*Task, RunRecord)) *Task {
+	j.hooks.after = fn
+	return j
+}
+
+// OnError sets the hook called when a run finally fails, after all retries
+func (j *Task) OnError(fn func(*Task, error)) *Task {
+	j.hooks.onError = fn
+	return j
+}
+
+// OnPanic sets the hook called with the recovered value when an attempt panics.
+// Without it the panic is logged
+func (j *Task) OnPanic(fn func(*Task, interface{})) *Task {
+	j.hooks.onPanic = fn
+	return j
+}
+
+// Retry retries a failed run at most n times, waiting an exponential backoff from min up to max
+// between the attempts. A run fails if the job function panics or its last return value is a non-nil error
+//
+//	s.Every(1).Hour().Retry(3, time.Second, time.Minute).Do(func(ctx context.Context) error { return nil })
+func (j *Task) Retry(n int, min, max time.Duration) *Task {
+	j.retries = n
+	j.retryMin = min
+	j.retryMax = max
+	return j
 }
 
 // Err should be checked to ensure an error didn't occur creating the job
@@ -82,40 +369,56 @@ func (j *Task) Err() error {
 	return j.err
 }
 
-// Do specifies the taskFunc that should be called every time the job runs
+// Do specifies the taskFunc that should be called every time the job runs, by reflection.
+// If taskFun takes a context.Context as its first argument, the job's context is passed
+// and the other params follow it. The context is cancelled on timeout or when the scheduler stops.
+// If the last return value of taskFun is an error, a non-nil one fails the run.
+// The params are checked when the job runs, prefer DoFunc and DoWith which are checked by the compiler
+//
+//	s.Every(1).Minute().Timeout(10 * time.Second).Do(func(ctx context.Context, name string) {}, "name")
 func (j *Task) Do(taskFun interface{}, params ...interface{}) error {
 	if j.err != nil {
 		return j.err
 	}
 
 	typ := reflect.TypeOf(taskFun)
-	if typ.Kind() != reflect.Func {
+	if typ == nil || typ.Kind() != reflect.Func {
 		return ErrNotAFunction
 	}
-	fname := getFunctionName(taskFun)
-	j.funcs[fname] = taskFun
-	j.fparams[fname] = params
-	j.taskFunc = fname
+	j.taskFunc = getFunctionName(taskFun)
+	j.invoke = reflectInvoker(taskFun, params)
+	return j.submit()
+}
+
+// submit adds the job to its scheduler, or schedules the first run of a standalone task
+func (j *Task) submit() error {
+	if j.scheduler != nil {
+		return j.scheduler.add(j)
+	}
+	_ = j.prepare()
+	return nil
+}
 
+// prepare schedules the first run of the job if it is not scheduled yet
+func (j *Task) prepare() error {
 	now := time.Now().In(j.loc)
 	if !j.nextRun.After(now) {
-		j.scheduleNextRun()
+		return j.scheduleNextRun()
 	}
-
 	return nil
 }
 
 // DoSafely does the same thing as Do, but logs unexpected panics, instead of unwinding them up the chain
 // Deprecated: DoSafely exists due to historical compatibility and will be removed soon. Use Do instead
 func (j *Task) DoSafely(taskFun interface{}, params ...interface{}) error {
-	recoveryWrapperFunc := func() {
+	recoveryWrapperFunc := func(ctx context.Context) {
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("Internal panic occurred: %s", r)
+				taskLogger.Error("job panic", logger.String("job", getFunctionName(taskFun)), logger.Any("panic", r))
 			}
 		}()
 
-		_, _ = callTaskFuncWithParams(taskFun, params)
+		_, _ = callTaskFuncWithContext(ctx, taskFun, params)
 	}
 
 	return j.Do(recoveryWrapperFunc)
@@ -167,6 +470,16 @@ func (j *Task) Untag(t string) {
 	j.tags = newTags
 }
 
+// hasTag returns true if the job is tagged with t
+func (j *Task) hasTag(t string) bool {
+	for _, tag := range j.tags {
+		if tag == t {
+			return true
+		}
+	}
+	return false
+}
+
 // Tags returns the tags attached to the job
 func (j *Task) Tags() []string {
 	return j.tags
@@ -198,6 +511,34 @@ func (j *Task) roundToMidnight(t time.Time) time.Time {
 	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, j.loc)
 }
 
+// atDate returns the wall clock atTime of the given date in j.loc.
+// Dates out of range are normalized, e.g. Feb 30 becomes Mar 2
+func (j *Task) atDate(year int, month time.Month, day int) time.Time {
+	hour := int(j.atTime / time.Hour)
+	min := int((j.atTime % time.Hour) / time.Minute)
+	sec := int((j.atTime % time.Minute) / time.Second)
+	return time.Date(year, month, day, hour, min, sec, 0, j.loc)
+}
+
+// atMonthDay returns the atTime of monthDay in the given month, clamped to the last day of month
+func (j *Task) atMonthDay(year int, month time.Month) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, j.loc).Day()
+	day := j.monthDay
+	switch {
+	case day == 0:
+		day = 1
+	case day < 0:
+		day = last + day + 1
+	}
+	if day < 1 {
+		day = 1
+	}
+	if day > last {
+		day = last
+	}
+	return j.atDate(year, month, day)
+}
+
 // scheduleNextRun Compute the instant when this job should run next
 func (j *Task) scheduleNextRun() error {
 	now := time.Now()
@@ -205,33 +546,145 @@ func (j *Task) scheduleNextRun() error {
 		j.lastRun = now
 	}
 
-	periodDuration, err := j.periodDuration()
+	next, err := j.next(j.lastRun, now)
+	if err != nil {
+		return err
+	}
+	j.tick = next
+	j.nextRun = next.Add(j.randomJitter())
+	return nil
+}
+
+// dueTicks returns the ticks of the due run: the scheduled tick, followed by
+// all the ticks missed until now when catching up with CatchUpAll
+func (j *Task) dueTicks(now time.Time) []time.Time {
+	ticks := []time.Time{j.tick}
+	if !j.catchingUp {
+		return ticks
+	}
+	j.catchingUp = false
+	for len(ticks) < MaxCatchUpRuns {
+		last := ticks[len(ticks)-1]
+		next, err := j.next(last, last.Add(time.Nanosecond))
+		if err != nil || !next.Before(now) {
+			break
+		}
+		ticks = append(ticks, next)
+	}
+	return ticks
+}
+
+// catchUpFrom schedules the first run after a downtime following the catch-up policy,
+// lastTick is the last run claimed in the Store
+func (j *Task) catchUpFrom(lastTick time.Time) error {
+	if lastTick.IsZero() || j.catchUp == CatchUpSkip {
+		return j.prepare()
+	}
+	missed, err := j.next(lastTick, lastTick.Add(time.Nanosecond))
 	if err != nil {
 		return err
 	}
+	if !missed.Before(time.Now()) {
+		return j.prepare()
+	}
+	j.tick, j.nextRun = missed, missed
+	j.catchingUp = j.catchUp == CatchUpAll
+	return nil
+}
+
+// spec describes the schedule of the job, for the Store
+func (j *Task) spec() string {
+	if j.cronExpr != "" {
+		return j.cronExpr
+	}
+	spec := fmt.Sprintf("every %d %s", j.interval, j.unit.name())
+	switch j.unit {
+	case weeks:
+		spec += " on " + j.startDay.String()
+	case months:
+		spec += fmt.Sprintf(" on day %d", j.monthDay)
+	}
+	if j.unit >= days {
+		spec += " at " + j.GetAt()
+	}
+	return spec
+}
+
+// next computes the first scheduled time which is not before lastRun and now, without jitter.
+// Seconds, minutes and hours run every period after lastRun. Named jobs sharing a Store align
+// the ticks to multiples of the period instead, so that replicas compute the same ticks whatever
+// their lastRun is, and compete for the same run.
+// Calendar units (days, weeks, months) use date arithmetic in j.loc, so that the wall clock
+// time of atTime is kept across daylight saving time changes.
+func (j *Task) next(lastRun, now time.Time) (time.Time, error) {
+	lastRun, now = lastRun.In(j.loc), now.In(j.loc)
+	if j.schedule != nil {
+		from := now
+		if lastRun.After(from) {
+			from = lastRun
+		}
+		next := j.schedule.Next(from.Add(-time.Nanosecond))
+		if next.IsZero() {
+			return next, ErrNoNextRun
+		}
+		return next, nil
+	}
+
+	if j.interval == 0 {
+		return time.Time{}, ErrIntervalNotValid
+	}
+	notBefore := func(t time.Time) bool {
+		return !t.Before(now) && !t.Before(lastRun)
+	}
+	interval := int(j.interval)
 
+	var next time.Time
 	switch j.unit {
 	case seconds, minutes, hours:
-		j.nextRun = j.lastRun.Add(periodDuration)
+		periodDuration, _ := j.periodDuration()
+		next = lastRun.Add(periodDuration)
+		if j.store != nil && j.name != "" {
+			next = next.Truncate(periodDuration)
+		}
+		if !notBefore(next) {
+			// skip the missed periods at once
+			missed := now.Sub(next) / periodDuration
+			next = next.Add(missed * periodDuration)
+			for !notBefore(next) {
+				next = next.Add(periodDuration)
+			}
+		}
 	case days:
-		j.nextRun = j.roundToMidnight(j.lastRun)
-		j.nextRun = j.nextRun.Add(j.atTime)
+		for d := 0; ; d += interval {
+			if next = j.atDate(lastRun.Year(), lastRun.Month(), lastRun.Day()+d); notBefore(next) {
+				break
+			}
+		}
 	case weeks:
-		j.nextRun = j.roundToMidnight(j.lastRun)
-		dayDiff := int(j.startDay)
-		dayDiff -= int(j.nextRun.Weekday())
-		if dayDiff != 0 {
-			j.nextRun = j.nextRun.Add(time.Duration(dayDiff) * 24 * time.Hour)
+		dayDiff := int(j.startDay) - int(lastRun.Weekday())
+		for d := dayDiff; ; d += 7 * interval {
+			if next = j.atDate(lastRun.Year(), lastRun.Month(), lastRun.Day()+d); notBefore(next) {
+				break
+			}
+		}
+	case months:
+		for m := 0; ; m += interval {
+			if next = j.atMonthDay(lastRun.Year(), lastRun.Month()+time.Month(m)); notBefore(next) {
+				break
+			}
 		}
-		j.nextRun = j.nextRun.Add(j.atTime)
+	default:
+		return time.Time{}, ErrPeriodNotSpecified
 	}
+	return next, nil
+}
 
-	// advance to next possible schedule
-	for j.nextRun.Before(now) || j.nextRun.Before(j.lastRun) {
-		j.nextRun = j.nextRun.Add(periodDuration)
+// randomJitter returns a random delay in [0, jitter)
+func (j *Task) randomJitter() time.Duration {
+	if j.jitter <= 0 {
+		return 0
 	}
-
-	return nil
+	return time.Duration(rand.Int63n(int64(j.jitter)))
 }
 
 // NextScheduledTime returns the time of when this job is to run next
@@ -239,6 +692,115 @@ func (j *Task) NextScheduledTime() time.Time {
 	return j.nextRun
 }
 
+// NextN previews the next n scheduled times from now, without jitter,
+// so that operators can verify a schedule
+//
+//	s.Every(1).Month().DayOfMonth(-1).At("23:00").NextN(3)
+func (j *Task) NextN(n int) ([]time.Time, error) {
+	if j.err != nil {
+		return nil, j.err
+	}
+	now := time.Now()
+	lastRun := j.lastRun
+	if lastRun == time.Unix(0, 0) {
+		lastRun = now
+	}
+	times := make([]time.Time, 0, n)
+	for i := 0; i < n; i++ {
+		next, err := j.next(lastRun, now)
+		if err != nil {
+			return times, err
+		}
+		times = append(times, next)
+		lastRun, now = next, next.Add(time.Nanosecond)
+	}
+	return times, nil
+}
+
+// Cron schedules the job with a standard cron expression, see ParseCron.
+// The expression is evaluated in the job's location
+//
+//	s.Every(1).Cron("*/5 9-18 * * MON-FRI").Do(task)
+//	s.Every(1).Cron("@hourly").Loc(time.UTC).Do(task)
+func (j *Task) Cron(expr string) *Task {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		j.err = err
+		return j
+	}
+	j.schedule = schedule
+	j.cronExpr = expr
+	return j
+}
+
+// Overlap sets what to do when the job is due while its previous run is still in flight
+//
+//	s.Every(10).Seconds().Overlap(OverlapSkip).Do(task)
+func (j *Task) Overlap(policy OverlapPolicy) *Task {
+	j.overlap = policy
+	return j
+}
+
+// Timeout sets the timeout of a single run. The job's context is cancelled after d,
+// the job function should honor it
+func (j *Task) Timeout(d time.Duration) *Task {
+	j.timeout = d
+	return j
+}
+
+// Name sets the unique name of the job. Named jobs added to a scheduler with a Store
+// are persisted, and every run is claimed by one replica only
+//
+//	s.SetStore(NewDiscoveryStore(ds, "/schedule"), hostname)
+//	s.Every(1).Day().At("02:00").Name("cleanup").CatchUp(CatchUpOnce).Do(cleanup)
+func (j *Task) Name(name string) *Task {
+	j.name = name
+	return j
+}
+
+// GetName returns the name of the job
+func (j *Task) GetName() string {
+	return j.name
+}
+
+// ID identifies the job in logs, metrics and the scheduler: the name if set, or an id
+// generated in the order the tasks are created, e.g. "task-1". Unlike the function name,
+// it is unique for every task
+func (j *Task) ID() string {
+	if j.name != "" {
+		return j.name
+	}
+	return j.id
+}
+
+// Paused returns true if the job is paused by the scheduler
+func (j *Task) Paused() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.paused
+}
+
+func (j *Task) setPaused(paused bool) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	changed := j.paused != paused
+	j.paused = paused
+	return changed
+}
+
+// CatchUp sets how the runs missed during a downtime are handled, it only applies to
+// named jobs of a scheduler with a Store
+func (j *Task) CatchUp(policy CatchUpPolicy) *Task {
+	j.catchUp = policy
+	return j
+}
+
+// Jitter adds a random delay in [0, max) to every scheduled run to spread load
+func (j *Task) Jitter(max time.Duration) *Task {
+	j.jitter = max
+	return j
+}
+
 // set the job's unit with seconds,minutes,hours...
 func (j *Task) mustInterval(i uint64) error {
 	if j.interval != i {
@@ -314,6 +876,31 @@ func (j *Task) Week() *Task {
 	return j.Weeks()
 }
 
+// Months set the job's unit with months
+func (j *Task) Months() *Task {
+	return j.setUnit(months)
+}
+
+// Month sets the job's unit with month, which interval is 1
+func (j *Task) Month() *Task {
+	j.mustInterval(1)
+	return j.Months()
+}
+
+// DayOfMonth sets the day of month for monthly jobs. Negative values count from
+// the end of month, -1 is the last day. Days beyond the end of a month run on its last day
+//
+//	s.Every(1).Month().DayOfMonth(15).At("10:30").Do(task)
+//	s.Every(3).Months().DayOfMonth(-1).Do(task)
+func (j *Task) DayOfMonth(day int) *Task {
+	if day == 0 || day > 31 || day < -31 {
+		j.err = ErrDayOfMonthNotValid
+		return j
+	}
+	j.monthDay = day
+	return j.Months()
+}
+
 // Weekday start job on specific Weekday
 func (j *Task) Weekday(startDay time.Weekday) *Task {
 	j.mustInterval(1)
@@ -363,7 +950,8 @@ func (j *Task) Sunday() *Task {
 	return j.Weekday(time.Sunday)
 }
 
-// Lock prevents job to run from multiple instances of gocron
+// Lock prevents job to run from multiple instances of gocron.
+// The lock is keyed by the job name if set, otherwise by the function name
 func (j *Task) Lock() *Task {
 	j.lock = true
 	return j
@@ -17,6 +17,8 @@ limitations under the License.
 package schedule
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -142,3 +144,219 @@ func Test_TaskLock(t *testing.T) {
 	err = task1.Lock().Hours().At(now.Format("15:04:05")).Loc(time.UTC).Do(CallBackPanic)
 	assert.Nil(err)
 }
+
+func Test_TaskMonthly(t *testing.T) {
+	assert := assert.New(t)
+	loc := time.UTC
+	from := mustTime(t, loc, "2023-01-20 12:00:00")
+
+	task := NewTask(1).Month().DayOfMonth(31).Loc(loc).At("10:30")
+	assert.Nil(task.Err())
+	next, err := task.next(from, from)
+	assert.Nil(err)
+	assert.Equal(mustTime(t, loc, "2023-01-31 10:30:00"), next)
+	next, err = task.next(next.Add(time.Second), next.Add(time.Second))
+	assert.Nil(err)
+	assert.Equal(mustTime(t, loc, "2023-02-28 10:30:00"), next)
+
+	task = NewTask(1).Month().DayOfMonth(-1).Loc(loc)
+	next, err = task.next(mustTime(t, loc, "2024-02-01 00:00:01"), mustTime(t, loc, "2024-02-01 00:00:01"))
+	assert.Nil(err)
+	assert.Equal(mustTime(t, loc, "2024-02-29 00:00:00"), next)
+
+	task = NewTask(3).Months().DayOfMonth(1).Loc(loc)
+	next, err = task.next(from, from)
+	assert.Nil(err)
+	assert.Equal(mustTime(t, loc, "2023-04-01 00:00:00"), next)
+
+	task = NewTask(1).Month().DayOfMonth(32)
+	assert.Equal(ErrDayOfMonthNotValid, task.Err())
+	task = NewTask(1).Month().DayOfMonth(0)
+	assert.Equal(ErrDayOfMonthNotValid, task.Err())
+}
+
+func Test_TaskDaysDST(t *testing.T) {
+	assert := assert.New(t)
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("timezone data not available")
+	}
+
+	task := NewTask(1).Day().Loc(loc).At("09:00")
+	from := mustTime(t, loc, "2023-03-11 09:00:01")
+	next, err := task.next(from, from)
+	assert.Nil(err)
+	assert.Equal(mustTime(t, loc, "2023-03-12 09:00:00"), next)
+	assert.Equal(9, next.Hour())
+}
+
+func Test_TaskCron(t *testing.T) {
+	assert := assert.New(t)
+
+	task := NewTask(1).Cron("0 0 * * *").Loc(time.UTC)
+	from := mustTime(t, time.UTC, "2023-01-01 10:00:00")
+	next, err := task.next(from, from)
+	assert.Nil(err)
+	assert.Equal(mustTime(t, time.UTC, "2023-01-02 00:00:00"), next)
+
+	task = NewTask(1).Cron("bad expr")
+	assert.ErrorIs(task.Err(), ErrCronFormat)
+	_, err = task.NextN(1)
+	assert.ErrorIs(err, ErrCronFormat)
+
+	task = NewTask(1).Cron("0 0 30 2 *")
+	_, err = task.next(from, from)
+	assert.Equal(ErrNoNextRun, err)
+}
+
+func Test_TaskNextN(t *testing.T) {
+	assert := assert.New(t)
+
+	task := NewTask(1).Cron("@every 1m")
+	times, err := task.NextN(3)
+	assert.Nil(err)
+	assert.Len(times, 3)
+	assert.Equal(time.Minute, times[1].Sub(times[0]))
+	assert.Equal(time.Minute, times[2].Sub(times[1]))
+
+	task = NewTask(2).Hours()
+	times, err = task.NextN(2)
+	assert.Nil(err)
+	assert.Equal(2*time.Hour, times[1].Sub(times[0]))
+
+	// standalone jobs are not aligned, they run a full period after lastRun
+	for _, n := range []uint64{1, 7} {
+		task = NewTask(n).Hours()
+		lastRun := time.Date(2023, 5, 1, 10, 59, 59, 0, time.Local)
+		next, err := task.next(lastRun, lastRun)
+		assert.Nil(err)
+		assert.Equal(lastRun.Add(time.Duration(n)*time.Hour), next)
+	}
+
+	task = NewTask(0).Hours()
+	_, err = task.NextN(1)
+	assert.Equal(ErrIntervalNotValid, err)
+}
+
+func Test_TaskJitter(t *testing.T) {
+	assert := assert.New(t)
+
+	task := NewTask(1).Minute().Jitter(10 * time.Second)
+	for i := 0; i < 20; i++ {
+		d := task.randomJitter()
+		assert.True(d >= 0 && d < 10*time.Second)
+	}
+	assert.Nil(task.scheduleNextRun())
+	delay := task.NextScheduledTime().Sub(task.lastRun)
+	assert.True(delay >= time.Minute && delay < time.Minute+10*time.Second)
+
+	assert.Equal(time.Duration(0), NewTask(1).Minute().randomJitter())
+}
+
+func Test_TaskHooks(t *testing.T) {
+	assert := assert.New(t)
+	errFailed := errors.New("failed")
+
+	var events []string
+	task := NewTask(1).Hour().
+		BeforeRun(func(*Task) { events = append(events, "before") }).
+		AfterRun(func(_ *Task, r RunRecord) { events = append(events, "after") }).
+		OnError(func(_ *Task, err error) { events = append(events, "error:"+err.Error()) })
+	fail := true
+	err := task.Do(func(name string) (string, error) {
+		if fail {
+			return "", errFailed
+		}
+		return name, nil
+	}, "ok")
+	assert.Nil(err)
+
+	record := task.run(context.Background())
+	assert.Equal(errFailed, record.Err)
+	assert.Equal(1, record.Attempts)
+	assert.Equal([]string{"before", "error:failed", "after"}, events)
+
+	fail = false
+	events = nil
+	record = task.run(context.Background())
+	assert.True(record.Succeeded())
+	assert.Equal([]interface{}{"ok", nil}, record.Results)
+	assert.Equal([]string{"before", "after"}, events)
+
+	history := task.History()
+	assert.Len(history, 2)
+	assert.False(history[0].Succeeded())
+	assert.True(history[1].Succeeded())
+}
+
+func Test_TaskPanic(t *testing.T) {
+	assert := assert.New(t)
+
+	var recovered interface{}
+	task := NewTask(1).Hour().OnPanic(func(_ *Task, r interface{}) { recovered = r })
+	assert.Nil(task.Do(CallBackPanic, "aaa"))
+
+	record := task.run(context.Background())
+	assert.ErrorIs(record.Err, ErrTaskPanic)
+	assert.Equal("error panic", record.Panic)
+	assert.Equal("error panic", recovered)
+
+	// Without OnPanic the panic is only logged
+	task = NewTask(1).Hour()
+	assert.Nil(task.Do(CallBackPanic, "aaa"))
+	record = task.run(context.Background())
+	assert.ErrorIs(record.Err, ErrTaskPanic)
+}
+
+func Test_TaskRetry(t *testing.T) {
+	assert := assert.New(t)
+
+	calls := 0
+	task := NewTask(1).Hour().Retry(3, time.Millisecond, 5*time.Millisecond)
+	assert.Nil(task.Do(func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("failed")
+		}
+		return nil
+	}))
+	record := task.run(context.Background())
+	assert.True(record.Succeeded())
+	assert.Equal(3, record.Attempts)
+
+	calls = 0
+	var errs int
+	task = NewTask(1).Hour().Retry(2, time.Millisecond, 5*time.Millisecond).
+		OnError(func(*Task, error) { errs++ })
+	assert.Nil(task.Do(func() error {
+		calls++
+		return errors.New("failed")
+	}))
+	record = task.run(context.Background())
+	assert.False(record.Succeeded())
+	assert.Equal(3, record.Attempts)
+	assert.Equal(1, errs)
+
+	// No more retries after cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	task = NewTask(1).Hour().Retry(5, time.Hour, time.Hour)
+	assert.Nil(task.Do(func() error { return errors.New("failed") }))
+	record = task.run(ctx)
+	assert.Equal(1, record.Attempts)
+}
+
+func Test_TaskKeepHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	task := NewTask(1).Hour().KeepHistory(2)
+	assert.Nil(task.Do(func() {}))
+	for i := 0; i < 5; i++ {
+		task.run(context.Background())
+	}
+	assert.Len(task.History(), 2)
+
+	task.KeepHistory(0)
+	task.run(context.Background())
+	assert.Len(task.History(), 0)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package schedule
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
 	"reflect"
@@ -41,18 +42,45 @@ func getFunctionKey(funcName string) string {
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
 func callTaskFuncWithParams(jobFunc interface{}, params []interface{}) ([]reflect.Value, error) {
+	return callTaskFuncWithContext(context.Background(), jobFunc, params)
+}
+
+// callTaskFuncWithContext calls jobFunc with params, ctx is passed as the first argument
+// if jobFunc takes a context.Context before params
+func callTaskFuncWithContext(ctx context.Context, jobFunc interface{}, params []interface{}) ([]reflect.Value, error) {
 	f := reflect.ValueOf(jobFunc)
-	if len(params) != f.Type().NumIn() {
+	typ := f.Type()
+	in := make([]reflect.Value, 0, len(params)+1)
+	if typ.NumIn() == len(params)+1 && typ.In(0) == contextType {
+		in = append(in, reflect.ValueOf(ctx))
+	}
+	if len(in)+len(params) != typ.NumIn() {
 		return nil, ErrParamsNotAdapted
 	}
-	in := make([]reflect.Value, len(params))
-	for k, param := range params {
-		in[k] = reflect.ValueOf(param)
+	for _, param := range params {
+		in = append(in, reflect.ValueOf(param))
 	}
 	return f.Call(in), nil
 }
 
+// isDone returns true if ctx is done or stop is closed
+func isDone(ctx context.Context, stop <-chan struct{}) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
 func formatTime(t string) (hour, min, sec int, err error) {
 	ts := strings.Split(t, ":")
 	if len(ts) < 2 || len(ts) > 3 {
@@ -17,6 +17,8 @@ limitations under the License.
 package schedule
 
 import (
+	"context"
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -123,3 +125,23 @@ func Test_formatTime(t *testing.T) {
 	h, m, s, err = formatTime("23:59:59.323")
 	assert.NotNil(err)
 }
+
+func Test_CallTaskFuncWithContext(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.WithValue(context.Background(), contextKey{}, "value")
+
+	res, err := callTaskFuncWithContext(ctx, func(ctx context.Context, a int) string {
+		return fmt.Sprintf("%v-%d", ctx.Value(contextKey{}), a)
+	}, []interface{}{1})
+	assert.Nil(err)
+	assert.Equal("value-1", res[0].String())
+
+	res, err = callTaskFuncWithContext(ctx, functionNameA, nil)
+	assert.Nil(err)
+	assert.True(res[0].Bool())
+
+	_, err = callTaskFuncWithContext(ctx, func(ctx context.Context) {}, []interface{}{1, 2})
+	assert.Equal(ErrParamsNotAdapted, err)
+}
+
+type contextKey struct{}
@@ -0,0 +1,98 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBackoffMin    = 100 * time.Millisecond
+	defaultBackoffMax    = 10 * time.Second
+	defaultBackoffFactor = 2
+	defaultBackoffJitter = 0.2
+)
+
+// Backoff 有上限的指数退避, 每次等待时间在 [d*(1-Jitter), d*(1+Jitter)] 之间随机
+type Backoff struct {
+	Min    time.Duration // 第一次重试等待时间
+	Max    time.Duration // 最大等待时间
+	Factor float64       // 指数因子
+	Jitter float64       // 随机抖动比例, 取值 [0, 1]
+
+	mu      sync.Mutex
+	attempt int
+	rnd     *rand.Rand
+}
+
+// NewBackoff 创建指数退避, 参数非法时使用默认值
+func NewBackoff(min, max time.Duration, factor, jitter float64) *Backoff {
+	return &Backoff{Min: min, Max: max, Factor: factor, Jitter: jitter}
+}
+
+// Next 返回下一次重试前需要等待的时间
+func (b *Backoff) Next() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	min, max, factor, jitter := b.Min, b.Max, b.Factor, b.Jitter
+	if min <= 0 {
+		min = defaultBackoffMin
+	}
+	if max <= 0 {
+		max = defaultBackoffMax
+	}
+	if max < min {
+		max = min
+	}
+	if factor < 1 {
+		factor = defaultBackoffFactor
+	}
+	if jitter < 0 || jitter > 1 {
+		jitter = defaultBackoffJitter
+	}
+
+	d := float64(min) * math.Pow(factor, float64(b.attempt))
+	if d > float64(max) || math.IsInf(d, 0) {
+		d = float64(max)
+	}
+	b.attempt++
+	if jitter > 0 {
+		if b.rnd == nil {
+			b.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+		}
+		d += d * jitter * (b.rnd.Float64()*2 - 1)
+	}
+	return time.Duration(d)
+}
+
+// Attempt 返回自上次 Reset 以来的重试次数
+func (b *Backoff) Attempt() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.attempt
+}
+
+// Reset 重置重试次数, 在成功后调用
+func (b *Backoff) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.attempt = 0
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2023 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Backoff(t *testing.T) {
+	assert := assert.New(t)
+	b := NewBackoff(10*time.Millisecond, 80*time.Millisecond, 2, 0)
+
+	assert.Equal(10*time.Millisecond, b.Next())
+	assert.Equal(20*time.Millisecond, b.Next())
+	assert.Equal(40*time.Millisecond, b.Next())
+	assert.Equal(80*time.Millisecond, b.Next())
+	assert.Equal(80*time.Millisecond, b.Next())
+	assert.Equal(5, b.Attempt())
+
+	b.Reset()
+	assert.Equal(0, b.Attempt())
+	assert.Equal(10*time.Millisecond, b.Next())
+}
+
+func Test_BackoffJitter(t *testing.T) {
+	assert := assert.New(t)
+	b := NewBackoff(100*time.Millisecond, time.Second, 2, 0.5)
+	for i := 0; i < 100; i++ {
+		b.Reset()
+		d := b.Next()
+		assert.True(d >= 50*time.Millisecond && d <= 150*time.Millisecond, d.String())
+	}
+}
+
+func Test_BackoffDefault(t *testing.T) {
+	assert := assert.New(t)
+	b := &Backoff{Jitter: -1}
+	d := b.Next()
+	assert.True(d >= 80*time.Millisecond && d <= 120*time.Millisecond, d.String())
+	for i := 0; i < 100; i++ {
+		d = b.Next()
+	}
+	assert.True(d <= 12*time.Second, d.String())
+}
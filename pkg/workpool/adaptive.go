@@ -0,0 +1,111 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workpool
+
+import (
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+const (
+	// 自适应并发默认的调整间隔
+	defaultAdaptiveInterval = time.Second
+	// 平均延迟超过基线的倍数时减小并发上限
+	adaptiveTolerance = 2.0
+	// 减小并发上限时乘以的系数
+	adaptiveBackoff = 0.9
+	// 平均延迟高于基线时, 基线向平均延迟靠近的比例, 用于适应下游能力的变化
+	adaptiveBaselineDrift = 0.05
+)
+
+type adaptiveOptions struct {
+	minWorkers int
+	maxWorkers int
+	interval   time.Duration
+}
+
+// adaptiveLimiter 按 AIMD 方式计算并发上限
+type adaptiveLimiter struct {
+	minWorkers int
+	maxWorkers int
+	interval   time.Duration
+	baseline   float64      // 基线延迟, 纳秒
+	count      atomic.Int64 // 当前窗口执行完的任务数
+	latency    atomic.Int64 // 当前窗口任务执行的总纳秒数
+}
+
+func newAdaptiveLimiter(o *adaptiveOptions) *adaptiveLimiter {
+	return &adaptiveLimiter{
+		minWorkers: o.minWorkers,
+		maxWorkers: o.maxWorkers,
+		interval:   o.interval,
+	}
+}
+
+// observe 记录一个任务的执行时间
+func (l *adaptiveLimiter) observe(d time.Duration) {
+	l.count.Inc()
+	l.latency.Add(int64(d))
+}
+
+// next 根据当前窗口的平均延迟和排队的任务数计算新的并发上限, 并开始新的窗口
+func (l *adaptiveLimiter) next(limit, queued int) int {
+	count, total := l.count.Swap(0), l.latency.Swap(0)
+	if count > 0 {
+		avg := float64(total) / float64(count)
+		switch {
+		case l.baseline == 0 || avg <= l.baseline:
+			l.baseline = avg
+			if queued > 0 {
+				limit++
+			}
+		case avg > l.baseline*adaptiveTolerance:
+			limit = int(float64(limit) * adaptiveBackoff)
+			l.baseline += (avg - l.baseline) * adaptiveBaselineDrift
+		default:
+			if queued > 0 {
+				limit++
+			}
+			l.baseline += (avg - l.baseline) * adaptiveBaselineDrift
+		}
+	}
+	if limit < l.minWorkers {
+		limit = l.minWorkers
+	}
+	if limit > l.maxWorkers {
+		limit = l.maxWorkers
+	}
+	return limit
+}
+
+// adapt 定时调整并发上限, 协程池停止后退出
+func (p *workerPool) adapt() {
+	ticker := time.NewTicker(p.limiter.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			limit := int(p.maxWorkers.Load())
+			if next := p.limiter.next(limit, p.ownerStats().QueueDepth); next != limit {
+				p.Resize(next)
+			}
+		}
+	}
+}
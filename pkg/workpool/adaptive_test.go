@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workpool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_AdaptiveLimiterNext(t *testing.T) {
+	assert := assert.New(t)
+	l := newAdaptiveLimiter(&adaptiveOptions{minWorkers: 2, maxWorkers: 10, interval: time.Second})
+
+	// 没有任务完成时只做范围限制
+	assert.Equal(2, l.next(1, 0))
+	assert.Equal(10, l.next(20, 5))
+
+	// 延迟稳定且有任务排队时加1
+	l.observe(time.Millisecond * 10)
+	assert.Equal(5, l.next(4, 3))
+	l.observe(time.Millisecond * 12)
+	assert.Equal(6, l.next(5, 3))
+	// 没有排队时保持
+	l.observe(time.Millisecond * 10)
+	assert.Equal(6, l.next(6, 0))
+
+	// 延迟超过基线2倍时乘性减小
+	l.observe(time.Millisecond * 50)
+	assert.Equal(5, l.next(6, 3))
+	l.observe(time.Millisecond * 50)
+	assert.Equal(2, l.next(2, 3))
+	assert.True(l.baseline > float64(10*time.Millisecond))
+}
+
+func Test_PoolResize(t *testing.T) {
+	assert := assert.New(t)
+	p := NewDefaultPool("resize", 1, time.Second*5).(*workerPool)
+	var _ ResizablePool = p
+
+	// 调大后排队的任务立即执行
+	release := make(chan struct{})
+	assert.Nil(p.Submit(context.Background(), func() { <-release }))
+	ran := make(chan struct{})
+	assert.Nil(p.Submit(context.Background(), func() { close(ran) }))
+	p.Resize(2)
+	<-ran
+	assert.Equal(2, p.Stats().MaxWorkers)
+
+	// 调小后忙碌的worker执行完任务后回收
+	p.Resize(4)
+	for i := 0; i < 2; i++ {
+		assert.Nil(p.Submit(context.Background(), func() { <-release }))
+	}
+	assert.Eventually(func() bool { return p.Stats().WorkersAlive == 3 }, time.Second, time.Millisecond)
+	p.Resize(0)
+	assert.Equal(1, p.Stats().MaxWorkers)
+	close(release)
+	assert.Eventually(func() bool { return p.Stats().WorkersAlive == 1 }, time.Second, time.Millisecond)
+
+	// 调小时空闲的worker立即回收
+	p.Resize(3)
+	block := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		assert.Nil(p.Submit(context.Background(), func() { <-block }))
+	}
+	assert.Eventually(func() bool { return p.Stats().WorkersAlive == 3 }, time.Second, time.Millisecond)
+	close(block)
+	assert.Eventually(func() bool { return len(p.readyWorkers) == 3 }, time.Second, time.Millisecond)
+	p.Resize(1)
+	assert.Equal(int32(1), p.Stats().WorkersAlive)
+	p.Stop()
+}
+
+func Test_PoolAdaptiveConcurrency(t *testing.T) {
+	assert := assert.New(t)
+	p := NewDefaultPool("adaptive", 1, time.Second*5, WithQueueCapacity(512),
+		WithAdaptiveConcurrency(1, 8, time.Millisecond*10))
+	for i := 0; i < 500; i++ {
+		assert.Nil(p.Submit(context.Background(), func() { time.Sleep(time.Millisecond) }))
+	}
+	assert.Eventually(func() bool { return p.Stats().MaxWorkers > 1 }, time.Second*5, time.Millisecond)
+	p.Stop()
+	assert.True(p.Stats().MaxWorkers <= 8)
+}
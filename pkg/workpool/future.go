@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workpool
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError 任务中 recover 的 panic
+type PanicError struct {
+	Value interface{} // panic 的值
+	Stack []byte      // panic 时的调用栈
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("workpool: task panic: %v", e.Value)
+}
+
+// safeCall 执行 fn, 将 panic 转换为 *PanicError
+func safeCall[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) (val T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx)
+}
+
+// Future 异步任务的结果
+type Future[T any] struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+	value  T
+	err    error
+}
+
+// SubmitFunc 提交有返回值的任务到 pool, 返回任务的 Future
+//
+//	f := workpool.SubmitFunc(pool, func(ctx context.Context) (int, error) { return 1, nil })
+//	v, err := f.Get(ctx)
+func SubmitFunc[T any](pool Pool, fn func(ctx context.Context) (T, error)) *Future[T] {
+	return SubmitFuncContext(context.Background(), pool, fn)
+}
+
+// SubmitFuncContext 同 SubmitFunc, 任务的 context 继承 ctx.
+// 提交失败时 Future 返回提交的错误, 任务被拒绝策略丢弃时返回 ErrTaskDiscarded
+func SubmitFuncContext[T any](ctx context.Context, pool Pool, fn func(ctx context.Context) (T, error)) *Future[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	f := &Future[T]{
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+	err := submitTask(ctx, pool, func() {
+		// 执行前已经取消
+		if ctx.Err() != nil {
+			f.complete(*new(T), fmt.Errorf("%w: %s", ErrTaskCancelled, ctx.Err()))
+			return
+		}
+		f.complete(safeCall(ctx, fn))
+	}, func(err error) {
+		f.complete(*new(T), err)
+	})
+	if err != nil {
+		f.complete(*new(T), err)
+	}
+	return f
+}
+
+func (f *Future[T]) complete(val T, err error) {
+	f.value, f.err = val, err
+	f.cancel()
+	close(f.done)
+}
+
+// Get 等待任务结束并返回结果, ctx 结束时返回 ctx.Err(), 任务不会被取消
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		return *new(T), ctx.Err()
+	}
+}
+
+// Done 任务结束时关闭
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Cancel 取消任务: 未开始执行的任务不再执行, 返回 ErrTaskCancelled; 执行中的任务的 context 被取消
+func (f *Future[T]) Cancel() {
+	f.cancel()
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SubmitFunc(t *testing.T) {
+	assert := assert.New(t)
+	pool := NewDefaultPool("test", 2, time.Second*5)
+	defer pool.Stop()
+
+	f := SubmitFunc(pool, func(ctx context.Context) (int, error) {
+		return 42, nil
+	})
+	v, err := f.Get(context.Background())
+	assert.Nil(err)
+	assert.Equal(42, v)
+	<-f.Done()
+
+	errFailed := errors.New("failed")
+	fs := SubmitFunc(pool, func(ctx context.Context) (string, error) {
+		return "", errFailed
+	})
+	_, err = fs.Get(context.Background())
+	assert.Equal(errFailed, err)
+}
+
+func Test_SubmitFuncPanic(t *testing.T) {
+	assert := assert.New(t)
+	pool := NewDefaultPool("test", 1, time.Second*5)
+	defer pool.Stop()
+
+	f := SubmitFunc(pool, func(ctx context.Context) (int, error) {
+		panic("boom")
+	})
+	_, err := f.Get(context.Background())
+	var perr *PanicError
+	assert.True(errors.As(err, &perr))
+	assert.Equal("boom", perr.Value)
+	assert.NotEmpty(perr.Stack)
+
+	// worker 仍然可用
+	v, err := SubmitFunc(pool, func(ctx context.Context) (int, error) { return 1, nil }).Get(context.Background())
+	assert.Nil(err)
+	assert.Equal(1, v)
+}
+
+func Test_FutureCancel(t *testing.T) {
+	assert := assert.New(t)
+	pool := NewDefaultPool("test", 1, time.Second*5)
+	defer pool.Stop()
+
+	// 占住唯一的 worker
+	release := make(chan struct{})
+	started := make(chan struct{})
+	running := SubmitFunc(pool, func(ctx context.Context) (int, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-release:
+			return 1, nil
+		}
+	})
+	<-started
+
+	ran := false
+	pending := SubmitFunc(pool, func(ctx context.Context) (int, error) {
+		ran = true
+		return 2, nil
+	})
+	pending.Cancel()
+
+	// Get 超时不影响任务
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := running.Get(ctx)
+	assert.Equal(context.DeadlineExceeded, err)
+
+	// 取消执行中的任务
+	running.Cancel()
+	_, err = running.Get(context.Background())
+	assert.Equal(context.Canceled, err)
+
+	_, err = pending.Get(context.Background())
+	assert.ErrorIs(err, ErrTaskCancelled)
+	assert.False(ran)
+	close(release)
+}
+
+func Test_SubmitFuncStopped(t *testing.T) {
+	assert := assert.New(t)
+	pool := NewDefaultPool("test", 1, time.Second*5)
+	pool.Stop()
+
+	_, err := SubmitFunc(pool, func(ctx context.Context) (int, error) { return 1, nil }).Get(context.Background())
+	assert.Equal(ErrPoolStopped, err)
+}
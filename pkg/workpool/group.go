@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workpool
+
+import (
+	"context"
+	"sync"
+)
+
+// Group 在 pool 中执行一组任务, 等待全部结束并返回第一个错误, 类似 errgroup.
+// 第一个任务失败后, 其它任务的 context 被取消
+type Group struct {
+	pool   Pool
+	cancel context.CancelFunc
+	ctx    context.Context
+
+	wg      sync.WaitGroup
+	errOnce sync.Once
+	err     error
+}
+
+// NewGroup 创建 Group, 返回的 context 在第一个任务失败或者 Wait 返回时被取消
+//
+//	g, ctx := workpool.NewGroup(ctx, pool)
+//	for _, url := range urls {
+//		url := url
+//		g.Go(func(ctx context.Context) error { return fetch(ctx, url) })
+//	}
+//	err := g.Wait()
+func NewGroup(ctx context.Context, pool Pool) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{pool: pool, ctx: ctx, cancel: cancel}, ctx
+}
+
+// Go 提交任务, 任务 panic 时以 *PanicError 作为错误返回
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+	err := submitTask(g.ctx, g.pool, func() {
+		defer g.wg.Done()
+		_, err := safeCall(g.ctx, func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, fn(ctx)
+		})
+		if err != nil {
+			g.fail(err)
+		}
+	}, func(err error) {
+		g.fail(err)
+		g.wg.Done()
+	})
+	if err != nil {
+		g.fail(err)
+		g.wg.Done()
+	}
+}
+
+func (g *Group) fail(err error) {
+	g.errOnce.Do(func() {
+		g.err = err
+		g.cancel()
+	})
+}
+
+// Wait 等待所有任务结束, 返回第一个错误
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
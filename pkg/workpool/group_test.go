@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func Test_Group(t *testing.T) {
+	assert := assert.New(t)
+	pool := NewDefaultPool("test", 4, time.Second*5)
+	defer pool.Stop()
+
+	var c atomic.Int32
+	g, _ := NewGroup(context.Background(), pool)
+	for i := 0; i < 100; i++ {
+		g.Go(func(ctx context.Context) error {
+			c.Inc()
+			return nil
+		})
+	}
+	assert.Nil(g.Wait())
+	assert.Equal(int32(100), c.Load())
+}
+
+func Test_GroupError(t *testing.T) {
+	assert := assert.New(t)
+	pool := NewDefaultPool("test", 4, time.Second*5)
+	defer pool.Stop()
+
+	errFailed := errors.New("failed")
+	g, ctx := NewGroup(context.Background(), pool)
+	g.Go(func(ctx context.Context) error {
+		return errFailed
+	})
+	g.Go(func(ctx context.Context) error {
+		// 第一个错误后 context 被取消
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.Equal(errFailed, g.Wait())
+	assert.NotNil(ctx.Err())
+
+	g, _ = NewGroup(context.Background(), pool)
+	g.Go(func(ctx context.Context) error {
+		panic("boom")
+	})
+	var perr *PanicError
+	assert.True(errors.As(g.Wait(), &perr))
+	assert.Equal("workpool: task panic: boom", perr.Error())
+}
+
+func Test_GroupStopped(t *testing.T) {
+	assert := assert.New(t)
+	pool := NewDefaultPool("test", 1, time.Second*5)
+	pool.Stop()
+
+	g, _ := NewGroup(context.Background(), pool)
+	g.Go(func(ctx context.Context) error { return nil })
+	assert.Equal(ErrPoolStopped, g.Wait())
+}
@@ -16,21 +16,138 @@ limitations under the License.
 
 package workpool
 
-import "time"
+import (
+	"context"
+	"errors"
+	"time"
 
-// TODO Task 应该有输入和输出
+	"github.com/kubeservice-stack/common/pkg/metrics"
+)
+
+var (
+	ErrPoolStopped   = errors.New("workpool: pool is stopped")
+	ErrTaskCancelled = errors.New("workpool: task is cancelled before running")
+	ErrQueueFull     = errors.New("workpool: task queue is full")
+	ErrTaskDiscarded = errors.New("workpool: task is discarded by the reject policy")
+)
+
+// Task 没有输入输出的任务, 需要返回结果和错误时使用 SubmitFunc 或 Group
 type Task func()
 
 // 开辟一个协程池：当有任务提交时，提交到协程池中运行；如果协程池都在工作，任务挂起
 type Pool interface {
 	// 提交任务
-	Submit(task Task)        // 提交任务
-	SubmitAndWait(task Task) // 提交任务并等待其执行
-	Stopped() bool           // 如果协程停止，返回true
-	Stop()                   // 停下来优雅地停止所有的勾当，所有挂起的任务将在退出前完成
-	//metrics
+	Submit(ctx context.Context, task Task) error  // 提交任务, 队列满时按照拒绝策略处理, 协程池停止时返回 ErrPoolStopped
+	SubmitAndWait(task Task)                      // 提交任务并等待其执行
+	Stopped() bool                                // 如果协程停止，返回true
+	Stop()                                        // 停下来优雅地停止所有的勾当，所有挂起的任务将在退出前完成
+	Shutdown(ctx context.Context) ([]Task, error) // 停止协程池, ctx 结束前没有执行的任务被放弃并返回
+	Stats() Stats                                 // 返回协程池运行状态快照
+}
+
+// ResizablePool 可以在运行时调整并发上限的协程池
+type ResizablePool interface {
+	Pool
+	Resize(maxWorkers int) // 调整并发上限, 自适应模式下会被之后的自适应调整覆盖
+}
+
+// discardNotifier 任务被拒绝策略丢弃时可以通知提交者的协程池
+type discardNotifier interface {
+	submitWithDiscard(ctx context.Context, task Task, discard func(error)) error
+}
+
+// submitTask 提交任务, pool 支持时任务被丢弃会调用 discard
+func submitTask(ctx context.Context, pool Pool, task Task, discard func(error)) error {
+	if dn, ok := pool.(discardNotifier); ok {
+		return dn.submitWithDiscard(ctx, task, discard)
+	}
+	return pool.Submit(ctx, task)
+}
+
+// RejectPolicy 任务队列满时的拒绝策略
+type RejectPolicy int
+
+const (
+	RejectBlock         RejectPolicy = iota // 阻塞等待队列空闲, 直到 ctx 结束, 默认策略
+	RejectAbort                             // 立即返回 ErrQueueFull
+	RejectDiscardOldest                     // 丢弃队列中最早的任务
+	RejectCallerRuns                        // 在调用者的协程中直接执行
+)
+
+type options struct {
+	queueCapacity int
+	rejectPolicy  RejectPolicy
+	tallyScope    *metrics.TallyScope
+	adaptive      *adaptiveOptions
+	// 仅 PriorityPool 使用
+	starvationTimeout time.Duration
+}
+
+// Option 协程池选项
+type Option func(*options)
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		queueCapacity:     tasksCapacity,
+		rejectPolicy:      RejectBlock,
+		tallyScope:        metrics.DefaultTallyScope,
+		starvationTimeout: defaultStarvationTimeout,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithQueueCapacity 设置任务队列长度, 默认 8.
+// 为0时任务不排队, 直接交给worker: 并发已满时 RejectAbort 和 RejectCallerRuns 立即按策略处理,
+// RejectBlock 和 RejectDiscardOldest 阻塞等待worker空闲
+func WithQueueCapacity(capacity int) Option {
+	return func(o *options) {
+		if capacity >= 0 {
+			o.queueCapacity = capacity
+		}
+	}
+}
+
+// WithRejectPolicy 设置任务队列满时的拒绝策略, 默认 RejectBlock
+func WithRejectPolicy(policy RejectPolicy) Option {
+	return func(o *options) {
+		o.rejectPolicy = policy
+	}
+}
+
+// WithTallyScope 设置metrics发布器, 默认 metrics.DefaultTallyScope, 为nil时不上报metrics
+func WithTallyScope(ts *metrics.TallyScope) Option {
+	return func(o *options) {
+		o.tallyScope = ts
+	}
+}
+
+// WithAdaptiveConcurrency 开启自适应并发: 每隔 interval 根据任务执行延迟和排队情况在 [minWorkers, maxWorkers] 内调整并发上限.
+// 延迟没有明显变大且有任务排队时上限加1, 延迟超过基线的2倍时上限乘以0.9
+func WithAdaptiveConcurrency(minWorkers, maxWorkers int, interval time.Duration) Option {
+	return func(o *options) {
+		if minWorkers < 1 {
+			minWorkers = 1
+		}
+		if maxWorkers < minWorkers {
+			maxWorkers = minWorkers
+		}
+		if interval <= 0 {
+			interval = defaultAdaptiveInterval
+		}
+		o.adaptive = &adaptiveOptions{minWorkers: minWorkers, maxWorkers: maxWorkers, interval: interval}
+	}
+}
+
+// WithStarvationTimeout 设置 PriorityPool 中任务的最长等待时间, 超过后不论优先级最先执行, 默认 1s, 小于等于0时不做饿死保护
+func WithStarvationTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.starvationTimeout = timeout
+	}
 }
 
-func NewDefaultPool(name string, maxWorkers int, idleTimeout time.Duration) Pool {
-	return NewWorkerPool(name, maxWorkers, idleTimeout)
+func NewDefaultPool(name string, maxWorkers int, idleTimeout time.Duration, opts ...Option) Pool {
+	return NewWorkerPool(name, maxWorkers, idleTimeout, opts...)
 }
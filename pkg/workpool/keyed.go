@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workpool
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// KeyedPool 按 key 分发任务的协程池: 相同 key 的任务按提交顺序串行执行, 不同 key 的任务并行执行.
+// 每个 lane 是只有一个 worker 的协程池, key 按 hash 映射到 lane.
+// 使用 RejectCallerRuns 或 RejectDiscardOldest 时, 相同 key 的任务不再保证顺序
+type KeyedPool struct {
+	name  string
+	lanes []*workerPool
+	next  atomic.Uint32 // 没有 key 的任务轮询分发到 lane
+}
+
+// NewKeyedPool 创建 lanes 个 lane 的协程池, 任务队列长度和拒绝策略对每个 lane 生效, 不支持自适应并发
+func NewKeyedPool(name string, lanes int, idleTimeout time.Duration, opts ...Option) *KeyedPool {
+	if lanes < 1 {
+		lanes = 1
+	}
+	o := newOptions(opts)
+	o.adaptive = nil
+	p := &KeyedPool{
+		name:  name,
+		lanes: make([]*workerPool, lanes),
+	}
+	for i := range p.lanes {
+		p.lanes[i] = newWorkerPool(name, 1, idleTimeout, o, p)
+	}
+	p.lanes[0].reportStats()
+	return p
+}
+
+// lane 返回 key 对应的 lane
+func (p *KeyedPool) lane(key string) *workerPool {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return p.lanes[h.Sum32()%uint32(len(p.lanes))]
+}
+
+// roundRobin 轮询返回 lane
+func (p *KeyedPool) roundRobin() *workerPool {
+	return p.lanes[p.next.Inc()%uint32(len(p.lanes))]
+}
+
+// SubmitKey 提交任务, 与之前提交的相同 key 的任务执行完后才执行
+func (p *KeyedPool) SubmitKey(ctx context.Context, key string, task Task) error {
+	return p.lane(key).Submit(ctx, task)
+}
+
+// SubmitKeyAndWait 提交任务并等待其执行
+func (p *KeyedPool) SubmitKeyAndWait(ctx context.Context, key string, task Task) error {
+	if task == nil {
+		return nil
+	}
+	done := make(chan error, 1)
+	err := p.lane(key).submitWithDiscard(ctx, func() {
+		defer close(done)
+		task()
+	}, func(err error) {
+		done <- err
+	})
+	if err != nil {
+		return err
+	}
+	return <-done
+}
+
+// Submit 提交没有 key 的任务, 轮询分发到 lane
+func (p *KeyedPool) Submit(ctx context.Context, task Task) error {
+	return p.roundRobin().Submit(ctx, task)
+}
+
+func (p *KeyedPool) submitWithDiscard(ctx context.Context, task Task, discard func(error)) error {
+	return p.roundRobin().submitWithDiscard(ctx, task, discard)
+}
+
+// SubmitAndWait 提交没有 key 的任务并等待其执行
+func (p *KeyedPool) SubmitAndWait(task Task) {
+	p.roundRobin().SubmitAndWait(task)
+}
+
+func (p *KeyedPool) Stopped() bool {
+	return p.lanes[0].Stopped()
+}
+
+// Stop 停止所有 lane, 所有挂起的任务将在退出前完成
+func (p *KeyedPool) Stop() {
+	_, _ = p.Shutdown(context.Background())
+}
+
+// Shutdown 停止所有 lane, 等待已提交的任务执行完.
+// ctx 结束时放弃还没有开始执行的任务并返回它们和 ctx.Err(), 协程池已经停止时返回 ErrPoolStopped
+func (p *KeyedPool) Shutdown(ctx context.Context) ([]Task, error) {
+	var (
+		abandoned []Task
+		err       error
+	)
+	for _, lane := range p.lanes {
+		tasks, lerr := lane.Shutdown(ctx)
+		abandoned = append(abandoned, tasks...)
+		if err == nil {
+			err = lerr
+		}
+	}
+	return abandoned, err
+}
+
+// Stats 返回所有 lane 的汇总状态
+func (p *KeyedPool) Stats() Stats {
+	st := Stats{Name: p.name}
+	for _, lane := range p.lanes {
+		ls := lane.Stats()
+		st.MaxWorkers += ls.MaxWorkers
+		st.WorkersAlive += ls.WorkersAlive
+		st.WorkersCreated += ls.WorkersCreated
+		st.WorkersKilled += ls.WorkersKilled
+		st.TasksConsumed += ls.TasksConsumed
+		st.TasksRejected += ls.TasksRejected
+		st.QueueDepth += ls.QueueDepth
+		st.QueueCapacity += ls.QueueCapacity
+	}
+	return st
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workpool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var _ Pool = (*KeyedPool)(nil)
+
+func Test_KeyedPoolOrder(t *testing.T) {
+	assert := assert.New(t)
+	p := NewKeyedPool("keyed", 4, time.Second*5, WithQueueCapacity(4))
+
+	var mu sync.Mutex
+	seqs := make(map[string][]int)
+	for i := 0; i < 200; i++ {
+		i, key := i, fmt.Sprintf("user-%d", i%7)
+		assert.Nil(p.SubmitKey(context.Background(), key, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			seqs[key] = append(seqs[key], i)
+		}))
+	}
+	p.Stop()
+
+	assert.Len(seqs, 7)
+	total := 0
+	for key, seq := range seqs {
+		total += len(seq)
+		for j := 1; j < len(seq); j++ {
+			assert.Equal(seq[j-1]+7, seq[j], key)
+		}
+	}
+	assert.Equal(200, total)
+	st := p.Stats()
+	assert.Equal("keyed", st.Name)
+	assert.Equal(4, st.MaxWorkers)
+	assert.Equal(16, st.QueueCapacity)
+	assert.Equal(int32(200), st.TasksConsumed)
+}
+
+func Test_KeyedPoolParallel(t *testing.T) {
+	assert := assert.New(t)
+	p := NewKeyedPool("keyed", 2, time.Second*5)
+
+	// 找到映射到不同 lane 的两个 key
+	blocked, other := "a", ""
+	for i := 0; other == ""; i++ {
+		if key := fmt.Sprint(i); p.lane(key) != p.lane(blocked) {
+			other = key
+		}
+	}
+
+	release := make(chan struct{})
+	assert.Nil(p.SubmitKey(context.Background(), blocked, func() { <-release }))
+	ran := false
+	assert.Nil(p.SubmitKeyAndWait(context.Background(), other, func() { ran = true }))
+	assert.True(ran)
+
+	p.SubmitAndWait(func() {})
+	close(release)
+	p.Stop()
+	assert.True(p.Stopped())
+	assert.Equal(ErrPoolStopped, p.SubmitKey(context.Background(), blocked, func() {}))
+}
+
+func Test_KeyedPoolShutdown(t *testing.T) {
+	assert := assert.New(t)
+	p := NewKeyedPool("keyed", 2, time.Second*5)
+	release := make(chan struct{})
+	assert.Nil(p.SubmitKey(context.Background(), "a", func() { <-release }))
+	assert.Nil(p.SubmitKey(context.Background(), "a", func() {}))
+	assert.Nil(p.SubmitKey(context.Background(), "a", func() {}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	abandoned, err := p.Shutdown(ctx)
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.Len(abandoned, 2)
+	close(release)
+}
@@ -0,0 +1,345 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workpool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// PriorityPool 默认的最长等待时间
+const defaultStarvationTimeout = time.Second
+
+// PriorityPool 带优先级的协程池, 优先级高的任务先执行.
+// 等待时间超过 starvationTimeout 的任务不论优先级最先执行, 防止低优先级的任务饿死
+type PriorityPool struct {
+	name                string
+	workers             *workerPool    // 执行任务的协程池, 不带任务队列
+	levels              [][]queuedTask // 每个优先级一个FIFO队列, 下标越大优先级越高
+	pending             int            // 等待中的任务数
+	capacity            int            // 等待中的任务数上限
+	rejectPolicy        RejectPolicy
+	starvationTimeout   time.Duration
+	mu                  sync.Mutex
+	ready               chan struct{} // 有新任务
+	space               chan struct{} // 队列有空位
+	empty               chan struct{} // 队列为空
+	stopped             atomic.Bool
+	onDispatcherStopped chan struct{}
+	ctx                 context.Context
+	cancel              context.CancelFunc
+}
+
+// NewPriorityPool 创建 levels 个优先级的协程池, 优先级取值 [0, levels), 越大越优先
+func NewPriorityPool(name string, maxWorkers, levels int, idleTimeout time.Duration, opts ...Option) *PriorityPool {
+	if levels < 1 {
+		levels = 1
+	}
+	o := newOptions(opts)
+	if o.queueCapacity < 1 {
+		o.queueCapacity = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &PriorityPool{
+		name:                name,
+		levels:              make([][]queuedTask, levels),
+		capacity:            o.queueCapacity,
+		rejectPolicy:        o.rejectPolicy,
+		starvationTimeout:   o.starvationTimeout,
+		ready:               make(chan struct{}, 1),
+		space:               make(chan struct{}, 1),
+		empty:               make(chan struct{}, 1),
+		onDispatcherStopped: make(chan struct{}),
+		ctx:                 ctx,
+		cancel:              cancel,
+	}
+	// 任务在 PriorityPool 中排队, workers 没有空闲时阻塞
+	p.workers = newWorkerPool(name, maxWorkers, idleTimeout, &options{
+		queueCapacity: 0,
+		rejectPolicy:  RejectBlock,
+		tallyScope:    o.tallyScope,
+		adaptive:      o.adaptive,
+	}, p)
+	p.workers.reportStats()
+	go p.dispatch()
+	return p
+}
+
+// Submit 以最低优先级提交任务
+func (p *PriorityPool) Submit(ctx context.Context, task Task) error {
+	return p.submit(ctx, 0, task, nil)
+}
+
+// SubmitPriority 以 priority 优先级提交任务, 超出范围时取最近的优先级
+func (p *PriorityPool) SubmitPriority(ctx context.Context, priority int, task Task) error {
+	return p.submit(ctx, priority, task, nil)
+}
+
+func (p *PriorityPool) submitWithDiscard(ctx context.Context, task Task, discard func(error)) error {
+	return p.submit(ctx, 0, task, discard)
+}
+
+func (p *PriorityPool) submit(ctx context.Context, priority int, task Task, discard func(error)) error {
+	if task == nil {
+		return nil
+	}
+	if p.Stopped() {
+		return ErrPoolStopped
+	}
+	if priority < 0 {
+		priority = 0
+	}
+	if priority >= len(p.levels) {
+		priority = len(p.levels) - 1
+	}
+
+	qt := queuedTask{task: task, discard: discard, enqueued: time.Now(), priority: priority}
+	defer p.workers.reportStats()
+	for {
+		p.mu.Lock()
+		if p.pending < p.capacity {
+			p.push(qt)
+			p.mu.Unlock()
+			notify(p.ready)
+			return nil
+		}
+
+		// 队列已满
+		switch p.rejectPolicy {
+		case RejectAbort:
+			p.mu.Unlock()
+			p.workers.reject()
+			return ErrQueueFull
+		case RejectCallerRuns:
+			p.mu.Unlock()
+			p.workers.measure(queuedTask{task: task})()
+			p.workers.tasksConsumed.Inc()
+			return nil
+		case RejectDiscardOldest:
+			oldest := p.popLowest()
+			p.push(qt)
+			p.mu.Unlock()
+			p.workers.reject()
+			if oldest.discard != nil {
+				oldest.discard(ErrTaskDiscarded)
+			}
+			notify(p.ready)
+			return nil
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-p.space:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.ctx.Done():
+			return ErrPoolStopped
+		}
+	}
+}
+
+// SubmitAndWait 以最低优先级提交任务并等待其执行
+func (p *PriorityPool) SubmitAndWait(task Task) {
+	if task == nil {
+		return
+	}
+	done := make(chan struct{})
+	err := p.submit(context.Background(), 0, func() {
+		defer close(done)
+		task()
+	}, func(error) {
+		close(done)
+	})
+	if err != nil {
+		return
+	}
+	<-done
+}
+
+// push 加入对应优先级的队尾, 调用者持有 mu
+func (p *PriorityPool) push(qt queuedTask) {
+	p.levels[qt.priority] = append(p.levels[qt.priority], qt)
+	p.pending++
+}
+
+// popLowest 取出最低优先级中最早的任务, 调用者持有 mu 且队列不为空
+func (p *PriorityPool) popLowest() queuedTask {
+	for i := range p.levels {
+		if len(p.levels[i]) > 0 {
+			return p.take(i)
+		}
+	}
+	return queuedTask{}
+}
+
+// take 取出 level 优先级的队首任务, 调用者持有 mu
+func (p *PriorityPool) take(level int) queuedTask {
+	qt := p.levels[level][0]
+	p.levels[level][0] = queuedTask{}
+	p.levels[level] = p.levels[level][1:]
+	p.pending--
+	if p.pending == 0 {
+		notify(p.empty)
+	}
+	return qt
+}
+
+// pop 取出下一个执行的任务: 等待超时的任务中最早的一个, 没有时取优先级最高的任务
+func (p *PriorityPool) pop() (queuedTask, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending == 0 {
+		return queuedTask{}, false
+	}
+	defer notify(p.space)
+
+	if p.starvationTimeout > 0 {
+		starving := -1
+		deadline := time.Now().Add(-p.starvationTimeout)
+		for i, level := range p.levels {
+			if len(level) == 0 || level[0].enqueued.After(deadline) {
+				continue
+			}
+			if starving < 0 || level[0].enqueued.Before(p.levels[starving][0].enqueued) {
+				starving = i
+			}
+		}
+		if starving >= 0 {
+			return p.take(starving), true
+		}
+	}
+	for i := len(p.levels) - 1; i >= 0; i-- {
+		if len(p.levels[i]) > 0 {
+			return p.take(i), true
+		}
+	}
+	return queuedTask{}, false
+}
+
+// pushFront 放回对应优先级的队首
+func (p *PriorityPool) pushFront(qt queuedTask) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.levels[qt.priority] = append([]queuedTask{qt}, p.levels[qt.priority]...)
+	p.pending++
+}
+
+// dispatch 按优先级把任务交给 workers, workers 都在忙时阻塞
+func (p *PriorityPool) dispatch() {
+	defer close(p.onDispatcherStopped)
+	for {
+		qt, ok := p.pop()
+		if !ok {
+			select {
+			case <-p.ready:
+				continue
+			case <-p.ctx.Done():
+				return
+			}
+		}
+		if err := p.workers.enqueue(p.ctx, qt); err != nil {
+			// 协程池停止, 放回去由 Stop 执行
+			p.pushFront(qt)
+			return
+		}
+	}
+}
+
+// Resize 运行时调整并发上限
+func (p *PriorityPool) Resize(maxWorkers int) {
+	p.workers.Resize(maxWorkers)
+}
+
+func (p *PriorityPool) Stopped() bool {
+	return p.stopped.Load()
+}
+
+// Stop 停止协程池, 所有挂起的任务按优先级在退出前完成
+func (p *PriorityPool) Stop() {
+	_, _ = p.Shutdown(context.Background())
+}
+
+// Shutdown 停止接收新任务, 等待已提交的任务按优先级执行完.
+// ctx 结束时放弃还没有开始执行的任务并返回它们和 ctx.Err(), 协程池已经停止时返回 ErrPoolStopped
+func (p *PriorityPool) Shutdown(ctx context.Context) ([]Task, error) {
+	if p.stopped.Swap(true) {
+		return nil, ErrPoolStopped
+	}
+	err := p.drain(ctx)
+	p.cancel()
+	<-p.onDispatcherStopped
+	abandoned, werr := p.workers.Shutdown(ctx)
+	if err == nil {
+		err = werr
+	}
+	// dispatcher 停止时放回的任务
+	for {
+		qt, ok := p.pop()
+		if !ok {
+			break
+		}
+		switch {
+		case err == nil:
+			p.workers.measure(qt)()
+			p.workers.tasksConsumed.Inc()
+		case qt.discard != nil:
+			qt.discard(ErrPoolStopped)
+		default:
+			abandoned = append(abandoned, qt.task)
+		}
+	}
+	p.workers.reportStats()
+	return abandoned, err
+}
+
+// drain 等待 PriorityPool 中排队的任务都交给 workers, ctx 结束时返回 ctx.Err()
+func (p *PriorityPool) drain(ctx context.Context) error {
+	for {
+		p.mu.Lock()
+		pending := p.pending
+		p.mu.Unlock()
+		if pending == 0 {
+			return nil
+		}
+		select {
+		case <-p.empty:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Stats 返回协程池运行状态快照, 队列为 PriorityPool 中等待的任务
+func (p *PriorityPool) Stats() Stats {
+	st := p.workers.Stats()
+	p.mu.Lock()
+	st.QueueDepth += p.pending
+	p.mu.Unlock()
+	st.QueueCapacity = p.capacity
+	return st
+}
+
+// notify 非阻塞地发送信号
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workpool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var _ Pool = (*PriorityPool)(nil)
+
+// blockPriorityPool 占满只有一个 worker 的协程池, 之后提交的任务都在 PriorityPool 中排队
+func blockPriorityPool(t *testing.T, p *PriorityPool, release chan struct{}) {
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, p.SubmitPriority(context.Background(), 0, func() { <-release }))
+		assert.Eventually(t, func() bool { return p.Stats().QueueDepth == 0 }, time.Second, time.Millisecond)
+	}
+}
+
+func Test_PriorityPoolOrder(t *testing.T) {
+	assert := assert.New(t)
+	p := NewPriorityPool("priority", 1, 3, time.Second*5, WithQueueCapacity(16), WithStarvationTimeout(0))
+	release := make(chan struct{})
+	blockPriorityPool(t, p, release)
+
+	var (
+		mu    sync.Mutex
+		order []int
+	)
+	for i, priority := range []int{0, 2, 1, 2, 0, -1, 5} {
+		i, priority := i, priority
+		assert.Nil(p.SubmitPriority(context.Background(), priority, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, i)
+		}))
+	}
+	assert.Equal(7, p.Stats().QueueDepth)
+	close(release)
+	p.Stop()
+	// 超出范围的优先级取最近的优先级
+	assert.Equal([]int{1, 3, 6, 2, 0, 4, 5}, order)
+	assert.Equal(int32(10), p.Stats().TasksConsumed)
+}
+
+func Test_PriorityPoolStarvation(t *testing.T) {
+	assert := assert.New(t)
+	p := NewPriorityPool("priority", 1, 2, time.Second*5, WithStarvationTimeout(time.Millisecond*20))
+	release := make(chan struct{})
+	blockPriorityPool(t, p, release)
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	record := func(name string) Task {
+		return func() {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+		}
+	}
+	assert.Nil(p.Submit(context.Background(), record("low")))
+	time.Sleep(time.Millisecond * 30)
+	assert.Nil(p.SubmitPriority(context.Background(), 1, record("high")))
+	close(release)
+	p.Stop()
+	assert.Equal([]string{"low", "high"}, order)
+}
+
+func Test_PriorityPoolReject(t *testing.T) {
+	assert := assert.New(t)
+	p := NewPriorityPool("priority", 1, 2, time.Second*5,
+		WithQueueCapacity(1), WithRejectPolicy(RejectAbort))
+	release := make(chan struct{})
+	blockPriorityPool(t, p, release)
+
+	assert.Nil(p.Submit(context.Background(), func() {}))
+	assert.Equal(ErrQueueFull, p.SubmitPriority(context.Background(), 1, func() {}))
+	assert.Equal(int32(1), p.Stats().TasksRejected)
+	close(release)
+	p.Stop()
+	assert.True(p.Stopped())
+	assert.Equal(ErrPoolStopped, p.Submit(context.Background(), func() {}))
+}
+
+func Test_PriorityPoolDiscardOldest(t *testing.T) {
+	assert := assert.New(t)
+	p := NewPriorityPool("priority", 1, 2, time.Second*5,
+		WithQueueCapacity(1), WithRejectPolicy(RejectDiscardOldest))
+	release := make(chan struct{})
+	blockPriorityPool(t, p, release)
+
+	f := SubmitFunc[int](p, func(ctx context.Context) (int, error) { return 1, nil })
+	ran := false
+	assert.Nil(p.SubmitPriority(context.Background(), 1, func() { ran = true }))
+	_, err := f.Get(context.Background())
+	assert.Equal(ErrTaskDiscarded, err)
+	close(release)
+	p.Stop()
+	assert.True(ran)
+}
+
+func Test_PriorityPoolBlock(t *testing.T) {
+	assert := assert.New(t)
+	p := NewPriorityPool("priority", 1, 2, time.Second*5, WithQueueCapacity(1))
+	release := make(chan struct{})
+	blockPriorityPool(t, p, release)
+	assert.Nil(p.Submit(context.Background(), func() {}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, p.Submit(ctx, func() {}))
+
+	done := make(chan error)
+	go func() {
+		done <- p.Submit(context.Background(), func() {})
+	}()
+	close(release)
+	assert.Nil(<-done)
+	p.SubmitAndWait(func() {})
+	p.Stop()
+	assert.Equal(int32(6), p.Stats().TasksConsumed)
+}
+
+func Test_PriorityPoolShutdown(t *testing.T) {
+	assert := assert.New(t)
+	p := NewPriorityPool("priority", 1, 2, time.Second*5)
+	release := make(chan struct{})
+	blockPriorityPool(t, p, release)
+	for i := 0; i < 3; i++ {
+		assert.Nil(p.SubmitPriority(context.Background(), i, func() {}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	abandoned, err := p.Shutdown(ctx)
+	assert.Equal(context.DeadlineExceeded, err)
+	// 3个排队的任务和 blockPriorityPool 中没有开始执行的2个任务
+	assert.Len(abandoned, 5)
+	close(release)
+
+	_, err = p.Shutdown(context.Background())
+	assert.Equal(ErrPoolStopped, err)
+	assert.Equal(ErrPoolStopped, p.Submit(context.Background(), func() {}))
+}
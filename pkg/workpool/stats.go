@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workpool
+
+import (
+	"time"
+
+	"github.com/uber-go/tally"
+
+	"github.com/kubeservice-stack/common/pkg/metrics"
+)
+
+// Stats 协程池运行状态快照
+type Stats struct {
+	Name           string // 协程池名称
+	MaxWorkers     int    // 当前的并发上限, Resize 或者自适应调整后随之变化
+	WorkersAlive   int32  // 当前worker数
+	WorkersCreated int32  // 累计创建的worker数
+	WorkersKilled  int32  // 累计回收的worker数
+	TasksConsumed  int32  // 累计处理的任务数
+	TasksRejected  int32  // 累计被拒绝或者丢弃的任务数
+	QueueDepth     int    // 队列中等待的任务数
+	QueueCapacity  int    // 任务队列长度
+}
+
+// poolMetrics 协程池的metrics, 在 workpool scope 下按 pool 打标签
+type poolMetrics struct {
+	queueDepth    tally.Gauge     // 队列中等待的任务数
+	workersAlive  tally.Gauge     // 当前worker数
+	maxWorkers    tally.Gauge     // 当前的并发上限
+	tasksConsumed tally.Counter   // 处理的任务数
+	tasksRejected tally.Counter   // 被拒绝或者丢弃的任务数
+	waitTime      tally.Histogram // 任务从提交到开始执行的秒数
+	execTime      tally.Histogram // 任务执行的秒数
+}
+
+func newPoolMetrics(ts *metrics.TallyScope, pool string) *poolMetrics {
+	scope := ts.Scope.SubScope("workpool").Tagged(map[string]string{"pool": pool})
+	return &poolMetrics{
+		queueDepth:    scope.Gauge("queue_depth"),
+		workersAlive:  scope.Gauge("workers_alive"),
+		maxWorkers:    scope.Gauge("max_workers"),
+		tasksConsumed: scope.Counter("tasks_consumed"),
+		tasksRejected: scope.Counter("tasks_rejected"),
+		waitTime:      scope.Histogram("task_wait_time", metrics.DefaultTallyBuckets),
+		execTime:      scope.Histogram("task_exec_time", metrics.DefaultTallyBuckets),
+	}
+}
+
+// update 根据状态快照更新 gauge
+func (m *poolMetrics) update(st Stats) {
+	m.queueDepth.Update(float64(st.QueueDepth))
+	m.workersAlive.Update(float64(st.WorkersAlive))
+	m.maxWorkers.Update(float64(st.MaxWorkers))
+}
+
+// Stats 返回协程池运行状态快照
+func (p *workerPool) Stats() Stats {
+	return Stats{
+		Name:           p.name,
+		MaxWorkers:     int(p.maxWorkers.Load()),
+		WorkersAlive:   p.workersAlive.Load(),
+		WorkersCreated: p.workersCreated.Load(),
+		WorkersKilled:  p.workersKilled.Load(),
+		TasksConsumed:  p.tasksConsumed.Load(),
+		TasksRejected:  p.tasksRejected.Load(),
+		QueueDepth:     len(p.tasks),
+		QueueCapacity:  cap(p.tasks),
+	}
+}
+
+// reportStats 更新 gauge
+func (p *workerPool) reportStats() {
+	if p.metrics != nil {
+		p.metrics.update(p.ownerStats())
+	}
+}
+
+// ownerStats 返回 owner 的状态快照, 没有 owner 时返回自身的
+func (p *workerPool) ownerStats() Stats {
+	if p.owner != nil {
+		return p.owner.Stats()
+	}
+	return p.Stats()
+}
+
+// reject 记录被拒绝或者丢弃的任务
+func (p *workerPool) reject() {
+	p.tasksRejected.Inc()
+	if p.metrics != nil {
+		p.metrics.tasksRejected.Inc(1)
+	}
+}
+
+// measure 包装任务, 记录等待时间和执行时间
+func (p *workerPool) measure(qt queuedTask) Task {
+	if p.metrics == nil && p.limiter == nil {
+		return qt.task
+	}
+	return func() {
+		start := time.Now()
+		defer func() {
+			elapsed := time.Since(start)
+			if p.limiter != nil {
+				p.limiter.observe(elapsed)
+			}
+			if p.metrics != nil {
+				if !qt.enqueued.IsZero() {
+					p.metrics.waitTime.RecordValue(start.Sub(qt.enqueued).Seconds())
+				}
+				p.metrics.execTime.RecordValue(elapsed.Seconds())
+				p.metrics.tasksConsumed.Inc(1)
+				p.reportStats()
+			}
+		}()
+		qt.task()
+	}
+}
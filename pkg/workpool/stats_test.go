@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The KubeService-Stack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workpool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+
+	"github.com/kubeservice-stack/common/pkg/metrics"
+)
+
+func Test_PoolStats(t *testing.T) {
+	assert := assert.New(t)
+	pool := NewDefaultPool("stats", 2, time.Second*5,
+		WithQueueCapacity(4), WithRejectPolicy(RejectAbort))
+
+	st := pool.Stats()
+	assert.Equal("stats", st.Name)
+	assert.Equal(2, st.MaxWorkers)
+	assert.Equal(4, st.QueueCapacity)
+	assert.Equal(int32(0), st.TasksConsumed)
+
+	for i := 0; i < 10; i++ {
+		pool.SubmitAndWait(func() {})
+	}
+	st = pool.Stats()
+	assert.Equal(int32(10), st.TasksConsumed)
+	assert.True(st.WorkersAlive > 0 && st.WorkersAlive <= 2)
+	assert.Equal(st.WorkersCreated-st.WorkersKilled, st.WorkersAlive)
+	pool.Stop()
+}
+
+func Test_PoolMetrics(t *testing.T) {
+	assert := assert.New(t)
+	scope := tally.NewTestScope("", nil)
+	p := NewDefaultPool("metrics", 1, time.Second*5, WithQueueCapacity(1),
+		WithRejectPolicy(RejectAbort), WithTallyScope(&metrics.TallyScope{Scope: scope})).(*workerPool)
+
+	release := make(chan struct{})
+	fillPool(t, p, release)
+	assert.Equal(ErrQueueFull, p.Submit(context.Background(), func() {}))
+
+	snapshot := scope.Snapshot()
+	assert.Equal(float64(1), snapshot.Gauges()["workpool.queue_depth+pool=metrics"].Value())
+	assert.Equal(float64(1), snapshot.Gauges()["workpool.max_workers+pool=metrics"].Value())
+	assert.Equal(int64(1), snapshot.Counters()["workpool.tasks_rejected+pool=metrics"].Value())
+
+	close(release)
+	p.Stop()
+
+	snapshot = scope.Snapshot()
+	assert.Equal(int64(3), snapshot.Counters()["workpool.tasks_consumed+pool=metrics"].Value())
+	assert.Equal(float64(0), snapshot.Gauges()["workpool.queue_depth+pool=metrics"].Value())
+	histograms := snapshot.Histograms()
+	_, ok := histograms["workpool.task_wait_time+pool=metrics"]
+	assert.True(ok)
+	_, ok = histograms["workpool.task_exec_time+pool=metrics"]
+	assert.True(ok)
+}
+
+func Test_PoolWithoutMetrics(t *testing.T) {
+	assert := assert.New(t)
+	pool := NewDefaultPool("nometrics", 1, time.Second*5, WithTallyScope(nil))
+	assert.Nil(pool.(*workerPool).metrics)
+	assert.Nil(pool.Submit(context.Background(), func() {}))
+	pool.Stop()
+	assert.Equal(int32(1), pool.Stats().TasksConsumed)
+}
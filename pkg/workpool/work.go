@@ -21,6 +21,7 @@ type worker struct {
 	pool   *workerPool
 	tasks  chan Task
 	stopCh chan struct{}
+	done   chan struct{} // process 退出时关闭
 }
 
 func NewWorker(pool *workerPool) *worker {
@@ -28,6 +29,7 @@ func NewWorker(pool *workerPool) *worker {
 		pool:   pool,
 		tasks:  make(chan Task),
 		stopCh: make(chan struct{}),
+		done:   make(chan struct{}),
 	}
 	w.pool.workersAlive.Inc()
 	w.pool.workersCreated.Inc()
@@ -42,11 +44,13 @@ func (w *worker) execute(task Task) {
 func (w *worker) stop(callable func()) {
 	defer callable()
 	w.stopCh <- struct{}{}
+	<-w.done
 	w.pool.workersKilled.Inc()
 	w.pool.workersAlive.Dec()
 }
 
 func (w *worker) process() {
+	defer close(w.done)
 	var task Task
 	for {
 		select {
@@ -55,6 +59,10 @@ func (w *worker) process() {
 		case task = <-w.tasks:
 			task()
 			w.pool.tasksConsumed.Inc()
+			// 超过并发上限时退出
+			if w.pool.retire() {
+				return
+			}
 			// 将w注册到readyWorkers
 			w.pool.readyWorkers <- w
 		}
@@ -32,8 +32,8 @@ func Test_NewWorker(t *testing.T) {
 	w := NewWorker(
 		&workerPool{
 			name:                "test",
-			maxWorkers:          2,
-			tasks:               make(chan Task, tasksCapacity),
+			maxWorkers:          *atomic.NewInt32(2),
+			tasks:               make(chan queuedTask, tasksCapacity),
 			readyWorkers:        make(chan *worker, readyWorkerQueueSize),
 			idleTimeout:         time.Second * 5,
 			onDispatcherStopped: make(chan struct{}),
@@ -29,35 +29,59 @@ const (
 	readyWorkerQueueSize = 32
 	// Task 数据
 	tasksCapacity = 8
-	// 队列为空时，sleep 5ms
-	sleepInterval = time.Millisecond * 5
 )
 
+// queuedTask 队列中的任务
+type queuedTask struct {
+	task     Task
+	discard  func(error) // 任务被丢弃时调用, 可以为nil
+	enqueued time.Time   // 提交时间
+	priority int         // 优先级, 仅 PriorityPool 使用
+}
+
 type workerPool struct {
-	name                string        // 工作协程池名称
-	maxWorkers          int           // 最大工程协程池数据
-	tasks               chan Task     // Task channel
-	readyWorkers        chan *worker  // 当前活跃工作协程
-	idleTimeout         time.Duration // 空闲goroutine回收时间
-	onDispatcherStopped chan struct{} // stop信号
-	stopped             atomic.Bool   // 标记 协程池是否关闭
-	workersAlive        atomic.Int32  // 当前协程使用数
-	workersCreated      atomic.Int32  // 当前协程创建数
-	workersKilled       atomic.Int32  // 当前协程完成数： 包括被kill
-	tasksConsumed       atomic.Int32  // 处理的任务数
+	name                string           // 工作协程池名称
+	maxWorkers          atomic.Int32     // 最大工程协程池数据, 可以通过 Resize 调整
+	rejectPolicy        RejectPolicy     // 任务队列满时的拒绝策略
+	workersMu           sync.Mutex       // 创建和回收worker时加锁, 保证不超过 maxWorkers
+	resized             chan struct{}    // maxWorkers 调整的信号
+	limiter             *adaptiveLimiter // 自适应调整并发上限, 可以为nil
+	tasks               chan queuedTask  // Task channel
+	readyWorkers        chan *worker     // 当前活跃工作协程
+	idleTimeout         time.Duration    // 空闲goroutine回收时间
+	onDispatcherStopped chan struct{}    // stop信号
+	stopped             atomic.Bool      // 标记 协程池是否关闭
+	workersAlive        atomic.Int32     // 当前协程使用数
+	workersCreated      atomic.Int32     // 当前协程创建数
+	workersKilled       atomic.Int32     // 当前协程完成数： 包括被kill
+	tasksConsumed       atomic.Int32     // 处理的任务数
+	tasksRejected       atomic.Int32     // 被拒绝或者丢弃的任务数
+	tasksPending        atomic.Int32     // 已提交还没有执行完的任务数
+	idle                chan struct{}    // tasksPending 减到0的信号
+	held                []queuedTask     // 停止时 dispatcher 没有交给 worker 的任务
+	metrics             *poolMetrics     // 为nil时不上报metrics
+	owner               Pool             // 包装当前协程池的 PriorityPool 或 KeyedPool, 可以为nil
 	ctx                 context.Context
 	cancel              context.CancelFunc
 }
 
-func NewWorkerPool(name string, maxWorkers int, idleTimeout time.Duration) Pool {
+func NewWorkerPool(name string, maxWorkers int, idleTimeout time.Duration, opts ...Option) Pool {
+	return newWorkerPool(name, maxWorkers, idleTimeout, newOptions(opts), nil)
+}
+
+// newWorkerPool 创建协程池, owner 不为nil时按 owner 的状态上报 metrics
+func newWorkerPool(name string, maxWorkers int, idleTimeout time.Duration, o *options, owner Pool) *workerPool {
 	if maxWorkers < 1 {
 		maxWorkers = 1
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	pool := &workerPool{
 		name:                name,
-		maxWorkers:          maxWorkers,
-		tasks:               make(chan Task, tasksCapacity),
+		maxWorkers:          *atomic.NewInt32(int32(maxWorkers)),
+		resized:             make(chan struct{}, 1),
+		idle:                make(chan struct{}, 1),
+		rejectPolicy:        o.rejectPolicy,
+		tasks:               make(chan queuedTask, o.queueCapacity),
 		readyWorkers:        make(chan *worker, readyWorkerQueueSize),
 		idleTimeout:         idleTimeout,
 		onDispatcherStopped: make(chan struct{}),
@@ -66,51 +90,204 @@ func NewWorkerPool(name string, maxWorkers int, idleTimeout time.Duration) Pool
 		workersCreated:      *atomic.NewInt32(0),
 		workersKilled:       *atomic.NewInt32(0),
 		tasksConsumed:       *atomic.NewInt32(0),
+		owner:               owner,
 		ctx:                 ctx,
 		cancel:              cancel,
 	}
+	if o.tallyScope != nil {
+		pool.metrics = newPoolMetrics(o.tallyScope, name)
+		// owner 创建完成后再上报
+		if owner == nil {
+			pool.reportStats()
+		}
+	}
+	if o.adaptive != nil {
+		pool.limiter = newAdaptiveLimiter(o.adaptive)
+		go pool.adapt()
+	}
 	go pool.dispatch()
 	return pool
 }
 
-func (p *workerPool) Submit(task Task) {
-	if task == nil || p.Stopped() {
-		return
+// Submit 提交任务到队列, 队列满时按照拒绝策略处理
+func (p *workerPool) Submit(ctx context.Context, task Task) error {
+	return p.submitWithDiscard(ctx, task, nil)
+}
+
+func (p *workerPool) submitWithDiscard(ctx context.Context, task Task, discard func(error)) error {
+	if task == nil {
+		return nil
 	}
-	p.tasks <- task
+	if p.Stopped() {
+		return ErrPoolStopped
+	}
+
+	return p.enqueue(ctx, queuedTask{task: task, discard: discard, enqueued: time.Now()})
 }
 
+// enqueue 任务入队, 队列满时按照拒绝策略处理
+func (p *workerPool) enqueue(ctx context.Context, qt queuedTask) (err error) {
+	// 先计数, 避免任务在计数前执行完
+	pending := p.tasksPending.Inc()
+	defer func() {
+		if err != nil {
+			p.done()
+		}
+		p.reportStats()
+	}()
+	if cap(p.tasks) == 0 {
+		// 队列长度为0时任务直接交给worker: 执行中和交接中的任务数不超过并发上限时一定有worker接手, 阻塞交接;
+		// RejectDiscardOldest 没有可以丢弃的任务, 同样阻塞交接
+		if pending <= p.maxWorkers.Load() || p.rejectPolicy == RejectDiscardOldest {
+			return p.put(ctx, qt)
+		}
+	} else {
+		select {
+		case p.tasks <- qt:
+			return nil
+		default:
+		}
+	}
+
+	// 队列已满
+	switch p.rejectPolicy {
+	case RejectAbort:
+		p.reject()
+		return ErrQueueFull
+	case RejectCallerRuns:
+		p.run(queuedTask{task: qt.task})()
+		p.tasksConsumed.Inc()
+		return nil
+	case RejectDiscardOldest:
+		for {
+			select {
+			case p.tasks <- qt:
+				return nil
+			case <-p.ctx.Done():
+				return ErrPoolStopped
+			default:
+			}
+			select {
+			case oldest := <-p.tasks:
+				p.reject()
+				p.done()
+				if oldest.discard != nil {
+					oldest.discard(ErrTaskDiscarded)
+				}
+			default:
+			}
+		}
+	default:
+		return p.put(ctx, qt)
+	}
+}
+
+// put 阻塞入队, 直到入队成功, ctx结束或者协程池停止
+func (p *workerPool) put(ctx context.Context, qt queuedTask) error {
+	select {
+	case p.tasks <- qt:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.ctx.Done():
+		return ErrPoolStopped
+	}
+}
+
+// SubmitAndWait 提交任务并等待其执行, 协程池停止时不执行
 func (p *workerPool) SubmitAndWait(task Task) {
 	if task == nil || p.Stopped() {
 		return
 	}
+	p.tasksPending.Inc()
+	run := p.run(queuedTask{task: task, enqueued: time.Now()})
 	worker := p.mustGetWorker()
+	if worker == nil {
+		// 协程池正在停止, 在调用者的协程中执行
+		run()
+		return
+	}
 	doneChan := make(chan struct{})
 	worker.execute(func() {
-		task()
+		run()
 		close(doneChan)
 	})
 	<-doneChan
 }
 
-// 返回可用worker
+// run 包装任务, 执行完后减少未完成的任务数
+func (p *workerPool) run(qt queuedTask) Task {
+	measured := p.measure(qt)
+	return func() {
+		defer p.done()
+		measured()
+	}
+}
+
+// done 一个任务执行完或者被丢弃, 没有未完成的任务时通知 drain
+func (p *workerPool) done() {
+	if p.tasksPending.Dec() == 0 {
+		notify(p.idle)
+	}
+}
+
+// 返回可用worker, 协程池停止时返回nil
 func (p *workerPool) mustGetWorker() *worker {
-	var worker *worker
 	for {
 		select {
 		// 获得一个worker
-		case worker = <-p.readyWorkers:
+		case worker := <-p.readyWorkers:
 			return worker
 		default:
-			if int(p.workersAlive.Load()) >= p.maxWorkers {
-				// 没有可用worker
-				time.Sleep(sleepInterval)
-				continue
-			}
+		}
+
+		p.workersMu.Lock()
+		if p.workersAlive.Load() < p.maxWorkers.Load() {
 			w := NewWorker(p)
+			p.workersMu.Unlock()
 			return w
 		}
+		p.workersMu.Unlock()
+
+		// 没有可用worker, 等待worker执行完任务后交还, 或者并发上限调大
+		select {
+		case worker := <-p.readyWorkers:
+			return worker
+		case <-p.resized:
+		case <-p.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Resize 运行时调整并发上限. 调小时空闲的worker立即回收, 忙碌的worker执行完当前任务后回收
+func (p *workerPool) Resize(maxWorkers int) {
+	if maxWorkers < 1 {
+		maxWorkers = 1
+	}
+	p.maxWorkers.Store(int32(maxWorkers))
+	notify(p.resized)
+	defer p.reportStats()
+	for p.workersAlive.Load() > int32(maxWorkers) {
+		select {
+		case worker := <-p.readyWorkers:
+			worker.stop(func() {})
+		default:
+			return
+		}
+	}
+}
+
+// retire worker数超过并发上限时回收执行完任务的worker, 返回是否回收
+func (p *workerPool) retire() bool {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	if p.workersAlive.Load() <= p.maxWorkers.Load() {
+		return false
 	}
+	p.workersKilled.Inc()
+	p.workersAlive.Dec()
+	return true
 }
 
 func (p *workerPool) dispatch() {
@@ -122,7 +299,7 @@ func (p *workerPool) dispatch() {
 	defer idleTimeoutTimer.Stop()
 	var (
 		worker *worker
-		task   Task
+		qt     queuedTask
 	)
 
 	for {
@@ -130,9 +307,14 @@ func (p *workerPool) dispatch() {
 		select {
 		case <-p.ctx.Done():
 			return
-		case task = <-p.tasks:
+		case qt = <-p.tasks:
 			worker := p.mustGetWorker()
-			worker.execute(task)
+			if worker == nil {
+				// 协程池正在停止, 交给 Shutdown 处理
+				p.held = append(p.held, qt)
+				return
+			}
+			worker.execute(p.run(qt))
 		case <-idleTimeoutTimer.C:
 			// 超时, kill掉worker
 			if p.workersAlive.Load() > 0 {
@@ -164,30 +346,81 @@ func (p *workerPool) stopWorkers() {
 	wg.Wait()
 }
 
-// consumedRemainingTasks consumes all buffered tasks in the channel
-func (p *workerPool) consumedRemainingTasks() {
+// remainingTasks takes the task held by the dispatcher and all buffered tasks in the channel
+func (p *workerPool) remainingTasks() []queuedTask {
+	remaining := p.held
+	p.held = nil
 	for {
 		select {
-		case task := <-p.tasks:
-			task()
-			p.tasksConsumed.Inc()
+		case qt := <-p.tasks:
+			remaining = append(remaining, qt)
 		default:
-			return
+			return remaining
+		}
+	}
+}
+
+// consumedRemainingTasks consumes all remaining tasks
+func (p *workerPool) consumedRemainingTasks() {
+	for _, qt := range p.remainingTasks() {
+		p.run(qt)()
+		p.tasksConsumed.Inc()
+	}
+}
+
+// abandonRemainingTasks drops all remaining tasks and returns them.
+// Tasks of SubmitFunc and Group are not returned, their Future and Group get ErrPoolStopped
+func (p *workerPool) abandonRemainingTasks() []Task {
+	var abandoned []Task
+	for _, qt := range p.remainingTasks() {
+		p.done()
+		if qt.discard != nil {
+			qt.discard(ErrPoolStopped)
+			continue
+		}
+		abandoned = append(abandoned, qt.task)
+	}
+	return abandoned
+}
+
+// drain 等待所有提交的任务执行完, ctx 结束时返回 ctx.Err()
+func (p *workerPool) drain(ctx context.Context) error {
+	for p.tasksPending.Load() > 0 {
+		select {
+		case <-p.idle:
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
+	return nil
 }
 
 // Stop tells the dispatcher to exit with pending tasks done.
 func (p *workerPool) Stop() {
+	_, _ = p.Shutdown(context.Background())
+}
+
+// Shutdown 停止接收新任务, 等待已提交的任务执行完.
+// ctx 结束时放弃还没有开始执行的任务并返回它们和 ctx.Err(), 正在执行的任务不会被中断, 在后台执行完.
+// 协程池已经停止时返回 ErrPoolStopped
+func (p *workerPool) Shutdown(ctx context.Context) ([]Task, error) {
 	if p.stopped.Swap(true) {
-		return
+		return nil, ErrPoolStopped
 	}
+	err := p.drain(ctx)
 	// close dispatcher
 	p.cancel()
 	// wait dispatcher's exit
 	<-p.onDispatcherStopped
+	defer p.reportStats()
+	if err != nil {
+		abandoned := p.abandonRemainingTasks()
+		go p.stopWorkers()
+		return abandoned, err
+	}
 	// close all workers
 	p.stopWorkers()
-	// consume remaining tasks
+	// consume tasks submitted while stopping
 	p.consumedRemainingTasks()
+	return nil, nil
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package workpool
 
 import (
+	"context"
 	"runtime"
 	"testing"
 	"time"
@@ -37,7 +38,7 @@ func Test_PoolSubmit(t *testing.T) {
 	finished := make(chan struct{})
 	do := func(iterations int) {
 		for i := 0; i < iterations; i++ {
-			pool.Submit(func() {
+			pool.Submit(context.Background(), func() {
 				c.Inc()
 			})
 		}
@@ -101,3 +102,206 @@ func Test_PoolStoped(t *testing.T) {
 	ret = pool.Stopped()
 	assert.True(ret)
 }
+
+// fillPool 占满worker, dispatcher 和任务队列
+func fillPool(t *testing.T, p *workerPool, release chan struct{}) {
+	block := func() { <-release }
+	for i := 0; i < int(p.maxWorkers.Load())+1; i++ {
+		assert.Nil(t, p.Submit(context.Background(), block))
+		assert.Eventually(t, func() bool { return len(p.tasks) == 0 }, time.Second, time.Millisecond)
+	}
+	for len(p.tasks) < cap(p.tasks) {
+		assert.Nil(t, p.Submit(context.Background(), block))
+	}
+}
+
+func Test_PoolRejectAbort(t *testing.T) {
+	assert := assert.New(t)
+	p := NewDefaultPool("test", 1, time.Second*5,
+		WithQueueCapacity(2), WithRejectPolicy(RejectAbort)).(*workerPool)
+	release := make(chan struct{})
+	fillPool(t, p, release)
+	assert.Equal(2, cap(p.tasks))
+
+	assert.Equal(ErrQueueFull, p.Submit(context.Background(), func() {}))
+	assert.Equal(int32(1), p.tasksRejected.Load())
+	close(release)
+	p.Stop()
+	assert.Equal(ErrPoolStopped, p.Submit(context.Background(), func() {}))
+}
+
+func Test_PoolRejectCallerRuns(t *testing.T) {
+	assert := assert.New(t)
+	p := NewDefaultPool("test", 1, time.Second*5,
+		WithQueueCapacity(1), WithRejectPolicy(RejectCallerRuns)).(*workerPool)
+	release := make(chan struct{})
+	fillPool(t, p, release)
+
+	ran := false
+	assert.Nil(p.Submit(context.Background(), func() { ran = true }))
+	assert.True(ran)
+	close(release)
+	p.Stop()
+}
+
+func Test_PoolRejectDiscardOldest(t *testing.T) {
+	assert := assert.New(t)
+	p := NewDefaultPool("test", 1, time.Second*5,
+		WithQueueCapacity(1), WithRejectPolicy(RejectDiscardOldest)).(*workerPool)
+	release := make(chan struct{})
+	fillPool(t, p, release)
+
+	// 丢弃 fillPool 提交的任务
+	f := SubmitFunc(p, func(ctx context.Context) (int, error) { return 1, nil })
+	assert.Equal(int32(1), p.tasksRejected.Load())
+
+	// 丢弃 Future 的任务
+	var c atomic.Int32
+	assert.Nil(p.Submit(context.Background(), func() { c.Inc() }))
+	assert.Equal(int32(2), p.tasksRejected.Load())
+	_, err := f.Get(context.Background())
+	assert.Equal(ErrTaskDiscarded, err)
+
+	close(release)
+	p.Stop()
+	assert.Equal(int32(1), c.Load())
+}
+
+func Test_PoolRejectBlock(t *testing.T) {
+	assert := assert.New(t)
+	p := NewDefaultPool("test", 1, time.Second*5, WithQueueCapacity(1)).(*workerPool)
+	release := make(chan struct{})
+	fillPool(t, p, release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, p.Submit(ctx, func() {}))
+
+	done := make(chan error)
+	go func() {
+		done <- p.Submit(context.Background(), func() {})
+	}()
+	close(release)
+	assert.Nil(<-done)
+	p.Stop()
+}
+
+func Test_PoolZeroQueueCapacity(t *testing.T) {
+	for _, policy := range []RejectPolicy{RejectBlock, RejectAbort, RejectCallerRuns, RejectDiscardOldest} {
+		assert := assert.New(t)
+		p := NewDefaultPool("test", 2, time.Second*5,
+			WithQueueCapacity(0), WithRejectPolicy(policy)).(*workerPool)
+		release := make(chan struct{})
+		// 并发未满时任务都被接手, 不会被拒绝
+		for i := 0; i < 2; i++ {
+			assert.Nil(p.Submit(context.Background(), func() { <-release }), policy)
+		}
+		assert.Equal(int32(0), p.tasksRejected.Load(), policy)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		ran := false
+		err := p.Submit(ctx, func() { ran = true })
+		cancel()
+		switch policy {
+		case RejectAbort:
+			assert.Equal(ErrQueueFull, err)
+			assert.Equal(int32(1), p.tasksRejected.Load())
+		case RejectCallerRuns:
+			assert.Nil(err)
+			assert.True(ran)
+		default:
+			// dispatcher 取走一个任务等待worker空闲, 之后的提交阻塞
+			assert.Nil(err, policy)
+			ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*50)
+			assert.Equal(context.DeadlineExceeded, p.Submit(ctx, func() {}), policy)
+			cancel()
+			assert.Equal(int32(0), p.tasksRejected.Load(), policy)
+		}
+
+		close(release)
+		assert.Eventually(func() bool { return p.tasksPending.Load() == 0 }, time.Second, time.Millisecond, policy)
+		assert.Nil(p.Submit(context.Background(), func() {}), policy)
+		p.Stop()
+	}
+}
+
+func Test_PoolMaxWorkers(t *testing.T) {
+	assert := assert.New(t)
+	pool := NewDefaultPool("test", 3, time.Second*5)
+	var running, peak atomic.Int32
+	group, _ := NewGroup(context.Background(), pool)
+	for i := 0; i < 100; i++ {
+		group.Go(func(ctx context.Context) error {
+			n := running.Inc()
+			for {
+				old := peak.Load()
+				if n <= old || peak.CAS(old, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Dec()
+			return nil
+		})
+	}
+	assert.Nil(group.Wait())
+	pool.Stop()
+	assert.True(peak.Load() <= 3)
+	assert.True(peak.Load() > 0)
+}
+
+func Test_PoolShutdown(t *testing.T) {
+	assert := assert.New(t)
+	pool := NewDefaultPool("test", 2, time.Second*5, WithQueueCapacity(32))
+	var c atomic.Int32
+	for i := 0; i < 20; i++ {
+		assert.Nil(pool.Submit(context.Background(), func() {
+			time.Sleep(time.Millisecond)
+			c.Inc()
+		}))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	abandoned, err := pool.Shutdown(ctx)
+	assert.Nil(err)
+	assert.Empty(abandoned)
+	assert.Equal(int32(20), c.Load())
+	assert.Equal(int32(20), pool.Stats().TasksConsumed)
+
+	_, err = pool.Shutdown(ctx)
+	assert.Equal(ErrPoolStopped, err)
+	assert.Equal(ErrPoolStopped, pool.Submit(ctx, func() {}))
+}
+
+func Test_PoolShutdownDeadline(t *testing.T) {
+	assert := assert.New(t)
+	pool := NewDefaultPool("test", 1, time.Second*5)
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	assert.Nil(pool.Submit(context.Background(), func() {
+		<-release
+		close(finished)
+	}))
+	var c atomic.Int32
+	for i := 0; i < 3; i++ {
+		assert.Nil(pool.Submit(context.Background(), func() { c.Inc() }))
+	}
+	f := SubmitFunc(pool, func(ctx context.Context) (int, error) { return 1, nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	abandoned, err := pool.Shutdown(ctx)
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.Len(abandoned, 3)
+	_, err = f.Get(context.Background())
+	assert.Equal(ErrPoolStopped, err)
+
+	// 正在执行的任务在后台执行完
+	close(release)
+	<-finished
+	assert.Equal(int32(0), c.Load())
+	for _, task := range abandoned {
+		task()
+	}
+	assert.Equal(int32(3), c.Load())
+}